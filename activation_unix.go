@@ -0,0 +1,71 @@
+//go:build unix
+
+package wildcat
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The first file descriptor passed by systemd socket activation.
+const listenFdsStart = 3
+
+// ActivationListeners returns the listeners passed to the process through
+// systemd-style socket activation (LISTEN_PID, LISTEN_FDS and the optional
+// LISTEN_FDNAMES), in file descriptor order. If the process was not socket
+// activated, it returns no listeners and no error.
+//
+// The LISTEN_* variables are removed from the environment so that child
+// processes don't also try to use the descriptors.
+func ActivationListeners() ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, nfds)
+
+	for i := 0; i < nfds; i++ {
+		fd := listenFdsStart + i
+
+		syscall.CloseOnExec(fd)
+
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+
+			return nil, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
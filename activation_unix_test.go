@@ -0,0 +1,76 @@
+//go:build unix
+
+package wildcat
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run by TestActivationListeners in a child process that was handed a
+// listener at fd 3.
+func TestActivationListenersChild(t *testing.T) {
+	addr := os.Getenv("WILDCAT_ACTIVATION_ADDR")
+	if addr == "" {
+		t.Skip("only run as a subprocess")
+	}
+
+	listeners, err := ActivationListeners()
+	require.NoError(t, err)
+	require.Len(t, listeners, 1)
+	defer listeners[0].Close()
+
+	assert.Equal(t, addr, listeners[0].Addr().String())
+
+	// The variables are cleared so children don't take the descriptors.
+	assert.Equal(t, "", os.Getenv("LISTEN_PID"))
+	assert.Equal(t, "", os.Getenv("LISTEN_FDS"))
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	s, err := listeners[0].Accept()
+	require.NoError(t, err)
+	s.Close()
+}
+
+func TestActivationListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer f.Close()
+
+	// The shell execs the test binary, so LISTEN_PID is the child's pid as
+	// it would be under systemd.
+	cmd := exec.Command("/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`,
+		os.Args[0], "-test.run=^TestActivationListenersChild$", "-test.v")
+	cmd.Env = append(os.Environ(),
+		"WILDCAT_ACTIVATION_ADDR="+l.Addr().String(),
+		"LISTEN_FDS=1",
+		"LISTEN_FDNAMES=http",
+	)
+	cmd.ExtraFiles = []*os.File{f}
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "--- PASS: TestActivationListenersChild")
+}
+
+func TestActivationListenersOtherPid(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getppid()))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := ActivationListeners()
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 0)
+}
@@ -0,0 +1,216 @@
+package wildcat
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/vektra/errors"
+)
+
+var ErrBadEscape = errors.New("invalid percent escape")
+
+// Return the query part of the request target, without the '?'.
+func (hp *HTTPParser) Query() []byte {
+	if q := bytes.IndexByte(hp.Path, '?'); q != -1 {
+		return hp.Path[q+1:]
+	}
+
+	return nil
+}
+
+// Append the decoded form of a query or form component to dst: percent
+// escapes are decoded and '+' becomes a space.
+func AppendUnescapeArg(dst, s []byte) ([]byte, error) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '+':
+			dst = append(dst, ' ')
+		case '%':
+			if i+2 >= len(s) {
+				return dst, ErrBadEscape
+			}
+
+			hi, ok1 := fromHex(s[i+1])
+			lo, ok2 := fromHex(s[i+2])
+			if !ok1 || !ok2 {
+				return dst, ErrBadEscape
+			}
+
+			dst = append(dst, hi<<4|lo)
+			i += 2
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return dst, nil
+}
+
+const upperHex = "0123456789ABCDEF"
+
+// Report whether c is unreserved (RFC 3986, section 2.3), and so never
+// needs escaping.
+func isUnreserved(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	switch c {
+	case '-', '.', '_', '~':
+		return true
+	}
+
+	return false
+}
+
+func appendEscaped(dst, s []byte, keep byte, spacePlus bool) []byte {
+	for _, c := range s {
+		switch {
+		case isUnreserved(c) || (keep != 0 && c == keep):
+			dst = append(dst, c)
+		case c == ' ' && spacePlus:
+			dst = append(dst, '+')
+		default:
+			dst = append(dst, '%', upperHex[c>>4], upperHex[c&15])
+		}
+	}
+
+	return dst
+}
+
+// Append s to dst with every byte but the unreserved ones percent-encoded,
+// so it can be used as any part of a URL.
+func AppendQuoted(dst, s []byte) []byte {
+	return appendEscaped(dst, s, 0, false)
+}
+
+// Append p to dst escaped for use as a URL path: like AppendQuoted, but '/'
+// is kept.
+func AppendQuotedPath(dst, p []byte) []byte {
+	return appendEscaped(dst, p, '/', false)
+}
+
+// Append "key=value" to a query in dst, escaped the way AppendUnescapeArg
+// decodes it. A '&' is added first unless dst is empty or ends in '?' or
+// '&'.
+func AppendQueryArg(dst, key, value []byte) []byte {
+	if n := len(dst); n > 0 && dst[n-1] != '?' && dst[n-1] != '&' {
+		dst = append(dst, '&')
+	}
+
+	dst = appendEscaped(dst, key, 0, true)
+	dst = append(dst, '=')
+	return appendEscaped(dst, value, 0, true)
+}
+
+// Call fn with the raw (still escaped) key and value of each argument in
+// args, which is a query or an application/x-www-form-urlencoded body.
+// Stops early if fn returns false.
+func VisitArgs(args []byte, fn func(key, value []byte) bool) {
+	for len(args) > 0 {
+		var arg []byte
+
+		if amp := bytes.IndexByte(args, '&'); amp != -1 {
+			arg, args = args[:amp], args[amp+1:]
+		} else {
+			arg, args = args, nil
+		}
+
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value := arg, []byte(nil)
+		if eq := bytes.IndexByte(arg, '='); eq != -1 {
+			key, value = arg[:eq], arg[eq+1:]
+		}
+
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+var (
+	queryBinds = &bindCache{tag: "query", multi: true}
+	formBinds  = &bindCache{tag: "form", multi: true}
+)
+
+// Fill the fields of v from args using bc's tag. Slice fields collect every
+// value for their name; other fields get the last one.
+func bindArgs(bc *bindCache, args []byte, v interface{}) error {
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+
+	fields, err := bc.fields(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	var (
+		key, value []byte
+		bindErr    error
+		reset      uint64
+	)
+
+	VisitArgs(args, func(rawKey, rawValue []byte) bool {
+		if key, bindErr = AppendUnescapeArg(key[:0], rawKey); bindErr != nil {
+			return false
+		}
+
+		for i := range fields {
+			f := &fields[i]
+			if !bytes.Equal(key, f.name) {
+				continue
+			}
+
+			if value, bindErr = AppendUnescapeArg(value[:0], rawValue); bindErr != nil {
+				return false
+			}
+
+			dst := rv.Field(f.index)
+
+			if f.slice {
+				// Replace, rather than add to, whatever the slice held.
+				if i < 64 && reset&(1<<uint(i)) == 0 {
+					dst.SetLen(0)
+					reset |= 1 << uint(i)
+				}
+
+				dst.Set(reflect.Append(dst, reflect.Zero(dst.Type().Elem())))
+				dst = dst.Index(dst.Len() - 1)
+			}
+
+			// The value buffer is reused, so []byte fields get a copy.
+			if f.kind == bindBytes {
+				bindErr = bindSet(dst, f, append([]byte(nil), value...))
+			} else {
+				bindErr = bindSet(dst, f, value)
+			}
+
+			if bindErr != nil {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return bindErr
+}
+
+// Fill the struct v points to from the request's query, using `query:"name"`
+// tags. Fields can be of the types BindHeaders supports, or slices of them
+// to collect repeated parameters.
+func (hp *HTTPParser) BindQuery(v interface{}) error {
+	return bindArgs(queryBinds, hp.Query(), v)
+}
+
+// Fill the struct v points to from an application/x-www-form-urlencoded
+// body, using `form:"name"` tags, as BindQuery does for the query.
+func BindForm(body []byte, v interface{}) error {
+	return bindArgs(formBinds, body, v)
+}
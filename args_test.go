@@ -0,0 +1,98 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+func TestAppendUnescapeArg(t *testing.T) {
+	out, err := AppendUnescapeArg(nil, []byte("a+b%20c%2Fd"))
+	require.NoError(t, err)
+	assert.Equal(t, "a b c/d", string(out))
+
+	_, err = AppendUnescapeArg(nil, []byte("bad%2"))
+	assert.Equal(t, ErrBadEscape, err)
+
+	_, err = AppendUnescapeArg(nil, []byte("bad%zz"))
+	assert.Equal(t, ErrBadEscape, err)
+}
+
+func TestVisitArgs(t *testing.T) {
+	var got []string
+
+	VisitArgs([]byte("a=1&&b&c=3"), func(key, value []byte) bool {
+		got = append(got, string(key)+":"+string(value))
+		return key[0] != 'b'
+	})
+
+	assert.Equal(t, []string{"a:1", "b:"}, got)
+}
+
+type searchParams struct {
+	Query string   `query:"q" form:"q"`
+	Page  int      `query:"page"`
+	Tags  []string `query:"tag" form:"tag"`
+	IDs   []int    `query:"id"`
+	Raw   []byte   `query:"raw"`
+}
+
+func TestBindQuery(t *testing.T) {
+	hp := parseRequest(t, "GET /search?q=hello+world&page=2&tag=a&tag=b%26c&id=1&id=2&raw=x%00y&other=1 HTTP/1.1\r\n\r\n")
+
+	p := searchParams{Tags: []string{"default"}}
+	require.NoError(t, hp.BindQuery(&p))
+
+	assert.Equal(t, "hello world", p.Query)
+	assert.Equal(t, 2, p.Page)
+	assert.Equal(t, []string{"a", "b&c"}, p.Tags)
+	assert.Equal(t, []int{1, 2}, p.IDs)
+	assert.Equal(t, []byte("x\x00y"), p.Raw)
+
+	hp = parseRequest(t, "GET /search?page=two HTTP/1.1\r\n\r\n")
+	assert.Equal(t, ErrBindValue, errors.Cause(hp.BindQuery(&p)))
+}
+
+func TestBindForm(t *testing.T) {
+	var p searchParams
+	require.NoError(t, BindForm([]byte("q=%C3%A9t%C3%A9&tag=x&page=9"), &p))
+
+	assert.Equal(t, "été", p.Query)
+	assert.Equal(t, []string{"x"}, p.Tags)
+
+	// page has no form tag.
+	assert.Equal(t, 0, p.Page)
+}
+
+func TestAppendQuoted(t *testing.T) {
+	assert.Equal(t, "a%20b%2Fc%3F%26%3D~-._%C3%A9", string(AppendQuoted(nil, []byte("a b/c?&=~-._\xc3\xa9"))))
+	assert.Equal(t, "/files/a%20b/%25.txt", string(AppendQuotedPath(nil, []byte("/files/a b/%.txt"))))
+}
+
+func TestAppendQueryArg(t *testing.T) {
+	dst := []byte("/search?")
+	dst = AppendQueryArg(dst, []byte("q"), []byte("fish & chips"))
+	dst = AppendQueryArg(dst, []byte("page no"), []byte("2+"))
+
+	assert.Equal(t, "/search?q=fish+%26+chips&page+no=2%2B", string(dst))
+
+	var got []string
+	VisitArgs(dst[len("/search?"):], func(key, value []byte) bool {
+		k, err := AppendUnescapeArg(nil, key)
+		require.NoError(t, err)
+		v, err := AppendUnescapeArg(nil, value)
+		require.NoError(t, err)
+		got = append(got, string(k)+"="+string(v))
+		return true
+	})
+
+	assert.Equal(t, []string{"q=fish & chips", "page no=2+"}, got)
+
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		AppendQueryArg(buf[:0], []byte("k"), []byte("v w"))
+	})
+	assert.Equal(t, float64(0), allocs)
+}
@@ -0,0 +1,260 @@
+package wildcat
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrBindTarget = errors.New("bind target must be a pointer to a struct")
+	ErrBindValue  = errors.New("value can't be converted to field type")
+)
+
+type bindKind int
+
+const (
+	bindString bindKind = iota
+	bindBytes
+	bindInt
+	bindUint
+	bindFloat
+	bindBool
+	bindTime
+)
+
+// How one struct field is bound: the field's index, the name from its
+// tag and how to convert the value.
+type bindField struct {
+	index int
+	name  []byte
+	class headerClass
+	kind  bindKind
+	bits  int
+	slice bool
+}
+
+// The field layouts for one tag, by struct type, so reflection over a
+// struct's fields happens once per type. Slice fields (other than []byte)
+// take every value when multi is set.
+type bindCache struct {
+	tag   string
+	multi bool
+	types sync.Map
+}
+
+var headerBinds = &bindCache{tag: "header"}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func bindKindOf(t reflect.Type) (bindKind, bool) {
+	if t == timeType {
+		return bindTime, true
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return bindString, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return bindInt, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bindUint, true
+	case reflect.Float32, reflect.Float64:
+		return bindFloat, true
+	case reflect.Bool:
+		return bindBool, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bindBytes, true
+		}
+	}
+
+	return 0, false
+}
+
+// Return the bindings for the fields of t.
+func (bc *bindCache) fields(t reflect.Type) ([]bindField, error) {
+	if fields, ok := bc.types.Load(t); ok {
+		return fields.([]bindField), nil
+	}
+
+	var fields []bindField
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		name, ok := sf.Tag.Lookup(bc.tag)
+		if !ok || name == "" || name == "-" || sf.PkgPath != "" {
+			continue
+		}
+
+		ft := sf.Type
+		slice := false
+
+		if bc.multi && ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+			ft = ft.Elem()
+			slice = true
+		}
+
+		kind, ok := bindKindOf(ft)
+		if !ok {
+			return nil, errors.Context(ErrBindTarget, "unsupported type for field "+sf.Name)
+		}
+
+		bits := 0
+		if kind == bindInt || kind == bindUint || kind == bindFloat {
+			bits = ft.Bits()
+		}
+
+		fields = append(fields, bindField{
+			index: i,
+			name:  []byte(name),
+			class: lookupHeaderClass([]byte(name)),
+			kind:  kind,
+			bits:  bits,
+			slice: slice,
+		})
+	}
+
+	bc.types.Store(t, fields)
+	return fields, nil
+}
+
+// Return the struct v points to.
+func bindTarget(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, ErrBindTarget
+	}
+
+	return rv.Elem(), nil
+}
+
+// Parse a decimal number that fits in bits. Done by hand, unlike strconv,
+// so that binding a number doesn't allocate.
+func parseBindUint(b []byte, bits int) (uint64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	// For 64 bits the shift gives 0, and max wraps around to all ones.
+	max := uint64(1)<<uint(bits) - 1
+
+	var n uint64
+
+	for _, c := range b {
+		if !isDigit(c) {
+			return 0, false
+		}
+
+		d := uint64(c - '0')
+		if n > (max-d)/10 {
+			return 0, false
+		}
+
+		n = n*10 + d
+	}
+
+	return n, true
+}
+
+// Convert value and store it in dst.
+func bindSet(dst reflect.Value, f *bindField, value []byte) error {
+	switch f.kind {
+	case bindString:
+		dst.SetString(string(value))
+	case bindBytes:
+		dst.SetBytes(value)
+	case bindInt:
+		neg := len(value) > 0 && value[0] == '-'
+		if neg || len(value) > 0 && value[0] == '+' {
+			value = value[1:]
+		}
+
+		u, ok := parseBindUint(value, f.bits)
+		limit := uint64(1) << uint(f.bits-1)
+
+		if !ok || u > limit || (!neg && u == limit) {
+			return errors.Context(ErrBindValue, string(f.name))
+		}
+
+		if neg {
+			dst.SetInt(-int64(u))
+		} else {
+			dst.SetInt(int64(u))
+		}
+	case bindUint:
+		u, ok := parseBindUint(value, f.bits)
+		if !ok {
+			return errors.Context(ErrBindValue, string(f.name))
+		}
+
+		dst.SetUint(u)
+	case bindFloat:
+		fl, err := strconv.ParseFloat(string(value), f.bits)
+		if err != nil {
+			return errors.Context(ErrBindValue, string(f.name))
+		}
+
+		dst.SetFloat(fl)
+	case bindBool:
+		switch string(value) {
+		case "1", "t", "T", "true", "TRUE", "True":
+			dst.SetBool(true)
+		case "0", "f", "F", "false", "FALSE", "False":
+			dst.SetBool(false)
+		default:
+			return errors.Context(ErrBindValue, string(f.name))
+		}
+	case bindTime:
+		t, err := http.ParseTime(string(value))
+		if err != nil {
+			return errors.Context(ErrBindValue, string(f.name))
+		}
+
+		*dst.Addr().Interface().(*time.Time) = t
+	}
+
+	return nil
+}
+
+// Fill the fields of the struct v points to from request headers, using
+// each field's `header:"Name"` tag. Fields can be strings, []byte (which
+// refer to the parser's input), integers, floats, bools or time.Time (from
+// an HTTP date). Fields whose header is missing are left alone.
+func (hp *HTTPParser) BindHeaders(v interface{}) error {
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+
+	fields, err := headerBinds.fields(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for i := range fields {
+		f := &fields[i]
+
+		var value []byte
+		if f.class != hUnknown {
+			value = hp.findHeaderClass(f.class)
+		} else {
+			value = hp.FindHeader(f.name)
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if err := bindSet(rv.Field(f.index), f, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package wildcat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+type boundHeaders struct {
+	RequestID  string    `header:"X-Request-Id"`
+	Length     int64     `header:"Content-Length"`
+	Retries    uint8     `header:"X-Retries"`
+	Offset     int       `header:"X-Offset"`
+	Debug      bool      `header:"X-Debug"`
+	Weight     float64   `header:"X-Weight"`
+	Since      time.Time `header:"If-Modified-Since"`
+	Agent      []byte    `header:"User-Agent"`
+	Missing    string    `header:"X-Missing"`
+	NotATag    string
+	unexported string `header:"X-Request-Id"`
+}
+
+func TestBindHeaders(t *testing.T) {
+	hp := parseRequest(t, "GET / HTTP/1.1\r\n"+
+		"X-Request-Id: abc\r\n"+
+		"Content-Length: 42\r\n"+
+		"X-Retries: 3\r\n"+
+		"X-Offset: -7\r\n"+
+		"X-Debug: true\r\n"+
+		"X-Weight: 0.5\r\n"+
+		"If-Modified-Since: Wed, 21 Oct 2015 07:28:00 GMT\r\n"+
+		"User-Agent: test\r\n\r\n")
+
+	v := boundHeaders{Missing: "default"}
+	require.NoError(t, hp.BindHeaders(&v))
+
+	assert.Equal(t, "abc", v.RequestID)
+	assert.Equal(t, int64(42), v.Length)
+	assert.Equal(t, uint8(3), v.Retries)
+	assert.Equal(t, -7, v.Offset)
+	assert.True(t, v.Debug)
+	assert.Equal(t, 0.5, v.Weight)
+	assert.Equal(t, time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC), v.Since)
+	assert.Equal(t, "test", string(v.Agent))
+	assert.Equal(t, "default", v.Missing)
+	assert.Equal(t, "", v.unexported)
+}
+
+func TestBindHeadersErrors(t *testing.T) {
+	hp := parseRequest(t, "GET / HTTP/1.1\r\nX-Retries: 300\r\n\r\n")
+
+	var v boundHeaders
+	assert.Equal(t, ErrBindValue, errors.Cause(hp.BindHeaders(&v)))
+
+	assert.Equal(t, ErrBindTarget, hp.BindHeaders(v))
+
+	var unsupported struct {
+		M map[string]string `header:"X-M"`
+	}
+	assert.Equal(t, ErrBindTarget, errors.Cause(hp.BindHeaders(&unsupported)))
+}
+
+func TestBindHeadersAllocs(t *testing.T) {
+	hp := parseRequest(t, "GET / HTTP/1.1\r\nContent-Length: 42\r\nX-Debug: 1\r\nX-Offset: -9223372036854775808\r\n\r\n")
+
+	var v struct {
+		Length int64  `header:"Content-Length"`
+		Debug  bool   `header:"X-Debug"`
+		Offset int    `header:"X-Offset"`
+		Agent  []byte `header:"User-Agent"`
+	}
+
+	require.NoError(t, hp.BindHeaders(&v))
+	assert.Equal(t, -9223372036854775808, v.Offset)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		hp.BindHeaders(&v)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
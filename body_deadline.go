@@ -0,0 +1,124 @@
+package wildcat
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/vektra/errors"
+)
+
+// Returned by the readers from BodyReaderContext and BodyReaderDeadline
+// when the body doesn't arrive in time.
+var ErrBodyTimeout = errors.New("body read timed out")
+
+// Sets a read deadline on the connection before each Read, so a client
+// sending its body slowly can't hold up the handler reading it.
+type deadlineConn struct {
+	net.Conn
+
+	ctx      context.Context
+	deadline time.Time
+	idle     time.Duration
+}
+
+func (dc *deadlineConn) Read(b []byte) (int, error) {
+	if dc.ctx != nil && dc.ctx.Err() != nil {
+		return 0, ErrBodyTimeout
+	}
+
+	deadline := dc.deadline
+
+	if dc.idle > 0 {
+		if d := time.Now().Add(dc.idle); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	if dc.ctx != nil {
+		if d, ok := dc.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+
+	if !setBodyReadDeadline(dc.Conn, deadline) {
+		return 0, ErrBodyTimeout
+	}
+
+	n, err := dc.Conn.Read(b)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		err = ErrBodyTimeout
+	}
+
+	return n, err
+}
+
+// Set c's read deadline, unless c is a serverConn whose request timed out:
+// expire moved its deadline to now to wake up reads, and it has to stay
+// there. Reports whether the deadline was set.
+func setBodyReadDeadline(c net.Conn, deadline time.Time) bool {
+	sc, ok := c.(*serverConn)
+	if !ok {
+		c.SetReadDeadline(deadline)
+		return true
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.timedOut {
+		return false
+	}
+
+	atomic.StoreInt32(&sc.readDeadline, 1)
+	sc.Conn.SetReadDeadline(deadline)
+	return true
+}
+
+// Clears the connection's read deadline once the body is done with.
+type deadlineBody struct {
+	io.ReadCloser
+	c net.Conn
+}
+
+func (db *deadlineBody) Read(b []byte) (int, error) {
+	n, err := db.ReadCloser.Read(b)
+	if err != nil {
+		setBodyReadDeadline(db.c, time.Time{})
+	}
+
+	return n, err
+}
+
+func (db *deadlineBody) Close() error {
+	setBodyReadDeadline(db.c, time.Time{})
+	return db.ReadCloser.Close()
+}
+
+func (hp *HTTPParser) deadlineBodyReader(rest []byte, dc *deadlineConn) io.ReadCloser {
+	body := hp.BodyReader(rest, dc)
+	if body == nil {
+		return nil
+	}
+
+	return &deadlineBody{ReadCloser: body, c: dc.Conn}
+}
+
+// Return a reader for the request body, as BodyReader does, that fails
+// with ErrBodyTimeout once ctx is done or its deadline passes, or when no
+// data arrives on c for idle (0 for no limit). Pass hp.Context() to bound
+// body reads by the server's RequestTimeout. The deadline is applied to c
+// before each Read, so it's honored by handlers that only see an
+// io.ReadCloser.
+func (hp *HTTPParser) BodyReaderContext(ctx context.Context, rest []byte, c net.Conn, idle time.Duration) io.ReadCloser {
+	return hp.deadlineBodyReader(rest, &deadlineConn{Conn: c, ctx: ctx, idle: idle})
+}
+
+// Return a reader for the request body that fails with ErrBodyTimeout if
+// the body hasn't been read by deadline, or if no data arrives on c for
+// idle (0 for no limit).
+func (hp *HTTPParser) BodyReaderDeadline(rest []byte, c net.Conn, deadline time.Time, idle time.Duration) io.ReadCloser {
+	return hp.deadlineBodyReader(rest, &deadlineConn{Conn: c, deadline: deadline, idle: idle})
+}
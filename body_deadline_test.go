@@ -0,0 +1,152 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var slowBodyHead = []byte("POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\n")
+
+func TestBodyReaderDeadlineIdle(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	hp := NewHTTPParser()
+
+	n, err := hp.Parse(append(slowBodyHead, "hel"...))
+	require.NoError(t, err)
+
+	body := hp.BodyReaderDeadline(append(slowBodyHead, "hel"...)[n:], server, time.Time{}, 20*time.Millisecond)
+
+	go client.Write([]byte("lo"))
+
+	buf := make([]byte, 10)
+
+	m, err := io.ReadAtLeast(body, buf, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:m]))
+
+	// The client never sends the rest.
+	_, err = body.Read(buf)
+	assert.Equal(t, ErrBodyTimeout, err)
+
+	// The deadline doesn't outlive the body.
+	go client.Write([]byte("x"))
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = server.Read(buf)
+	assert.NoError(t, err)
+}
+
+func TestBodyReaderContext(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	hp := NewHTTPParser()
+
+	n, err := hp.Parse(slowBodyHead)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	body := hp.BodyReaderContext(ctx, slowBodyHead[n:], server, 0)
+
+	start := time.Now()
+	_, err = body.Read(make([]byte, 10))
+	assert.Equal(t, ErrBodyTimeout, err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// Once ctx is done reads fail straight away.
+	_, err = body.Read(make([]byte, 10))
+	assert.Equal(t, ErrBodyTimeout, err)
+}
+
+func TestServerClearsBodyDeadline(t *testing.T) {
+	s := &Server{Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+		// Read exactly the body, so the reader never sees EOF and clears
+		// the deadline itself.
+		if hp.ContentLength() > 0 {
+			body := hp.BodyReaderDeadline(rest, c, time.Now().Add(50*time.Millisecond), 0)
+			if _, err := io.ReadFull(body, make([]byte, 2)); err != nil {
+				return
+			}
+		}
+
+		helloHandler(hp, rest, c)
+	})}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+
+	_, err = c.Write([]byte("POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 2\r\n\r\n"))
+	require.NoError(t, err)
+
+	// Send the body separately so it's read through the deadline.
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = c.Write([]byte("ab"))
+	require.NoError(t, err)
+
+	assert.Contains(t, readResponse(t, r), "200 OK")
+	_, err = io.ReadFull(r, make([]byte, 5))
+	require.NoError(t, err)
+
+	// Well past the body's deadline, the connection still takes requests.
+	time.Sleep(80 * time.Millisecond)
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Contains(t, readResponse(t, r), "200 OK")
+}
+
+func TestBodyReaderDeadlineRequestTimeout(t *testing.T) {
+	done := make(chan error, 1)
+
+	s := &Server{
+		RequestTimeout: 30 * time.Millisecond,
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			// Only start reading once the request has timed out; a later
+			// deadline mustn't undo the timeout.
+			<-hp.Context().Done()
+
+			body := hp.BodyReaderDeadline(rest, c, time.Now().Add(5*time.Second), 0)
+
+			_, err := io.ReadFull(body, make([]byte, 10))
+			done <- err
+		}),
+	}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write(slowBodyHead)
+	require.NoError(t, err)
+
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrBodyTimeout, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("body read outlived the request timeout")
+	}
+
+	assert.Contains(t, readResponse(t, bufio.NewReader(c)), "503 Service Unavailable")
+}
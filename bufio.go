@@ -1,11 +1,38 @@
 package wildcat
 
-import "io"
+import (
+	"io"
+
+	"github.com/vektra/errors"
+)
+
+// Returned when the connection ends before the number of body bytes given
+// by Content-Length have been read, or when bytes that can't be the start
+// of a request follow the body on a keep-alive connection. Either way the
+// connection is out of sync and must be closed.
+var ErrBodyLengthMismatch = errors.New("body length does not match Content-Length")
 
 type sizedBodyReader struct {
-	size int64
-	rest []byte
-	c    io.ReadCloser
+	size  int64
+	rest  []byte
+	extra []byte
+	c     io.ReadCloser
+}
+
+func newSizedBodyReader(size int64, rest []byte, c io.ReadCloser) *sizedBodyReader {
+	br := &sizedBodyReader{size: size, rest: rest, c: c}
+
+	// Anything past the body belongs to the next request.
+	if int64(len(rest)) > size {
+		br.rest = rest[:size]
+		br.extra = rest[size:]
+	}
+
+	if len(br.rest) == 0 {
+		br.rest = nil
+	}
+
+	return br
 }
 
 func (br *sizedBodyReader) Read(buf []byte) (int, error) {
@@ -30,13 +57,30 @@ func (br *sizedBodyReader) Read(buf []byte) (int, error) {
 		}
 	}
 
-	n, err := br.c.Read(buf[:br.size])
-	if err != nil {
-		return 0, err
+	if int64(len(buf)) > br.size {
+		buf = buf[:br.size]
 	}
 
+	n, err := br.c.Read(buf)
 	br.size -= int64(n)
-	return n, nil
+
+	if err == io.EOF {
+		if br.size > 0 {
+			return n, ErrBodyLengthMismatch
+		}
+
+		if n > 0 {
+			err = nil
+		}
+	}
+
+	return n, err
+}
+
+// Return the bytes read past the end of the body, and whether the body was
+// read completely so they can be used.
+func (br *sizedBodyReader) leftover() ([]byte, bool) {
+	return br.extra, br.size == 0
 }
 
 func (br *sizedBodyReader) Close() error {
@@ -71,13 +115,67 @@ func (br *unsizedBodyReader) Close() error {
 	return br.c.Close()
 }
 
+// Return a reader for a body of size bytes, or one that reads to the end
+// of c if size is -1. Other negative sizes give a reader that fails with
+// ErrBadContentLength.
 func BodyReader(size int64, rest []byte, c io.ReadCloser) io.ReadCloser {
-	switch size {
-	case 0:
+	switch {
+	case size == 0:
 		return nil
-	case -1:
+	case size == -1:
 		return &unsizedBodyReader{rest, c}
+	case size < 0:
+		return badBodyReader{c}
 	default:
-		return &sizedBodyReader{size, rest, c}
+		return newSizedBodyReader(size, rest, c)
 	}
 }
+
+type badBodyReader struct {
+	c io.ReadCloser
+}
+
+func (br badBodyReader) Read(buf []byte) (int, error) {
+	return 0, ErrBadContentLength
+}
+
+func (br badBodyReader) Close() error {
+	return br.c.Close()
+}
+
+// Skip the empty lines RFC 9112 allows before a request line and check that
+// what follows could be the start of one: a method token followed by a
+// space, or a prefix of that. Returns ErrBodyLengthMismatch otherwise, as
+// the usual cause is a body longer than its Content-Length.
+func skipToRequestLine(b []byte) ([]byte, error) {
+	for len(b) > 0 && (b[0] == '\r' || b[0] == '\n') {
+		b = b[1:]
+	}
+
+	for i, c := range b {
+		if c == ' ' && i > 0 {
+			return b, nil
+		}
+
+		if !isTokenChar(c) {
+			return nil, ErrBodyLengthMismatch
+		}
+	}
+
+	return b, nil
+}
+
+// Report whether c is a tchar, as used in methods and header names.
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	switch c {
+	case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '^', '_', '`', '|', '~':
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package wildcat
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizedBodyReaderEarlyEOF(t *testing.T) {
+	br := BodyReader(10, []byte("hel"), io.NopCloser(strings.NewReader("lo")))
+
+	body, err := io.ReadAll(br)
+	assert.Equal(t, ErrBodyLengthMismatch, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestSizedBodyReaderSmallBuffer(t *testing.T) {
+	br := BodyReader(5, nil, io.NopCloser(strings.NewReader("helloGET")))
+
+	buf := make([]byte, 2)
+
+	var body []byte
+
+	for {
+		n, err := br.Read(buf)
+		body = append(body, buf[:n]...)
+
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestSizedBodyReaderLeftover(t *testing.T) {
+	br := BodyReader(5, []byte("helloGET /"), nil)
+
+	body, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	extra, ok := br.(*sizedBodyReader).leftover()
+	assert.True(t, ok)
+	assert.Equal(t, "GET /", string(extra))
+}
+
+func TestSkipToRequestLine(t *testing.T) {
+	next, err := skipToRequestLine([]byte("\r\nGET / HTTP/1.1\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\n", string(next))
+
+	next, err = skipToRequestLine([]byte("PO"))
+	require.NoError(t, err)
+	assert.Equal(t, "PO", string(next))
+
+	_, err = skipToRequestLine([]byte("lo, world"))
+	assert.Equal(t, ErrBodyLengthMismatch, err)
+
+	_, err = skipToRequestLine([]byte(" GET /"))
+	assert.Equal(t, ErrBodyLengthMismatch, err)
+}
+
+func TestBodyReaderNegativeSize(t *testing.T) {
+	br := BodyReader(-5, []byte("hello"), io.NopCloser(strings.NewReader("")))
+
+	_, err := io.ReadAll(br)
+	assert.Equal(t, ErrBadContentLength, err)
+}
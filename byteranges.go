@@ -0,0 +1,188 @@
+package wildcat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// The most ranges served as multipart/byteranges. Requests for more, after
+// overlapping ranges are merged, get the whole representation instead, as
+// many small ranges cost far more to send than they save.
+const MaxByteRanges = 32
+
+var (
+	cMultipartByteranges = []byte("multipart/byteranges; boundary=")
+	cDashDash            = []byte("--")
+	cContentRangeHeader  = []byte("Content-Range: ")
+	cContentTypeHeader   = []byte("Content-Type: ")
+	cContentLengthHeader = []byte("Content-Length: ")
+)
+
+// Sort ranges and merge those that overlap or touch.
+func coalesceRanges(ranges []ByteRange) []ByteRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := append([]ByteRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	out := sorted[:1]
+
+	for _, r := range sorted[1:] {
+		last := &out[len(out)-1]
+
+		if end := last.Start + last.Length; r.Start <= end {
+			if rend := r.Start + r.Length; rend > end {
+				last.Length = rend - last.Start
+			}
+
+			continue
+		}
+
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// byteranges lays out a multipart/byteranges body (RFC 9110, section
+// 14.6) so its length is known before it's written.
+type byteranges struct {
+	boundary    string
+	contentType []byte
+	size        int64
+	ranges      []ByteRange
+}
+
+func newBoundary() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Append the delimiter and headers that precede part i.
+func (br *byteranges) appendPartHeader(dst []byte, i int) []byte {
+	if i > 0 {
+		dst = append(dst, cCRLF...)
+	}
+
+	dst = append(dst, cDashDash...)
+	dst = append(dst, br.boundary...)
+	dst = append(dst, cCRLF...)
+
+	if br.contentType != nil {
+		dst = append(dst, cContentTypeHeader...)
+		dst = append(dst, br.contentType...)
+		dst = append(dst, cCRLF...)
+	}
+
+	dst = append(dst, cContentRangeHeader...)
+	dst = br.ranges[i].AppendContentRange(dst, br.size)
+	dst = append(dst, cCRLF...)
+
+	return append(dst, cCRLF...)
+}
+
+// Append the final delimiter.
+func (br *byteranges) appendClose(dst []byte) []byte {
+	dst = append(dst, cCRLF...)
+	dst = append(dst, cDashDash...)
+	dst = append(dst, br.boundary...)
+	dst = append(dst, cDashDash...)
+	return append(dst, cCRLF...)
+}
+
+func (br *byteranges) contentLength() int64 {
+	var buf []byte
+
+	n := int64(len(br.appendClose(buf[:0])))
+
+	for i, r := range br.ranges {
+		buf = br.appendPartHeader(buf[:0], i)
+		n += int64(len(buf)) + r.Length
+	}
+
+	return n
+}
+
+// Send the given ranges of f, which is size bytes long, as a 206 with a
+// multipart/byteranges body. A Content-Type added beforehand is moved into
+// each part. Overlapping ranges are merged; if a single range remains it's
+// sent on its own, and if more than MaxByteRanges remain the whole file is
+// sent with a 200. Without any ranges, a 416 is sent.
+func (r *Response) SendFileRanges(f *os.File, ranges []ByteRange, size int64) error {
+	ranges = coalesceRanges(ranges)
+
+	if len(ranges) == 0 {
+		r.writeUnsatisfiedRange(size)
+		return nil
+	}
+
+	if len(ranges) > MaxByteRanges {
+		r.WriteStatus(StatusOK)
+		r.WriteHeaders()
+		return r.SendFile(f, 0, size)
+	}
+
+	if len(ranges) == 1 {
+		r.AddHeader(cContentRange, ranges[0].AppendContentRange(nil, size))
+		r.WriteStatus(StatusPartialContent)
+		r.WriteHeaders()
+		return r.SendFile(f, ranges[0].Start, ranges[0].Length)
+	}
+
+	br := &byteranges{boundary: newBoundary(), size: size, ranges: ranges}
+
+	ct := append(append([]byte(nil), cMultipartByteranges...), br.boundary...)
+
+	replaced := false
+
+	for i := 0; i < r.numHeaders; i++ {
+		if bytes.EqualFold(r.headers[i].Name, cContentType) {
+			br.contentType = r.headers[i].Value
+			r.headers[i].Value = ct
+			replaced = true
+			break
+		}
+	}
+
+	if !replaced {
+		r.AddHeader(cContentType, ct)
+	}
+
+	r.WriteStatus(StatusPartialContent)
+	r.WriteHeaders()
+
+	hdr := append([]byte(nil), cContentLengthHeader...)
+	hdr = strconv.AppendInt(hdr, br.contentLength(), 10)
+	hdr = append(hdr, cCRLF...)
+	hdr = append(hdr, cCRLF...)
+
+	var buf []byte
+
+	for i, rng := range ranges {
+		buf = br.appendPartHeader(hdr, i)
+		hdr = nil
+
+		if _, err := r.c.Write(buf); err != nil {
+			return err
+		}
+
+		if _, err := f.Seek(rng.Start, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(r.c, &io.LimitedReader{R: f, N: rng.Length}); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.c.Write(br.appendClose(nil))
+	return err
+}
@@ -0,0 +1,655 @@
+package wildcat
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The directives of a Cache-Control header (RFC 9111, section 5.2) that
+// affect caching. Ages are in seconds, -1 when absent.
+type CacheControl struct {
+	MaxAge         int
+	SMaxAge        int
+	MaxStale       int
+	MinFresh       int
+	NoCache        bool
+	NoStore        bool
+	Private        bool
+	Public         bool
+	MustRevalidate bool
+	OnlyIfCached   bool
+}
+
+var (
+	cMaxAge         = []byte("max-age")
+	cSMaxAge        = []byte("s-maxage")
+	cMaxStale       = []byte("max-stale")
+	cMinFresh       = []byte("min-fresh")
+	cNoCache        = []byte("no-cache")
+	cNoStore        = []byte("no-store")
+	cPrivate        = []byte("private")
+	cPublic         = []byte("public")
+	cMustRevalidate = []byte("must-revalidate")
+	cProxyRevalid   = []byte("proxy-revalidate")
+	cOnlyIfCached   = []byte("only-if-cached")
+)
+
+// Parse a Cache-Control value. Unknown directives are ignored; a malformed
+// age counts as 0, so that it errs on the side of revalidating.
+func ParseCacheControl(value []byte) CacheControl {
+	cc := CacheControl{MaxAge: -1, SMaxAge: -1, MaxStale: -1, MinFresh: -1}
+
+	for list := value; len(list) > 0; {
+		var item []byte
+		item, list = nextListItem(list)
+
+		name, arg := item, []byte(nil)
+		if eq := bytes.IndexByte(item, '='); eq != -1 {
+			name, arg = bytes.TrimSpace(item[:eq]), bytes.Trim(bytes.TrimSpace(item[eq+1:]), `"`)
+		}
+
+		switch {
+		case equalFoldASCII(name, cMaxAge):
+			cc.MaxAge = parseDeltaSeconds(arg)
+		case equalFoldASCII(name, cSMaxAge):
+			cc.SMaxAge = parseDeltaSeconds(arg)
+		case equalFoldASCII(name, cMaxStale):
+			// Without a value, any staleness is acceptable.
+			if arg == nil {
+				cc.MaxStale = 1<<31 - 1
+			} else {
+				cc.MaxStale = parseDeltaSeconds(arg)
+			}
+		case equalFoldASCII(name, cMinFresh):
+			cc.MinFresh = parseDeltaSeconds(arg)
+		case equalFoldASCII(name, cNoCache):
+			cc.NoCache = true
+		case equalFoldASCII(name, cNoStore):
+			cc.NoStore = true
+		case equalFoldASCII(name, cPrivate):
+			cc.Private = true
+		case equalFoldASCII(name, cPublic):
+			cc.Public = true
+		case equalFoldASCII(name, cMustRevalidate), equalFoldASCII(name, cProxyRevalid):
+			cc.MustRevalidate = true
+		case equalFoldASCII(name, cOnlyIfCached):
+			cc.OnlyIfCached = true
+		}
+	}
+
+	return cc
+}
+
+func parseDeltaSeconds(b []byte) int {
+	v, err := strconv.ParseUint(string(b), 10, 31)
+	if err != nil {
+		if len(b) > 0 && err.(*strconv.NumError).Err == strconv.ErrRange {
+			return 1<<31 - 1
+		}
+
+		return 0
+	}
+
+	return int(v)
+}
+
+// A response held by a Cache.
+type CachedResponse struct {
+	Status int
+
+	// The header block, "Name: value\r\n" lines without the status line or
+	// the final blank line.
+	Header []byte
+	Body   []byte
+
+	ETag         []byte
+	LastModified []byte
+
+	// When the response was received, its age at that point, and how long
+	// it stays fresh.
+	Stored   time.Time
+	Age      time.Duration
+	Lifetime time.Duration
+
+	NoCache        bool
+	MustRevalidate bool
+
+	// The headers named by Vary, and their values in the request that got
+	// this response.
+	vary [][2][]byte
+}
+
+// Return the response's current age.
+func (cr *CachedResponse) CurrentAge(now time.Time) time.Duration {
+	return cr.Age + now.Sub(cr.Stored)
+}
+
+var (
+	cCacheControl = []byte("Cache-Control")
+	cExpires      = []byte("Expires")
+	cDate         = []byte("Date")
+	cAge          = []byte("Age")
+	cVary         = []byte("Vary")
+	cPragma       = []byte("Pragma")
+	cStar         = []byte("*")
+)
+
+// Return the next "Name: value" line in a header block.
+func nextHeaderLine(block []byte) (name, value, rest []byte, ok bool) {
+	end := bytes.Index(block, cCRLF)
+	if end == -1 {
+		end = len(block)
+		rest = nil
+	} else {
+		rest = block[end+2:]
+	}
+
+	line := block[:end]
+
+	colon := bytes.IndexByte(line, ':')
+	if colon <= 0 {
+		return nil, nil, rest, len(block) > 0
+	}
+
+	return line[:colon], bytes.TrimSpace(line[colon+1:]), rest, true
+}
+
+// Return the value of the first header named name in a header block.
+func findHeaderInBlock(block, name []byte) []byte {
+	for len(block) > 0 {
+		n, v, rest, ok := nextHeaderLine(block)
+		if !ok {
+			break
+		}
+
+		if n != nil && equalFoldASCII(n, name) {
+			return v
+		}
+
+		block = rest
+	}
+
+	return nil
+}
+
+// Statuses that can be cached without explicit freshness (RFC 9110,
+// section 15.1).
+func heuristicallyCacheable(status int) bool {
+	switch status {
+	case 200, 203, 204, 206, 300, 301, 308, 404, 405, 410, 414, 501:
+		return true
+	}
+
+	return false
+}
+
+// A CacheStore holds cached responses by key. MemoryCache is the built-in
+// one; others can keep them in a shared store.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, cr *CachedResponse)
+	Delete(key string)
+}
+
+// The result of looking up a request in a Cache.
+const (
+	CacheMiss = iota
+	CacheHit
+	CacheStale
+)
+
+// Cache implements the RFC 9111 rules for storing responses and serving
+// requests from a CacheStore. It doesn't make requests itself: on a stale
+// result the caller revalidates with AppendConditional and then calls
+// Refresh or Put with the response.
+type Cache struct {
+	Store CacheStore
+
+	// A shared cache (such as a proxy) honors s-maxage and doesn't store
+	// private responses or responses to requests with Authorization.
+	Shared bool
+
+	now func() time.Time
+}
+
+func NewCache(store CacheStore, shared bool) *Cache {
+	return &Cache{Store: store, Shared: shared, now: time.Now}
+}
+
+// The current time, from now if it's set so tests can control it.
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+
+	return time.Now()
+}
+
+func cacheKey(hp *HTTPParser) string {
+	return string(hp.Method) + " " + string(hp.Host()) + string(hp.Path)
+}
+
+func cacheableMethod(hp *HTTPParser) bool {
+	return bytes.Equal(hp.Method, cGet) || bytes.Equal(hp.Method, cHead)
+}
+
+// Look up the response for hp. A CacheStale response must be revalidated
+// before it's used.
+func (c *Cache) Lookup(hp *HTTPParser) (*CachedResponse, int) {
+	if !cacheableMethod(hp) {
+		return nil, CacheMiss
+	}
+
+	req := ParseCacheControl(hp.findHeaderClass(hCacheControl))
+	if req.NoStore {
+		return nil, CacheMiss
+	}
+
+	cr, ok := c.Store.Get(cacheKey(hp))
+	if !ok {
+		return nil, CacheMiss
+	}
+
+	for _, v := range cr.vary {
+		if !bytes.Equal(hp.FindHeader(v[0]), v[1]) {
+			return nil, CacheMiss
+		}
+	}
+
+	if req.NoCache || cr.NoCache || bytes.Equal(hp.FindHeader(cPragma), cNoCache) {
+		return cr, CacheStale
+	}
+
+	age := cr.CurrentAge(c.clock())
+	lifetime := cr.Lifetime
+
+	if req.MaxAge >= 0 && time.Duration(req.MaxAge)*time.Second < lifetime {
+		lifetime = time.Duration(req.MaxAge) * time.Second
+	}
+
+	if req.MinFresh >= 0 {
+		age += time.Duration(req.MinFresh) * time.Second
+	}
+
+	if age < lifetime {
+		return cr, CacheHit
+	}
+
+	if req.MaxStale >= 0 && !cr.MustRevalidate && age-lifetime < time.Duration(req.MaxStale)*time.Second {
+		return cr, CacheHit
+	}
+
+	return cr, CacheStale
+}
+
+// Store the response to hp if the rules allow it, reporting whether it was
+// stored. header is the response's header block.
+func (c *Cache) Put(hp *HTTPParser, status int, header, body []byte) bool {
+	// Entries are keyed by the request without its Range, so a partial
+	// response can't be stored as if it were the whole representation.
+	if !cacheableMethod(hp) || status == StatusPartialContent {
+		return false
+	}
+
+	req := ParseCacheControl(hp.findHeaderClass(hCacheControl))
+	resp := ParseCacheControl(findHeaderInBlock(header, cCacheControl))
+
+	if req.NoStore || resp.NoStore || (c.Shared && resp.Private) {
+		return false
+	}
+
+	if c.Shared && hp.findHeaderClass(hAuthorization) != nil &&
+		!resp.Public && !resp.MustRevalidate && resp.SMaxAge < 0 {
+		return false
+	}
+
+	vary := findHeaderInBlock(header, cVary)
+	if bytes.Equal(bytes.TrimSpace(vary), cStar) {
+		return false
+	}
+
+	now := c.clock()
+
+	cr := &CachedResponse{
+		Status:         status,
+		Header:         stripHopByHop(header),
+		Body:           append([]byte(nil), body...),
+		Stored:         now,
+		NoCache:        resp.NoCache,
+		MustRevalidate: resp.MustRevalidate,
+	}
+
+	cr.ETag = findHeaderInBlock(cr.Header, cETag)
+	cr.LastModified = findHeaderInBlock(cr.Header, cLastModified)
+
+	lifetime, explicit := c.lifetime(resp, cr.Header, now)
+	if !explicit && !heuristicallyCacheable(status) {
+		return false
+	}
+
+	cr.Lifetime = lifetime
+
+	if age := findHeaderInBlock(cr.Header, cAge); age != nil {
+		cr.Age = time.Duration(parseDeltaSeconds(age)) * time.Second
+	}
+
+	for list := vary; len(list) > 0; {
+		var name []byte
+		name, list = nextListItem(list)
+
+		if len(name) > 0 {
+			cr.vary = append(cr.vary, [2][]byte{name, append([]byte(nil), hp.FindHeader(name)...)})
+		}
+	}
+
+	c.Store.Set(cacheKey(hp), cr)
+	return true
+}
+
+// Work out the freshness lifetime of a response and whether it was given
+// explicitly. Without one, 10% of the time since Last-Modified is used.
+func (c *Cache) lifetime(cc CacheControl, header []byte, now time.Time) (time.Duration, bool) {
+	if c.Shared && cc.SMaxAge >= 0 {
+		return time.Duration(cc.SMaxAge) * time.Second, true
+	}
+
+	if cc.MaxAge >= 0 {
+		return time.Duration(cc.MaxAge) * time.Second, true
+	}
+
+	date := now
+	if d, err := http.ParseTime(string(findHeaderInBlock(header, cDate))); err == nil {
+		date = d
+	}
+
+	if exp := findHeaderInBlock(header, cExpires); exp != nil {
+		t, err := http.ParseTime(string(exp))
+		if err != nil || !t.After(date) {
+			return 0, true
+		}
+
+		return t.Sub(date), true
+	}
+
+	if lm, err := http.ParseTime(string(findHeaderInBlock(header, cLastModified))); err == nil && date.After(lm) {
+		return date.Sub(lm) / 10, false
+	}
+
+	return 0, false
+}
+
+// Update a stale response after the server answered its revalidation with
+// a 304; header is the 304's header block. Its fields replace the stored
+// ones, so new validators and freshness information take effect (RFC 9111,
+// section 4.3.4).
+func (c *Cache) Refresh(hp *HTTPParser, cr *CachedResponse, header []byte) {
+	now := c.clock()
+
+	fresh := *cr
+	fresh.Header = updateHeaderBlock(stripHopByHop(cr.Header), stripHopByHop(header))
+	fresh.Stored = now
+	fresh.Age = 0
+
+	if age := findHeaderInBlock(header, cAge); age != nil {
+		fresh.Age = time.Duration(parseDeltaSeconds(age)) * time.Second
+	}
+
+	resp := ParseCacheControl(findHeaderInBlock(fresh.Header, cCacheControl))
+	if resp.NoStore {
+		c.Store.Delete(cacheKey(hp))
+		return
+	}
+
+	fresh.ETag = findHeaderInBlock(fresh.Header, cETag)
+	fresh.LastModified = findHeaderInBlock(fresh.Header, cLastModified)
+	fresh.Lifetime, _ = c.lifetime(resp, fresh.Header, now)
+	fresh.NoCache = resp.NoCache
+	fresh.MustRevalidate = resp.MustRevalidate
+
+	c.Store.Set(cacheKey(hp), &fresh)
+}
+
+// Whether a 304 leaves the stored value of a header alone: Content-Length
+// describes the stored body rather than the 304's (RFC 9111, section 3.2).
+func keepStoredHeader(name []byte) bool {
+	return lookupHeaderClass(name) == hContentLength
+}
+
+var (
+	cKeepAlive       = []byte("Keep-Alive")
+	cProxyConnection = []byte("Proxy-Connection")
+)
+
+// Whether a header only applies to the connection a response came on, so
+// it must not be stored (RFC 9111, section 3.1). connection is the value
+// of the response's Connection header, which can name more of them.
+func hopByHopHeader(name, connection []byte) bool {
+	switch lookupHeaderClass(name) {
+	case hConnection, hTransferEncoding, hTe, hUpgrade:
+		return true
+	}
+
+	if equalFoldASCII(name, cKeepAlive) || equalFoldASCII(name, cProxyConnection) {
+		return true
+	}
+
+	for list := connection; len(list) > 0; {
+		var item []byte
+		item, list = nextListItem(list)
+
+		if equalFoldASCII(item, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return a copy of a header block without its hop-by-hop headers.
+func stripHopByHop(block []byte) []byte {
+	connection := findHeaderInBlock(block, headerClassNames[hConnection])
+
+	out := make([]byte, 0, len(block))
+
+	for len(block) > 0 {
+		name, _, rest, ok := nextHeaderLine(block)
+		if !ok {
+			break
+		}
+
+		line := block[:len(block)-len(rest)]
+		block = rest
+
+		if name == nil || hopByHopHeader(name, connection) {
+			continue
+		}
+
+		out = appendHeaderBlockLine(out, line)
+	}
+
+	return out
+}
+
+// Return a new header block with the lines of stored, less those replaced
+// by a field of the same name in update, followed by those of update.
+func updateHeaderBlock(stored, update []byte) []byte {
+	var out []byte
+
+	for block := stored; len(block) > 0; {
+		name, _, rest, ok := nextHeaderLine(block)
+		if !ok {
+			break
+		}
+
+		line := block[:len(block)-len(rest)]
+		block = rest
+
+		if name == nil || (!keepStoredHeader(name) && findHeaderInBlock(update, name) != nil) {
+			continue
+		}
+
+		out = appendHeaderBlockLine(out, line)
+	}
+
+	for block := update; len(block) > 0; {
+		name, _, rest, ok := nextHeaderLine(block)
+		if !ok {
+			break
+		}
+
+		line := block[:len(block)-len(rest)]
+		block = rest
+
+		if name == nil || keepStoredHeader(name) {
+			continue
+		}
+
+		out = appendHeaderBlockLine(out, line)
+	}
+
+	return out
+}
+
+func appendHeaderBlockLine(dst, line []byte) []byte {
+	dst = append(dst, line...)
+	if !bytes.HasSuffix(line, cCRLF) {
+		dst = append(dst, cCRLF...)
+	}
+
+	return dst
+}
+
+// Append the If-None-Match and If-Modified-Since header lines to send when
+// revalidating cr.
+func (cr *CachedResponse) AppendConditional(dst []byte) []byte {
+	if cr.ETag != nil {
+		dst = append(dst, cIfNoneMatchHeader...)
+		dst = append(dst, cColon...)
+		dst = append(dst, cr.ETag...)
+		dst = append(dst, cCRLF...)
+	}
+
+	if cr.LastModified != nil {
+		dst = append(dst, cIfModifiedSinceHeader...)
+		dst = append(dst, cColon...)
+		dst = append(dst, cr.LastModified...)
+		dst = append(dst, cCRLF...)
+	}
+
+	return dst
+}
+
+var (
+	cIfNoneMatchHeader     = []byte("If-None-Match")
+	cIfModifiedSinceHeader = []byte("If-Modified-Since")
+)
+
+// Write cr as a complete response, with its current Age.
+func (cr *CachedResponse) WriteTo(w io.Writer, now time.Time) error {
+	buf := appendStatusLine(nil, cr.Status)
+
+	connection := findHeaderInBlock(cr.Header, headerClassNames[hConnection])
+
+	for block := cr.Header; len(block) > 0; {
+		name, _, rest, ok := nextHeaderLine(block)
+		if !ok {
+			break
+		}
+
+		line := block[:len(block)-len(rest)]
+		block = rest
+
+		// Age and Content-Length are written below. Hop-by-hop headers
+		// are left out in case a CacheStore was given them directly.
+		if name == nil || equalFoldASCII(name, cAge) || equalFoldASCII(name, headerClassNames[hContentLength]) ||
+			hopByHopHeader(name, connection) {
+			continue
+		}
+
+		buf = appendHeaderBlockLine(buf, line)
+	}
+
+	buf = append(buf, cAge...)
+	buf = append(buf, cColon...)
+	buf = strconv.AppendInt(buf, int64(cr.CurrentAge(now)/time.Second), 10)
+	buf = append(buf, cCRLF...)
+
+	buf = append(buf, "Content-Length: "...)
+	buf = strconv.AppendInt(buf, int64(len(cr.Body)), 10)
+	buf = append(buf, cCRLF...)
+	buf = append(buf, cCRLF...)
+	buf = append(buf, cr.Body...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// MemoryCache is a CacheStore keeping up to MaxEntries responses in memory,
+// evicting the least recently used.
+type MemoryCache struct {
+	MaxEntries int
+
+	mu      sync.Mutex
+	lru     list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key string
+	cr  *CachedResponse
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{MaxEntries: maxEntries, entries: make(map[string]*list.Element)}
+}
+
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	m.lru.MoveToFront(e)
+	return e.Value.(*memoryEntry).cr, true
+}
+
+func (m *MemoryCache) Set(key string, cr *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.Value.(*memoryEntry).cr = cr
+		m.lru.MoveToFront(e)
+		return
+	}
+
+	if m.entries == nil {
+		m.entries = make(map[string]*list.Element)
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoryEntry{key, cr})
+
+	for m.MaxEntries > 0 && m.lru.Len() > m.MaxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		m.lru.Remove(e)
+		delete(m.entries, key)
+	}
+}
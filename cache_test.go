@@ -0,0 +1,198 @@
+package wildcat
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseRequest(t *testing.T, req string) *HTTPParser {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte(req))
+	require.NoError(t, err)
+
+	return hp
+}
+
+func testCache(shared bool) (*Cache, *time.Time) {
+	now := time.Unix(1700000000, 0)
+
+	c := NewCache(NewMemoryCache(10), shared)
+	c.now = func() time.Time { return now }
+
+	return c, &now
+}
+
+func TestParseCacheControl(t *testing.T) {
+	cc := ParseCacheControl([]byte(`public, max-age=60, s-maxage="120", no-cache="Set-Cookie", max-stale`))
+
+	assert.True(t, cc.Public)
+	assert.True(t, cc.NoCache)
+	assert.Equal(t, 60, cc.MaxAge)
+	assert.Equal(t, 120, cc.SMaxAge)
+	assert.True(t, cc.MaxStale > 0)
+	assert.Equal(t, -1, cc.MinFresh)
+
+	assert.Equal(t, 0, ParseCacheControl([]byte("max-age=abc")).MaxAge)
+}
+
+func TestCacheFreshness(t *testing.T) {
+	c, now := testCache(false)
+
+	hp := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	ok := c.Put(hp, 200, []byte("Cache-Control: max-age=60\r\nETag: \"v1\"\r\n"), []byte("hello"))
+	require.True(t, ok)
+
+	cr, res := c.Lookup(hp)
+	assert.Equal(t, CacheHit, res)
+	assert.Equal(t, []byte(`"v1"`), cr.ETag)
+
+	*now = now.Add(61 * time.Second)
+
+	cr, res = c.Lookup(hp)
+	assert.Equal(t, CacheStale, res)
+	assert.Equal(t, "If-None-Match: \"v1\"\r\n", string(cr.AppendConditional(nil)))
+
+	c.Refresh(hp, cr, []byte("Cache-Control: max-age=60\r\n"))
+
+	_, res = c.Lookup(hp)
+	assert.Equal(t, CacheHit, res)
+
+	// The request can ask for something fresher.
+	_, res = c.Lookup(parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\nCache-Control: no-cache\r\n\r\n"))
+	assert.Equal(t, CacheStale, res)
+}
+
+func TestCacheRefreshUpdatesHeaders(t *testing.T) {
+	c, now := testCache(false)
+
+	hp := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	require.True(t, c.Put(hp, 200, []byte("Cache-Control: max-age=60\r\nETag: \"v1\"\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n"), []byte("hello")))
+
+	*now = now.Add(61 * time.Second)
+
+	cr, res := c.Lookup(hp)
+	require.Equal(t, CacheStale, res)
+
+	c.Refresh(hp, cr, []byte("Cache-Control: max-age=120, must-revalidate\r\nETag: \"v2\"\r\nContent-Length: 0\r\n"))
+
+	*now = now.Add(90 * time.Second)
+
+	cr, res = c.Lookup(hp)
+	assert.Equal(t, CacheHit, res)
+	assert.Equal(t, `"v2"`, string(cr.ETag))
+	assert.True(t, cr.MustRevalidate)
+	assert.Equal(t, "Content-Type: text/plain\r\nContent-Length: 5\r\nCache-Control: max-age=120, must-revalidate\r\nETag: \"v2\"\r\n", string(cr.Header))
+	assert.Equal(t, "If-None-Match: \"v2\"\r\n", string(cr.AppendConditional(nil)))
+}
+
+func TestCacheNotStorable(t *testing.T) {
+	c, _ := testCache(true)
+
+	hp := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	assert.False(t, c.Put(hp, 200, []byte("Cache-Control: no-store\r\n"), nil))
+	assert.False(t, c.Put(hp, 200, []byte("Cache-Control: private, max-age=60\r\n"), nil))
+	assert.False(t, c.Put(hp, 200, []byte("Cache-Control: max-age=60\r\nVary: *\r\n"), nil))
+	assert.False(t, c.Put(hp, 500, nil, nil))
+	assert.False(t, c.Put(hp, 206, []byte("Cache-Control: max-age=60\r\nContent-Range: bytes 0-4/10\r\n"), nil))
+
+	auth := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\nAuthorization: Basic eA==\r\n\r\n")
+	assert.False(t, c.Put(auth, 200, []byte("Cache-Control: max-age=60\r\n"), nil))
+	assert.True(t, c.Put(auth, 200, []byte("Cache-Control: s-maxage=60\r\n"), nil))
+
+	post := parseRequest(t, "POST /a HTTP/1.1\r\nHost: x\r\n\r\n")
+	assert.False(t, c.Put(post, 200, []byte("Cache-Control: max-age=60\r\n"), nil))
+}
+
+func TestCacheVary(t *testing.T) {
+	c, _ := testCache(false)
+
+	gz := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\nAccept-Encoding: gzip\r\n\r\n")
+	require.True(t, c.Put(gz, 200, []byte("Cache-Control: max-age=60\r\nVary: Accept-Encoding\r\n"), nil))
+
+	_, res := c.Lookup(gz)
+	assert.Equal(t, CacheHit, res)
+
+	_, res = c.Lookup(parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n"))
+	assert.Equal(t, CacheMiss, res)
+}
+
+func TestCachedResponseWriteTo(t *testing.T) {
+	c, now := testCache(false)
+
+	hp := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.True(t, c.Put(hp, 200, []byte("Cache-Control: max-age=60\r\nContent-Length: 5\r\nAge: 3\r\n"), []byte("hello")))
+
+	*now = now.Add(10 * time.Second)
+
+	cr, _ := c.Lookup(hp)
+
+	var buf bytes.Buffer
+	require.NoError(t, cr.WriteTo(&buf, *now))
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nCache-Control: max-age=60\r\nAge: 13\r\nContent-Length: 5\r\n\r\nhello", buf.String())
+}
+
+func TestCacheStripsHopByHopHeaders(t *testing.T) {
+	c, now := testCache(false)
+
+	hp := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.True(t, c.Put(hp, 200, []byte("Cache-Control: max-age=60\r\nTransfer-Encoding: chunked\r\n"+
+		"Connection: keep-alive, X-Hop\r\nKeep-Alive: timeout=5\r\nX-Hop: 1\r\nX-End: 2\r\n"), []byte("hello")))
+
+	cr, _ := c.Lookup(hp)
+	assert.Equal(t, "Cache-Control: max-age=60\r\nX-End: 2\r\n", string(cr.Header))
+
+	var buf bytes.Buffer
+	require.NoError(t, cr.WriteTo(&buf, *now))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nCache-Control: max-age=60\r\nX-End: 2\r\nAge: 0\r\nContent-Length: 5\r\n\r\nhello", buf.String())
+
+	*now = now.Add(61 * time.Second)
+
+	cr, _ = c.Lookup(hp)
+	c.Refresh(hp, cr, []byte("Cache-Control: max-age=60\r\nConnection: close\r\nTransfer-Encoding: chunked\r\n"))
+
+	cr, res := c.Lookup(hp)
+	assert.Equal(t, CacheHit, res)
+	assert.Equal(t, "X-End: 2\r\nCache-Control: max-age=60\r\n", string(cr.Header))
+
+	// Headers a store was handed directly are still left out.
+	cr.Header = []byte("Transfer-Encoding: chunked\r\nConnection: close\r\n")
+
+	buf.Reset()
+	require.NoError(t, cr.WriteTo(&buf, *now))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nAge: 0\r\nContent-Length: 5\r\n\r\nhello", buf.String())
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	m := NewMemoryCache(2)
+
+	m.Set("a", &CachedResponse{})
+	m.Set("b", &CachedResponse{})
+	m.Get("a")
+	m.Set("c", &CachedResponse{})
+
+	_, ok := m.Get("b")
+	assert.False(t, ok)
+
+	_, ok = m.Get("a")
+	assert.True(t, ok)
+}
+
+func TestCacheZeroValues(t *testing.T) {
+	c := &Cache{Store: &MemoryCache{}}
+
+	hp := parseRequest(t, "GET /a HTTP/1.1\r\nHost: x\r\n\r\n")
+	require.True(t, c.Put(hp, 200, []byte("Cache-Control: max-age=60\r\n"), []byte("hello")))
+
+	cr, res := c.Lookup(hp)
+	assert.Equal(t, CacheHit, res)
+	assert.Equal(t, "hello", string(cr.Body))
+}
@@ -0,0 +1,79 @@
+package wildcat
+
+// CaptureHeaders is a set of well-known headers that Parse stores in
+// dedicated fields as it goes, so looking them up afterwards doesn't scan
+// the headers. Captured headers are available even when they aren't
+// subscribed to.
+type CaptureHeaders uint8
+
+const (
+	CaptureHost CaptureHeaders = 1 << iota
+	CaptureConnection
+	CaptureUpgrade
+	CaptureTransferEncoding
+	CaptureContentType
+
+	// Every header that can be captured; what new parsers use.
+	DefaultCaptureHeaders = CaptureHost | CaptureConnection | CaptureUpgrade |
+		CaptureTransferEncoding | CaptureContentType
+)
+
+const numCaptureSlots = 5
+
+// The capture slot of each header class, plus one; 0 for classes that
+// aren't captured.
+var captureSlots = [numHeaderClasses]int8{
+	hHost:             1,
+	hConnection:       2,
+	hUpgrade:          3,
+	hTransferEncoding: 4,
+	hContentType:      5,
+}
+
+// Return the slot class is captured in, or -1.
+func (hp *HTTPParser) captureSlot(class headerClass) int {
+	slot := int(captureSlots[class]) - 1
+	if slot < 0 || hp.capture&(1<<uint(slot)) == 0 {
+		return -1
+	}
+
+	return slot
+}
+
+// Store value if class is captured and hasn't been seen yet. Returns the
+// slot used, or -1.
+func (hp *HTTPParser) captureHeader(class headerClass, value []byte) int {
+	slot := hp.captureSlot(class)
+	if slot < 0 || hp.captured[slot] != nil {
+		return -1
+	}
+
+	hp.captured[slot] = value
+	return slot
+}
+
+// Set which headers Parse captures. Headers left out are found by scanning
+// the stored headers, as for any other header.
+func (hp *HTTPParser) SetCaptureHeaders(set CaptureHeaders) {
+	hp.capture = set
+}
+
+// Return the value of the Connection header.
+func (hp *HTTPParser) Connection() []byte {
+	return hp.findHeaderClass(hConnection)
+}
+
+// Return the value of the Upgrade header.
+func (hp *HTTPParser) Upgrade() []byte {
+	return hp.findHeaderClass(hUpgrade)
+}
+
+// Return the value of the Transfer-Encoding header.
+func (hp *HTTPParser) TransferEncoding() []byte {
+	return hp.findHeaderClass(hTransferEncoding)
+}
+
+// Return the value of the Content-Type header.
+func (hp *HTTPParser) ContentType() []byte {
+	return hp.findHeaderClass(hContentType)
+}
@@ -0,0 +1,67 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var captureReq = []byte("POST / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\n" +
+	"Upgrade: websocket\r\nContent-Type: text/plain\r\nTransfer-Encoding: chunked\r\n" +
+	"Content-Type: text/html\r\n\r\n")
+
+func TestParserCapturesHeaders(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse(captureReq)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com", string(hp.Host()))
+	assert.Equal(t, "Upgrade", string(hp.Connection()))
+	assert.Equal(t, "websocket", string(hp.Upgrade()))
+	assert.Equal(t, "chunked", string(hp.TransferEncoding()))
+	assert.Equal(t, "text/plain", string(hp.ContentType()))
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Nil(t, hp.Host())
+	assert.Nil(t, hp.ContentType())
+}
+
+func TestParserCapturesUnsubscribedHeaders(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SubscribeAllHeader(false)
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\nHost: a.example\r\n b.example\r\nAccept: */*\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, hp.HeaderCount())
+	assert.Equal(t, "a.example b.example", string(hp.Host()))
+	assert.Nil(t, hp.FindHeader([]byte("Accept")))
+}
+
+func TestParserCaptureSubset(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetCaptureHeaders(CaptureHost)
+
+	_, err := hp.Parse(captureReq)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com", string(hp.Host()))
+	assert.Equal(t, "text/plain", string(hp.ContentType()))
+	assert.Equal(t, "websocket", string(hp.FindHeader([]byte("Upgrade"))))
+}
+
+func TestParserCaptureAllocs(t *testing.T) {
+	hp := NewSizedHTTPParser(8)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		hp.Parse(captureReq)
+		hp.ContentType()
+		hp.Upgrade()
+	})
+
+	assert.Equal(t, float64(0), allocs)
+}
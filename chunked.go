@@ -0,0 +1,377 @@
+package wildcat
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrChunkedWriterClosed    = errors.New("write to closed chunked writer")
+	ErrBadChunk               = errors.New("malformed chunked encoding")
+	ErrChunkExtension         = errors.New("chunk extensions not allowed")
+	ErrChunkExtensionTooLarge = errors.New("chunk extension too large")
+)
+
+// How a ChunkedReader treats chunk extensions (the ";name=value" parameters
+// after a chunk size). Nothing uses them in practice, and an unbounded
+// extension lets a client hold the connection open or smuggle bytes past a
+// proxy, so they're either skipped within a limit or rejected.
+type ChunkExtensions int
+
+const (
+	// Skip extensions up to MaxExtensionBytes per chunk.
+	SkipChunkExtensions ChunkExtensions = iota
+
+	// Fail with ErrChunkExtension if any chunk has an extension.
+	RejectChunkExtensions
+)
+
+// The default per-chunk limit on the size of skipped extensions.
+const DefaultMaxChunkExtensionBytes = 1024
+
+// The longest chunk size accepted, in hex digits.
+const maxChunkSizeDigits = 15
+
+// The longest trailer line accepted.
+const maxTrailerLineBytes = 8192
+
+const (
+	chunkSize = iota
+	chunkData
+	chunkDataEnd
+	chunkTrailer
+	chunkDone
+)
+
+// ChunkedReader decodes a body sent with the chunked transfer coding. It
+// reads exactly up to the end of the body, so the bytes following it are
+// available to the next request.
+type ChunkedReader struct {
+	Extensions        ChunkExtensions
+	MaxExtensionBytes int
+
+	pending []byte
+	scratch []byte
+	line    []byte
+	c       io.ReadCloser
+
+	state int
+	left  int64
+	err   error
+}
+
+// Create a ChunkedReader that reads rest, then c.
+func NewChunkedReader(rest []byte, c io.ReadCloser) *ChunkedReader {
+	return &ChunkedReader{
+		MaxExtensionBytes: DefaultMaxChunkExtensionBytes,
+		pending:           rest,
+		c:                 c,
+	}
+}
+
+func (cr *ChunkedReader) fill() error {
+	if cr.c == nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	if cr.scratch == nil {
+		cr.scratch = make([]byte, OptimalBufferSize)
+	}
+
+	n, err := cr.c.Read(cr.scratch)
+	cr.pending = cr.scratch[:n]
+
+	if n > 0 {
+		return nil
+	}
+
+	if err == io.EOF || err == nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// Return the next CRLF terminated line, without the CRLF. Lines longer
+// than limit fail with tooLong. A bare LF is rejected, as different
+// parsers disagreeing on line endings is a classic smuggling vector.
+func (cr *ChunkedReader) readLine(limit int, tooLong error) ([]byte, error) {
+	cr.line = cr.line[:0]
+
+	for {
+		if len(cr.pending) == 0 {
+			if err := cr.fill(); err != nil {
+				return nil, err
+			}
+		}
+
+		for i, c := range cr.pending {
+			if c != '\n' {
+				continue
+			}
+
+			cr.line = append(cr.line, cr.pending[:i]...)
+			cr.pending = cr.pending[i+1:]
+
+			l := len(cr.line)
+			if l == 0 || cr.line[l-1] != '\r' {
+				return nil, ErrBadChunk
+			}
+
+			if l-1 > limit {
+				return nil, tooLong
+			}
+
+			return cr.line[:l-1], nil
+		}
+
+		cr.line = append(cr.line, cr.pending...)
+		cr.pending = nil
+
+		if len(cr.line) > limit+1 {
+			return nil, tooLong
+		}
+	}
+}
+
+func (cr *ChunkedReader) readSize() error {
+	line, err := cr.readLine(maxChunkSizeDigits+cr.MaxExtensionBytes+1, ErrChunkExtensionTooLarge)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+
+	i := 0
+	for ; i < len(line); i++ {
+		v, ok := fromHex(line[i])
+		if !ok {
+			break
+		}
+
+		if i == maxChunkSizeDigits {
+			return ErrBadChunk
+		}
+
+		size = size<<4 | int64(v)
+	}
+
+	if i == 0 {
+		return ErrBadChunk
+	}
+
+	ext := line[i:]
+
+	// Allow whitespace before the extension, as RFC 9112 does (BWS).
+	for len(ext) > 0 && (ext[0] == ' ' || ext[0] == '\t') {
+		ext = ext[1:]
+	}
+
+	if len(ext) > 0 {
+		if ext[0] != ';' {
+			return ErrBadChunk
+		}
+
+		if cr.Extensions == RejectChunkExtensions {
+			return ErrChunkExtension
+		}
+
+		if len(ext) > cr.MaxExtensionBytes {
+			return ErrChunkExtensionTooLarge
+		}
+
+		for _, c := range ext {
+			if c < ' ' && c != '\t' || c == 0x7f {
+				return ErrBadChunk
+			}
+		}
+	}
+
+	cr.left = size
+
+	if size == 0 {
+		cr.state = chunkTrailer
+	} else {
+		cr.state = chunkData
+	}
+
+	return nil
+}
+
+func (cr *ChunkedReader) step() error {
+	switch cr.state {
+	case chunkSize:
+		return cr.readSize()
+	case chunkDataEnd:
+		line, err := cr.readLine(0, ErrBadChunk)
+		if err != nil {
+			return err
+		}
+
+		if len(line) != 0 {
+			return ErrBadChunk
+		}
+
+		cr.state = chunkSize
+	case chunkTrailer:
+		line, err := cr.readLine(maxTrailerLineBytes, ErrBadChunk)
+		if err != nil {
+			return err
+		}
+
+		if len(line) == 0 {
+			cr.state = chunkDone
+		}
+	}
+
+	return nil
+}
+
+func (cr *ChunkedReader) Read(buf []byte) (int, error) {
+	for cr.err == nil && cr.state != chunkData && cr.state != chunkDone {
+		cr.err = cr.step()
+	}
+
+	if cr.err != nil {
+		return 0, cr.err
+	}
+
+	if cr.state == chunkDone {
+		return 0, io.EOF
+	}
+
+	if len(cr.pending) == 0 {
+		if cr.err = cr.fill(); cr.err != nil {
+			return 0, cr.err
+		}
+	}
+
+	n := len(buf)
+	if int64(n) > cr.left {
+		n = int(cr.left)
+	}
+
+	if n > len(cr.pending) {
+		n = len(cr.pending)
+	}
+
+	copy(buf, cr.pending[:n])
+	cr.pending = cr.pending[n:]
+
+	cr.left -= int64(n)
+	if cr.left == 0 {
+		cr.state = chunkDataEnd
+	}
+
+	return n, nil
+}
+
+func (cr *ChunkedReader) Close() error {
+	if cr.c == nil {
+		return nil
+	}
+
+	return cr.c.Close()
+}
+
+// Return the bytes read past the end of the body, and whether the body was
+// read completely so they can be used.
+func (cr *ChunkedReader) leftover() ([]byte, bool) {
+	return cr.pending, cr.state == chunkDone
+}
+
+var cChunked = []byte("chunked")
+
+// Report whether the final transfer coding in value is chunked.
+func isChunked(value []byte) bool {
+	end := len(value)
+	for end > 0 && (value[end-1] == ' ' || value[end-1] == '\t') {
+		end--
+	}
+
+	start := end
+	for start > 0 && value[start-1] != ',' && value[start-1] != ' ' && value[start-1] != '\t' {
+		start--
+	}
+
+	return equalFoldASCII(value[start:end], cChunked)
+}
+
+// ChunkedWriter encodes a body with the chunked transfer coding. Each Write
+// is sent as one chunk; Close sends the last chunk and any trailers.
+type ChunkedWriter struct {
+	w        io.Writer
+	buf      []byte
+	trailers []header
+	closed   bool
+}
+
+// Create a ChunkedWriter encoding to w.
+func NewChunkedWriter(w io.Writer) *ChunkedWriter {
+	return &ChunkedWriter{w: w}
+}
+
+func (cw *ChunkedWriter) Write(p []byte) (int, error) {
+	if cw.closed {
+		return 0, ErrChunkedWriterClosed
+	}
+
+	if len(p) == 0 {
+		// An empty chunk would end the body.
+		return 0, nil
+	}
+
+	buf := strconv.AppendInt(cw.buf[:0], int64(len(p)), 16)
+	buf = append(buf, cCRLF...)
+	buf = append(buf, p...)
+	buf = append(buf, cCRLF...)
+	cw.buf = buf
+
+	if _, err := cw.w.Write(buf); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Set a trailer field to send after the body. It can be called until
+// Close, so values computed from the body, such as checksums, can be sent.
+// The names should be declared up front with Response.DeclareTrailer.
+func (cw *ChunkedWriter) SetTrailer(name, value []byte) {
+	for i := range cw.trailers {
+		if equalFoldASCII(cw.trailers[i].Name, name) {
+			cw.trailers[i].Value = value
+			return
+		}
+	}
+
+	cw.trailers = append(cw.trailers, header{Name: name, Value: value})
+}
+
+// Send the last chunk and the trailers. It doesn't close the underlying
+// writer.
+func (cw *ChunkedWriter) Close() error {
+	if cw.closed {
+		return nil
+	}
+
+	cw.closed = true
+
+	buf := append(cw.buf[:0], '0')
+	buf = append(buf, cCRLF...)
+
+	for _, t := range cw.trailers {
+		buf = append(buf, t.Name...)
+		buf = append(buf, cColon...)
+		buf = append(buf, t.Value...)
+		buf = append(buf, cCRLF...)
+	}
+
+	buf = append(buf, cCRLF...)
+	cw.buf = buf
+
+	_, err := cw.w.Write(buf)
+	return err
+}
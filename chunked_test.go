@@ -0,0 +1,134 @@
+package wildcat
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readChunked(cr *ChunkedReader) (string, error) {
+	body, err := io.ReadAll(cr)
+	return string(body), err
+}
+
+func TestChunkedReader(t *testing.T) {
+	cr := NewChunkedReader([]byte("5\r\nhello\r\n6\r\n world\r\n0\r\n\r\nGET /"), nil)
+
+	body, err := readChunked(cr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", body)
+
+	extra, ok := cr.leftover()
+	assert.True(t, ok)
+	assert.Equal(t, "GET /", string(extra))
+}
+
+func TestChunkedReaderAcrossReads(t *testing.T) {
+	cr := NewChunkedReader([]byte("5\r"), io.NopCloser(strings.NewReader("\nhello\r\n0\r\nX-Sum: 1\r\n\r\n")))
+
+	body, err := readChunked(cr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", body)
+}
+
+func TestChunkedReaderSkipsExtensions(t *testing.T) {
+	cr := NewChunkedReader([]byte("5;name=\"v\"\r\nhello\r\n0\r\n\r\n"), nil)
+
+	body, err := readChunked(cr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", body)
+}
+
+func TestChunkedReaderExtensionTooLarge(t *testing.T) {
+	cr := NewChunkedReader([]byte("5;"+strings.Repeat("a", 64)+"\r\nhello\r\n0\r\n\r\n"), nil)
+	cr.MaxExtensionBytes = 16
+
+	_, err := readChunked(cr)
+	assert.Equal(t, ErrChunkExtensionTooLarge, err)
+
+	// The limit applies before the line ending is seen.
+	cr = NewChunkedReader(nil, io.NopCloser(strings.NewReader("5;"+strings.Repeat("a", 4096))))
+	cr.MaxExtensionBytes = 16
+
+	_, err = readChunked(cr)
+	assert.Equal(t, ErrChunkExtensionTooLarge, err)
+}
+
+func TestChunkedReaderRejectsExtensions(t *testing.T) {
+	cr := NewChunkedReader([]byte("5;a=b\r\nhello\r\n0\r\n\r\n"), nil)
+	cr.Extensions = RejectChunkExtensions
+
+	_, err := readChunked(cr)
+	assert.Equal(t, ErrChunkExtension, err)
+}
+
+func TestChunkedReaderMalformed(t *testing.T) {
+	for _, in := range []string{
+		"5\nhello\r\n0\r\n\r\n",
+		"5\r\nhelloX\r\n0\r\n\r\n",
+		"x\r\n",
+		"5 x\r\nhello\r\n",
+		"1000000000000000\r\n",
+		"5;a\x00b\r\nhello\r\n",
+	} {
+		_, err := readChunked(NewChunkedReader([]byte(in), nil))
+		assert.Equal(t, ErrBadChunk, err, "%q", in)
+	}
+
+	_, err := readChunked(NewChunkedReader([]byte("5\r\nhel"), nil))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestParserChunkedBodyReader(t *testing.T) {
+	hp := NewHTTPParser()
+
+	in := []byte("POST / HTTP/1.1\r\nTransfer-Encoding: gzip, chunked\r\n\r\n3;x\r\nabc\r\n0\r\n\r\n")
+
+	n, err := hp.Parse(in)
+	require.NoError(t, err)
+
+	hp.SetChunkExtensions(RejectChunkExtensions, 0)
+
+	_, err = io.ReadAll(hp.BodyReader(in[n:], nil))
+	assert.Equal(t, ErrChunkExtension, err)
+
+	_, ok := hp.bodyLeftover(in[n:])
+	assert.False(t, ok)
+}
+
+func TestChunkedWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	cw := NewChunkedWriter(&buf)
+
+	_, err := cw.Write([]byte("hello "))
+	require.NoError(t, err)
+
+	_, err = cw.Write(nil)
+	require.NoError(t, err)
+
+	_, err = cw.Write([]byte("chunked world"))
+	require.NoError(t, err)
+
+	cw.SetTrailer([]byte("X-Checksum"), []byte("abc"))
+	cw.SetTrailer([]byte("x-checksum"), []byte("def"))
+	require.NoError(t, cw.Close())
+
+	assert.Equal(t, "6\r\nhello \r\nd\r\nchunked world\r\n0\r\nX-Checksum: def\r\n\r\n", buf.String())
+
+	_, err = cw.Write([]byte("late"))
+	assert.Equal(t, ErrChunkedWriterClosed, err)
+
+	cr := NewChunkedReader(buf.Bytes(), nil)
+	out, err := io.ReadAll(cr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello chunked world", string(out))
+
+	rest, ok := cr.leftover()
+	assert.True(t, ok)
+	assert.Len(t, rest, 0)
+}
@@ -0,0 +1,128 @@
+package wildcat
+
+import "strconv"
+
+// Codec frames requests out of, and serializes responses into, plain byte
+// buffers. It's meant for readiness-based event loops (epoll, gnet and the
+// like) where there is no goroutine per connection to block in Read: the
+// loop hands the codec whatever bytes it has, gets back the complete
+// requests and the leftover bytes, and later writes out the responses the
+// codec produced.
+//
+// A Codec holds per-connection state and is not safe for concurrent use.
+type Codec struct {
+	// The largest header block Decode will buffer before giving up with
+	// ErrHeaderTooLarge. Defaults to DefaultMaxHeaderBytes.
+	MaxHeaderBytes int
+
+	hp   *HTTPParser
+	reqs []*Request
+
+	headers    []header
+	numHeaders int
+}
+
+const DefaultMaxHeaderBytes = 64 * 1024
+
+// Create a new codec for a single connection.
+func NewCodec() *Codec {
+	return &Codec{
+		MaxHeaderBytes: DefaultMaxHeaderBytes,
+		hp:             NewHTTPParser(),
+	}
+}
+
+// Decode parses as many complete requests (header block plus a
+// Content-Length body) as in contains. The requests own their memory. rest
+// is the unconsumed tail of in, which is the start of an incomplete request;
+// the caller should keep it and call Decode again with more data appended.
+//
+// The returned slice is reused by the next call to Decode.
+func (c *Codec) Decode(in []byte) (reqs []*Request, rest []byte, err error) {
+	c.reqs = c.reqs[:0]
+
+	for len(in) > 0 {
+		c.hp.Reset()
+
+		n, err := c.hp.Parse(in)
+		if err == ErrMissingData {
+			if len(in) > c.MaxHeaderBytes {
+				return c.reqs, in, ErrHeaderTooLarge
+			}
+
+			break
+		}
+
+		if err != nil {
+			return c.reqs, in, err
+		}
+
+		if c.hp.findHeaderClass(hTransferEncoding) != nil {
+			return c.reqs, in, ErrUnsupported
+		}
+
+		size := c.hp.ContentLength()
+		if size < 0 {
+			size = 0
+		}
+
+		if int64(len(in)-n) < size {
+			break
+		}
+
+		req := c.hp.Snapshot()
+		if size > 0 {
+			req.Body = append([]byte(nil), in[n:n+int(size)]...)
+		}
+
+		c.reqs = append(c.reqs, req)
+
+		in, err = skipToRequestLine(in[n+int(size):])
+		if err != nil {
+			return c.reqs, nil, err
+		}
+	}
+
+	return c.reqs, in, nil
+}
+
+// Add a header to the next response produced by AppendResponse.
+func (c *Codec) AddHeader(key, val []byte) {
+	if c.numHeaders == len(c.headers) {
+		newHeaders := make([]header, c.numHeaders+10)
+		copy(newHeaders, c.headers)
+		c.headers = newHeaders
+	}
+
+	c.headers[c.numHeaders] = header{Name: key, Value: val}
+	c.numHeaders++
+}
+
+func (c *Codec) AddStringHeader(key, val string) {
+	c.AddHeader([]byte(key), []byte(val))
+}
+
+// Append a complete response with the given status code, the headers added
+// since the last call, a Content-Length and body to dst.
+func (c *Codec) AppendResponse(dst []byte, code int, body []byte) []byte {
+	dst = appendStatusLine(dst, code)
+
+	for i := 0; i < c.numHeaders; i++ {
+		h := &c.headers[i]
+		dst = append(dst, h.Name...)
+		dst = append(dst, cColon...)
+		dst = append(dst, h.Value...)
+		dst = append(dst, cCRLF...)
+
+		c.headers[i] = header{}
+	}
+
+	c.numHeaders = 0
+
+	dst = append(dst, "Content-Length: "...)
+	dst = strconv.AppendInt(dst, int64(len(body)), 10)
+	dst = append(dst, cCRLF...)
+	dst = append(dst, cCRLF...)
+
+	return append(dst, body...)
+}
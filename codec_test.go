@@ -0,0 +1,92 @@
+package wildcat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecDecode(t *testing.T) {
+	c := NewCodec()
+
+	in := []byte("GET /a HTTP/1.1\r\nHost: x\r\n\r\nPOST /b HTTP/1.1\r\nContent-Length: 5\r\n\r\nhelloGET /c HT")
+
+	reqs, rest, err := c.Decode(in)
+	require.NoError(t, err)
+
+	require.Len(t, reqs, 2)
+	assert.Equal(t, []byte("/a"), reqs[0].Path)
+	assert.Nil(t, reqs[0].Body)
+	assert.Equal(t, []byte("/b"), reqs[1].Path)
+	assert.Equal(t, []byte("hello"), reqs[1].Body)
+	assert.Equal(t, []byte("GET /c HT"), rest)
+
+	first := reqs[1]
+
+	reqs, rest, err = c.Decode(append(rest, "TP/1.1\r\n\r\n"...))
+	require.NoError(t, err)
+
+	require.Len(t, reqs, 1)
+	assert.Equal(t, []byte("/c"), reqs[0].Path)
+	assert.Empty(t, rest)
+
+	assert.Equal(t, []byte("hello"), first.Body)
+}
+
+func TestCodecDecodeWaitsForBody(t *testing.T) {
+	c := NewCodec()
+
+	in := []byte("POST /b HTTP/1.1\r\nContent-Length: 5\r\n\r\nhel")
+
+	reqs, rest, err := c.Decode(in)
+	require.NoError(t, err)
+
+	assert.Len(t, reqs, 0)
+	assert.Equal(t, in, rest)
+}
+
+func TestCodecDecodeHeaderTooLarge(t *testing.T) {
+	c := NewCodec()
+	c.MaxHeaderBytes = 16
+
+	_, _, err := c.Decode([]byte("GET / HTTP/1.1\r\nHost: a-long-host-name\r\n"))
+	assert.Equal(t, ErrHeaderTooLarge, err)
+}
+
+func TestCodecAppendResponse(t *testing.T) {
+	c := NewCodec()
+	c.AddStringHeader("X-Runtime", "1")
+
+	out := c.AppendResponse(nil, 200, []byte("hi"))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nX-Runtime: 1\r\nContent-Length: 2\r\n\r\nhi", string(out))
+
+	out = c.AppendResponse(out[:0], 404, nil)
+	assert.Equal(t, "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n", string(out))
+}
+
+func TestCodecDecodeBodyLengthMismatch(t *testing.T) {
+	c := NewCodec()
+
+	in := []byte("POST /b HTTP/1.1\r\nContent-Length: 3\r\n\r\nhello\r\nGET /c HTTP/1.1\r\n\r\n")
+
+	reqs, _, err := c.Decode(in)
+	assert.Equal(t, ErrBodyLengthMismatch, err)
+
+	require.Len(t, reqs, 1)
+	assert.Equal(t, []byte("hel"), reqs[0].Body)
+}
+
+func TestCodecDecodeBadContentLength(t *testing.T) {
+	c := NewCodec()
+
+	in := []byte("GET /a HTTP/1.1\r\n\r\nPOST /b HTTP/1.1\r\nContent-Length: -3\r\n\r\nabc")
+
+	reqs, rest, err := c.Decode(in)
+	assert.Equal(t, ErrBadContentLength, err)
+
+	require.Len(t, reqs, 1)
+	assert.Equal(t, []byte("/a"), reqs[0].Path)
+	assert.True(t, bytes.HasPrefix(rest, []byte("POST /b")))
+}
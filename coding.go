@@ -0,0 +1,197 @@
+package wildcat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/vektra/errors"
+)
+
+var ErrUnsupportedCoding = errors.New("unsupported content coding")
+
+// A ContentCoding compresses and decompresses one content coding, such as
+// gzip. gzip and deflate are built in; others (br, zstd) can be added with
+// RegisterContentCoding without wildcat depending on their packages.
+type ContentCoding struct {
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	codingsMu sync.RWMutex
+	codings   = map[string]ContentCoding{}
+)
+
+func init() {
+	gz := ContentCoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	}
+
+	RegisterContentCoding("gzip", gz)
+	RegisterContentCoding("x-gzip", gz)
+
+	// HTTP's "deflate" is the zlib format, not raw deflate.
+	RegisterContentCoding("deflate", ContentCoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+	})
+}
+
+// Register c under name, replacing any existing coding with that name.
+// Names are case-insensitive.
+func RegisterContentCoding(name string, c ContentCoding) {
+	codingsMu.Lock()
+	defer codingsMu.Unlock()
+
+	codings[strings.ToLower(name)] = c
+}
+
+// Return the coding registered under name.
+func LookupContentCoding(name []byte) (ContentCoding, bool) {
+	var lower [32]byte
+
+	if len(name) > len(lower) {
+		return ContentCoding{}, false
+	}
+
+	for i, c := range name {
+		lower[i] = toLower(c)
+	}
+
+	codingsMu.RLock()
+	defer codingsMu.RUnlock()
+
+	c, ok := codings[string(lower[:len(name)])]
+	return c, ok
+}
+
+type decodedBodyReader struct {
+	io.Reader
+
+	closers []io.Closer
+}
+
+func (dr *decodedBodyReader) Close() error {
+	var err error
+
+	for _, c := range dr.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Return a reader for the request body with its Content-Encoding removed.
+// Codings are undone in reverse of the order they were applied. An unknown
+// coding fails with ErrUnsupportedCoding, which a server would usually
+// answer with a 415.
+func (hp *HTTPParser) DecodedBodyReader(rest []byte, in io.ReadCloser) (io.ReadCloser, error) {
+	body := hp.BodyReader(rest, in)
+	if body == nil {
+		return nil, nil
+	}
+
+	var names [][]byte
+
+	for i := 0; i < hp.numHeaders; i++ {
+		h := &hp.Headers[i]
+		if h.class != hContentEncoding {
+			continue
+		}
+
+		for list := h.Value; len(list) > 0; {
+			var item []byte
+			item, list = nextListItem(list)
+
+			if len(item) == 0 || equalFoldASCII(item, cIdentity) {
+				continue
+			}
+
+			names = append(names, item)
+		}
+	}
+
+	if len(names) == 0 {
+		return body, nil
+	}
+
+	dr := &decodedBodyReader{Reader: body}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		c, ok := LookupContentCoding(names[i])
+		if !ok || c.NewReader == nil {
+			return nil, ErrUnsupportedCoding
+		}
+
+		r, err := c.NewReader(dr.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		dr.Reader = r
+		dr.closers = append(dr.closers, r)
+	}
+
+	dr.closers = append(dr.closers, body)
+
+	return dr, nil
+}
+
+var cContentEncoding = []byte("Content-Encoding")
+
+// Compress body with the named coding and write it with Content-Encoding
+// and Content-Length headers. Typically coding comes from Negotiate. An
+// empty coding or "identity" writes body as it is, without
+// Content-Encoding.
+func (r *Response) WriteBodyEncoded(coding []byte, body []byte) error {
+	if len(coding) == 0 || equalFoldASCII(coding, cIdentity) {
+		r.WriteBodyBytes(body)
+		return nil
+	}
+
+	c, ok := LookupContentCoding(coding)
+	if !ok || c.NewWriter == nil {
+		return ErrUnsupportedCoding
+	}
+
+	var buf bytes.Buffer
+
+	w, err := c.NewWriter(&buf)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	hdr := append(append([]byte(nil), cContentEncoding...), cColon...)
+	hdr = append(hdr, coding...)
+	hdr = append(hdr, cCRLF...)
+
+	if _, err := r.c.Write(hdr); err != nil {
+		return err
+	}
+
+	r.WriteBodyBytes(buf.Bytes())
+	return nil
+}
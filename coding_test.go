@@ -0,0 +1,128 @@
+package wildcat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func parseWithBody(t *testing.T, head string, body []byte) (*HTTPParser, []byte) {
+	hp := NewHTTPParser()
+
+	in := append([]byte(head), body...)
+
+	n, err := hp.Parse(in)
+	require.NoError(t, err)
+
+	return hp, in[n:]
+}
+
+func TestDecodedBodyReader(t *testing.T) {
+	body := gzipBytes(t, []byte("hello"))
+
+	hp, rest := parseWithBody(t, "POST / HTTP/1.1\r\nContent-Encoding: GZIP\r\nContent-Length: "+strconv.Itoa(len(body))+"\r\n\r\n", body)
+
+	r, err := hp.DecodedBodyReader(rest, nil)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestDecodedBodyReaderUnknownCoding(t *testing.T) {
+	hp, rest := parseWithBody(t, "POST / HTTP/1.1\r\nContent-Encoding: gzip, snappy\r\nContent-Length: 3\r\n\r\n", []byte("abc"))
+
+	_, err := hp.DecodedBodyReader(rest, nil)
+	assert.Equal(t, ErrUnsupportedCoding, err)
+}
+
+func TestRegisterContentCoding(t *testing.T) {
+	// A toy coding that upper-cases on the way out and lower-cases back.
+	RegisterContentCoding("x-upper", ContentCoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			data, err := io.ReadAll(r)
+			return io.NopCloser(strings.NewReader(strings.ToLower(string(data)))), err
+		},
+	})
+
+	hp, rest := parseWithBody(t, "POST / HTTP/1.1\r\nContent-Encoding: x-upper\r\nContent-Length: 5\r\n\r\n", []byte("HELLO"))
+
+	r, err := hp.DecodedBodyReader(rest, nil)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestWriteBodyEncoded(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		resp := NewResponse(server)
+		resp.WriteStatus(200)
+		resp.WriteHeaders()
+		resp.WriteBodyEncoded([]byte("gzip"), []byte("hello"))
+	}()
+
+	data, err := io.ReadAll(client)
+	require.NoError(t, err)
+
+	parts := strings.SplitN(string(data), "\r\n\r\n", 2)
+	require.Len(t, parts, 2)
+	assert.Contains(t, parts[0], "Content-Encoding: gzip\r\n")
+
+	zr, err := gzip.NewReader(strings.NewReader(parts[1]))
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestWriteBodyEncodedIdentity(t *testing.T) {
+	for _, coding := range []string{"identity", ""} {
+		client, server := tcpPair(t)
+
+		go func() {
+			defer server.Close()
+
+			resp := NewResponse(server)
+			resp.WriteStatus(200)
+			resp.WriteHeaders()
+			assert.NoError(t, resp.WriteBodyEncoded([]byte(coding), []byte("hello")))
+		}()
+
+		data, err := io.ReadAll(client)
+		require.NoError(t, err)
+
+		client.Close()
+
+		parts := strings.SplitN(string(data), "\r\n\r\n", 2)
+		require.Len(t, parts, 2)
+		assert.False(t, strings.Contains(parts[0], "Content-Encoding"), coding)
+		assert.Contains(t, parts[0], "Content-Length: 5")
+		assert.Equal(t, "hello", parts[1])
+	}
+}
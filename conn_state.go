@@ -0,0 +1,97 @@
+package wildcat
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// The state of a connection, as reported to Server.ConnState.
+type ConnState int32
+
+const (
+	// Just accepted; the TLS handshake, if any, hasn't happened yet.
+	StateNew ConnState = iota
+
+	// Bytes of a request have been read and the request is being handled.
+	StateActive
+
+	// Waiting for the next request.
+	StateIdle
+
+	// Taken over by a handler. The server reports nothing more about it.
+	StateHijacked
+
+	// Closed by the server.
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateActive:
+		return "active"
+	case StateIdle:
+		return "idle"
+	case StateHijacked:
+		return "hijacked"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Record the connection's new state and tell Server.ConnState about it,
+// unless it's the state the connection was already in. Returns false if
+// the connection was closed meanwhile, as StateClosed is final.
+func (c *serverConn) setState(state ConnState) bool {
+	for {
+		old := ConnState(atomic.LoadInt32(&c.state))
+
+		if old == StateClosed {
+			return state == StateClosed
+		}
+
+		if old == state && state != StateNew {
+			return true
+		}
+
+		if atomic.CompareAndSwapInt32(&c.state, int32(old), int32(state)) {
+			c.reportState(state)
+			return true
+		}
+	}
+}
+
+func (c *serverConn) reportState(state ConnState) {
+	if fn := c.srv.ConnState; fn != nil {
+		fn(c, state)
+	}
+}
+
+// Close the connection if it's waiting for a request, reporting whether it
+// was. Checking and closing are one step, so a connection that has just
+// started on a request is left alone.
+func (c *serverConn) closeIfIdle() bool {
+	for _, st := range []ConnState{StateIdle, StateNew} {
+		if atomic.CompareAndSwapInt32(&c.state, int32(st), int32(StateClosed)) {
+			c.Close()
+			c.reportState(StateClosed)
+			return true
+		}
+	}
+
+	return false
+}
+
+// How long a rejected connection gets to take its 503.
+const rejectTimeout = time.Second
+
+// Answer a connection over Server.MaxConns with a 503 and close it.
+func rejectConn(c net.Conn) {
+	c.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	c.Write(errorResponses[StatusServiceUnavailable])
+	c.Close()
+}
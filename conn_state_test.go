@@ -0,0 +1,212 @@
+package wildcat
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Collect the states reported for each connection.
+type stateLog struct {
+	mu     sync.Mutex
+	states []ConnState
+	closed chan struct{}
+}
+
+func newStateLog() *stateLog {
+	return &stateLog{closed: make(chan struct{}, 10)}
+}
+
+func (l *stateLog) record(c net.Conn, state ConnState) {
+	l.mu.Lock()
+	l.states = append(l.states, state)
+	l.mu.Unlock()
+
+	if state == StateClosed || state == StateHijacked {
+		l.closed <- struct{}{}
+	}
+}
+
+func (l *stateLog) get() []ConnState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]ConnState(nil), l.states...)
+}
+
+func TestServerConnState(t *testing.T) {
+	log := newStateLog()
+
+	s := &Server{Handler: handlerFunc(helloHandler), ConnState: log.record}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	r := bufio.NewReader(c)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+		require.NoError(t, err)
+
+		readResponse(t, r)
+		_, err = io.ReadFull(r, make([]byte, 5))
+		require.NoError(t, err)
+	}
+
+	c.Close()
+
+	select {
+	case <-log.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection not closed")
+	}
+
+	assert.Equal(t, []ConnState{
+		StateNew, StateIdle, StateActive, StateIdle, StateActive, StateIdle, StateClosed,
+	}, log.get())
+
+	assert.Equal(t, "hijacked", StateHijacked.String())
+}
+
+func TestServerConnStateHijacked(t *testing.T) {
+	log := newStateLog()
+
+	s := &Server{
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			raw, _, err := Hijack(c)
+			if err == nil {
+				raw.Close()
+			}
+		}),
+		ConnState: log.record,
+	}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	select {
+	case <-log.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection not hijacked")
+	}
+
+	// Give the server a moment to (not) report anything else.
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, []ConnState{StateNew, StateIdle, StateActive, StateHijacked}, log.get())
+}
+
+func TestServerMaxConns(t *testing.T) {
+	s := &Server{Handler: handlerFunc(helloHandler), MaxConns: 1}
+
+	addr, _ := startServer(t, s)
+
+	first, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer first.Close()
+
+	// Make sure the first connection has been accepted.
+	_, err = first.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(first)
+	assert.Contains(t, readResponse(t, r), "HTTP/1.1 200 OK\r\n")
+
+	second, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer second.Close()
+
+	head := readResponse(t, bufio.NewReader(second))
+	assert.Contains(t, head, "HTTP/1.1 503 Service Unavailable\r\n")
+	assert.Contains(t, head, "Connection: close\r\n")
+
+	// Once the first goes away there's room again.
+	first.Close()
+
+	var line string
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		c, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+
+		c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+		line, _ = bufio.NewReader(c).ReadString('\n')
+		c.Close()
+
+		if line == "HTTP/1.1 200 OK\r\n" {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", line)
+}
+
+func TestServerMaxRequestsPerConn(t *testing.T) {
+	s := &Server{Handler: handlerFunc(helloHandler), MaxRequestsPerConn: 2}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\nGET / HTTP/1.1\r\nHost: x\r\n\r\nGET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(c)
+
+	head := readResponse(t, r)
+	assert.False(t, strings.Contains(head, "Connection: close"))
+	_, err = io.ReadFull(r, make([]byte, 5))
+	require.NoError(t, err)
+
+	head = readResponse(t, r)
+	assert.Contains(t, head, "Connection: close\r\n")
+	_, err = io.ReadFull(r, make([]byte, 5))
+	require.NoError(t, err)
+
+	// The third request isn't served.
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCloseIfIdle(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	var states []ConnState
+
+	c := &serverConn{Conn: server, srv: &Server{ConnState: func(_ net.Conn, st ConnState) {
+		states = append(states, st)
+	}}}
+
+	c.setState(StateActive)
+	assert.False(t, c.closeIfIdle())
+
+	c.setState(StateIdle)
+	assert.True(t, c.closeIfIdle())
+
+	// Once closed, the connection can't go back to work.
+	assert.False(t, c.setState(StateActive))
+	assert.True(t, c.setState(StateClosed))
+
+	assert.Equal(t, []ConnState{StateActive, StateIdle, StateClosed}, states)
+}
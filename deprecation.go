@@ -0,0 +1,301 @@
+package wildcat
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	cDeprecation = []byte("Deprecation")
+	cSunset      = []byte("Sunset")
+	cWarningName = []byte("Warning")
+	cLinkName    = []byte("Link")
+	cTrue        = []byte("true")
+)
+
+// Link relations for documentation about a deprecation (RFC 9745) and a
+// sunset (RFC 8594).
+var (
+	RelDeprecation = []byte("deprecation")
+	RelSunset      = []byte("sunset")
+)
+
+// Append a Deprecation value (RFC 9745) saying the resource was or will be
+// deprecated at t. It's a structured field date: '@' and Unix seconds.
+func AppendDeprecation(dst []byte, t time.Time) []byte {
+	dst = append(dst, '@')
+	return strconv.AppendInt(dst, t.Unix(), 10)
+}
+
+// Parse a Deprecation value. Besides the RFC 9745 form, the "true" and
+// HTTP date forms of earlier drafts are accepted; "true" gives the zero
+// time.
+func ParseDeprecation(value []byte) (time.Time, bool) {
+	value = bytes.TrimSpace(value)
+
+	if bytes.Equal(value, cTrue) {
+		return time.Time{}, true
+	}
+
+	if len(value) > 1 && value[0] == '@' {
+		digits, neg := value[1:], false
+		if digits[0] == '-' {
+			digits, neg = digits[1:], true
+		}
+
+		secs, ok := parseBindUint(digits, 63)
+		if !ok {
+			return time.Time{}, false
+		}
+
+		if neg {
+			return time.Unix(-int64(secs), 0).UTC(), true
+		}
+
+		return time.Unix(int64(secs), 0).UTC(), true
+	}
+
+	return ParseSunset(value)
+}
+
+// Append a Sunset value (RFC 8594), the HTTP date t.
+func AppendSunset(dst []byte, t time.Time) []byte {
+	return t.UTC().AppendFormat(dst, http.TimeFormat)
+}
+
+// Parse a Sunset value.
+func ParseSunset(value []byte) (time.Time, bool) {
+	t, err := http.ParseTime(string(bytes.TrimSpace(value)))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// Append a Link value pointing at uri with relation rel, such as
+// `<https://example.com/deprecation>; rel="deprecation"`.
+func AppendLink(dst, uri, rel []byte) []byte {
+	dst = append(dst, '<')
+	dst = append(dst, uri...)
+	dst = append(dst, `>; rel=`...)
+	return appendQuotedString(dst, rel)
+}
+
+// Return the first element of a Link list, and the rest of the list.
+// Commas inside the URI or quoted parameters don't split.
+func nextLink(list []byte) (link, rest []byte) {
+	quoted, bracketed := false, false
+
+	for i := 0; i < len(list); i++ {
+		switch c := list[i]; {
+		case bracketed:
+			bracketed = c != '>'
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			if c == '\\' {
+				i++
+			}
+		case c == '<':
+			bracketed = true
+		case c == ',':
+			return bytes.TrimSpace(list[:i]), list[i+1:]
+		}
+	}
+
+	return bytes.TrimSpace(list), nil
+}
+
+// Report whether the space separated relation types in rels include rel.
+func hasRel(rels, rel []byte) bool {
+	for _, r := range bytes.Fields(rels) {
+		if equalFoldASCII(r, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the URI of the first link in a Link value with relation rel, or
+// nil if there's none.
+func FindLink(value, rel []byte) []byte {
+	for list := value; len(list) > 0; {
+		var link []byte
+		link, list = nextLink(list)
+
+		if len(link) == 0 || link[0] != '<' {
+			continue
+		}
+
+		end := bytes.IndexByte(link, '>')
+		if end == -1 {
+			continue
+		}
+
+		uri := link[1:end]
+		found := false
+
+		parseLinkParams(link[end+1:], func(name string, value []byte) {
+			if name == "rel" && hasRel(value, rel) {
+				found = true
+			}
+		})
+
+		if found {
+			return uri
+		}
+	}
+
+	return nil
+}
+
+// Call fn for each ';' separated parameter of a link, with quoted values
+// unquoted.
+func parseLinkParams(params []byte, fn func(name string, value []byte)) {
+	ParseParameterizedValue(params, func(name, value []byte) bool {
+		fn(string(bytes.ToLower(name)), value)
+		return true
+	})
+}
+
+// Split a possibly quoted value off the front of b, ending at a delimiter
+// outside quotes. Quoted values are returned unquoted.
+func unquotePrefix(b []byte, delim byte) (value, rest []byte) {
+	if len(b) == 0 || b[0] != '"' {
+		end := bytes.IndexByte(b, delim)
+		if end == -1 {
+			end = len(b)
+		}
+
+		return bytes.TrimSpace(b[:end]), b[end:]
+	}
+
+	escaped := false
+
+	i := 1
+	for ; i < len(b) && b[i] != '"'; i++ {
+		if b[i] == '\\' && i+1 < len(b) {
+			escaped = true
+			i++
+		}
+	}
+
+	value = b[1:i]
+
+	// Only values with escapes need a copy.
+	if escaped {
+		value = nil
+
+		for j := 1; j < i; j++ {
+			if b[j] == '\\' {
+				j++
+			}
+
+			value = append(value, b[j])
+		}
+	}
+
+	if i < len(b) {
+		i++
+	}
+
+	return value, b[i:]
+}
+
+// Append s as a quoted-string.
+func appendQuotedString(dst, s []byte) []byte {
+	dst = append(dst, '"')
+
+	for _, c := range s {
+		if c == '"' || c == '\\' {
+			dst = append(dst, '\\')
+		}
+
+		dst = append(dst, c)
+	}
+
+	return append(dst, '"')
+}
+
+// Mark the response as deprecated at deprecated, with an optional sunset
+// time (zero to leave it out) and an optional link to documentation about
+// the deprecation.
+func (r *Response) AddDeprecation(deprecated, sunset time.Time, link []byte) {
+	r.AddHeader(cDeprecation, AppendDeprecation(nil, deprecated))
+
+	if !sunset.IsZero() {
+		r.AddHeader(cSunset, AppendSunset(nil, sunset))
+	}
+
+	if link != nil {
+		r.AddHeader(cLinkName, AppendLink(nil, link, RelDeprecation))
+	}
+}
+
+// Append a Warning value (RFC 7234, section 5.5), such as
+// `299 gateway.example "Deprecated API"`. An empty agent is sent as "-".
+func AppendWarning(dst []byte, code int, agent, text []byte) []byte {
+	dst = strconv.AppendInt(dst, int64(code), 10)
+	dst = append(dst, ' ')
+
+	if len(agent) == 0 {
+		dst = append(dst, '-')
+	} else {
+		dst = append(dst, agent...)
+	}
+
+	dst = append(dst, ' ')
+	return appendQuotedString(dst, text)
+}
+
+// Add a Warning header to the response.
+func (r *Response) AddWarning(code int, agent, text []byte) {
+	r.AddHeader(cWarningName, AppendWarning(nil, code, agent, text))
+}
+
+// Call fn for each warning in a Warning value. Returns false if value is
+// malformed.
+func VisitWarnings(value []byte, fn func(code int, agent, text []byte)) bool {
+	for len(value) > 0 {
+		value = bytes.TrimLeft(value, " \t,")
+		if len(value) == 0 {
+			break
+		}
+
+		if len(value) < 4 || !isDigit(value[0]) || !isDigit(value[1]) || !isDigit(value[2]) || value[3] != ' ' {
+			return false
+		}
+
+		code := int(value[0]-'0')*100 + int(value[1]-'0')*10 + int(value[2]-'0')
+
+		value = value[4:]
+
+		sp := bytes.IndexByte(value, ' ')
+		if sp <= 0 {
+			return false
+		}
+
+		agent := value[:sp]
+		value = bytes.TrimLeft(value[sp+1:], " \t")
+
+		if len(value) == 0 || value[0] != '"' {
+			return false
+		}
+
+		var text []byte
+		text, value = unquotePrefix(value, ',')
+
+		// Skip the optional warn-date.
+		if value = bytes.TrimLeft(value, " \t"); len(value) > 0 && value[0] == '"' {
+			_, value = unquotePrefix(value, ',')
+		}
+
+		fn(code, agent, text)
+	}
+
+	return true
+}
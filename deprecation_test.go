@@ -0,0 +1,99 @@
+package wildcat
+
+import (
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecation(t *testing.T) {
+	at := time.Date(2023, 6, 30, 23, 59, 59, 0, time.UTC)
+
+	assert.Equal(t, "@1688169599", string(AppendDeprecation(nil, at)))
+
+	got, ok := ParseDeprecation([]byte("@1688169599"))
+	require.True(t, ok)
+	assert.True(t, at.Equal(got))
+
+	got, ok = ParseDeprecation([]byte("true"))
+	require.True(t, ok)
+	assert.True(t, got.IsZero())
+
+	got, ok = ParseDeprecation([]byte("Fri, 30 Jun 2023 23:59:59 GMT"))
+	require.True(t, ok)
+	assert.True(t, at.Equal(got))
+
+	for _, bad := range []string{"", "@", "@12x", "yes"} {
+		_, ok := ParseDeprecation([]byte(bad))
+		assert.False(t, ok, bad)
+	}
+}
+
+func TestSunset(t *testing.T) {
+	at := time.Date(2024, 11, 11, 23, 59, 59, 0, time.UTC)
+
+	assert.Equal(t, "Mon, 11 Nov 2024 23:59:59 GMT", string(AppendSunset(nil, at)))
+
+	got, ok := ParseSunset([]byte("Mon, 11 Nov 2024 23:59:59 GMT"))
+	require.True(t, ok)
+	assert.True(t, at.Equal(got))
+}
+
+func TestFindLink(t *testing.T) {
+	value := []byte(`<https://example.com/a,b>; rel="preload", ` +
+		`<https://example.com/deprecation>; rel="alternate Deprecation"; type="text/html", ` +
+		`<https://example.com/sunset>; rel=sunset`)
+
+	assert.Equal(t, "https://example.com/deprecation", string(FindLink(value, RelDeprecation)))
+	assert.Equal(t, "https://example.com/sunset", string(FindLink(value, RelSunset)))
+	assert.Equal(t, "https://example.com/a,b", string(FindLink(value, []byte("preload"))))
+	assert.Nil(t, FindLink(value, []byte("next")))
+
+	link := AppendLink(nil, []byte("/docs"), RelDeprecation)
+	assert.Equal(t, `</docs>; rel="deprecation"`, string(link))
+	assert.Equal(t, "/docs", string(FindLink(link, RelDeprecation)))
+}
+
+func TestWarnings(t *testing.T) {
+	value := AppendWarning(nil, 299, []byte("gw.example"), []byte(`Deprecated "v1" API`))
+	assert.Equal(t, `299 gw.example "Deprecated \"v1\" API"`, string(value))
+
+	value = append(value, `, 110 - "Response is Stale" "Sat, 25 Aug 2012 23:34:45 GMT"`...)
+
+	var got []string
+	ok := VisitWarnings(value, func(code int, agent, text []byte) {
+		got = append(got, strconv.Itoa(code)+" "+string(agent)+" "+string(text))
+	})
+
+	require.True(t, ok)
+	assert.Equal(t, []string{`299 gw.example Deprecated "v1" API`, "110 - Response is Stale"}, got)
+
+	assert.False(t, VisitWarnings([]byte("abc"), func(int, []byte, []byte) {}))
+}
+
+func TestAddDeprecation(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	go func() {
+		resp := NewResponse(server)
+		resp.AddDeprecation(time.Unix(1688169599, 0), time.Date(2024, 11, 11, 23, 59, 59, 0, time.UTC), []byte("/docs"))
+		resp.WriteStatus(200)
+		resp.WriteHeaders()
+		resp.WriteBodyString("ok")
+		server.Close()
+	}()
+
+	out, err := io.ReadAll(client)
+	require.NoError(t, err)
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Deprecation: @1688169599\r\n"+
+		"Sunset: Mon, 11 Nov 2024 23:59:59 GMT\r\n"+
+		"Link: </docs>; rel=\"deprecation\"\r\n"+
+		"Content-Length: 2\r\n\r\nok", string(out))
+}
@@ -0,0 +1,556 @@
+package wildcat
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrBadDigest         = errors.New("malformed digest authorization")
+	ErrDigestAlgorithm   = errors.New("unsupported digest algorithm")
+	ErrDigestStaleNonce  = errors.New("digest nonce expired")
+	ErrDigestReplay      = errors.New("digest nonce count reused")
+	ErrDigestBadResponse = errors.New("digest response does not match")
+)
+
+// A Digest challenge, sent in WWW-Authenticate (RFC 7616, section 3.3).
+type DigestChallenge struct {
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	Qop       []string
+	Stale     bool
+}
+
+// Digest credentials, sent in Authorization (RFC 7616, section 3.4).
+type DigestCredentials struct {
+	Username  string
+	Realm     string
+	Nonce     string
+	URI       string
+	Response  string
+	Algorithm string
+	Cnonce    string
+	Opaque    string
+	Qop       string
+	Nc        string
+}
+
+var cDigestScheme = []byte("Digest")
+
+// Call fn for each auth-param in b, with quoted values unquoted.
+func parseAuthParams(b []byte, fn func(name string, value []byte)) bool {
+	for {
+		b = bytes.TrimLeft(b, " \t,")
+		if len(b) == 0 {
+			return true
+		}
+
+		eq := bytes.IndexByte(b, '=')
+		if eq <= 0 {
+			return false
+		}
+
+		name := string(bytes.ToLower(bytes.TrimSpace(b[:eq])))
+		b = bytes.TrimLeft(b[eq+1:], " \t")
+
+		var value []byte
+
+		if len(b) > 0 && b[0] == '"' {
+			var out []byte
+
+			i := 1
+			for ; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' && i+1 < len(b) {
+					i++
+				}
+
+				out = append(out, b[i])
+			}
+
+			if i == len(b) {
+				return false
+			}
+
+			value, b = out, b[i+1:]
+		} else {
+			end := bytes.IndexByte(b, ',')
+			if end == -1 {
+				end = len(b)
+			}
+
+			value, b = bytes.TrimSpace(b[:end]), b[end:]
+		}
+
+		fn(name, value)
+	}
+}
+
+// Strip the "Digest" scheme from a header value.
+func digestParams(value []byte) ([]byte, bool) {
+	if len(value) <= len(cDigestScheme) || !equalFoldASCII(value[:len(cDigestScheme)], cDigestScheme) ||
+		(value[len(cDigestScheme)] != ' ' && value[len(cDigestScheme)] != '\t') {
+		return nil, false
+	}
+
+	return value[len(cDigestScheme)+1:], true
+}
+
+// Parse a WWW-Authenticate value holding a Digest challenge.
+func ParseDigestChallenge(value []byte) (DigestChallenge, error) {
+	var ch DigestChallenge
+
+	params, ok := digestParams(value)
+	if !ok {
+		return ch, ErrBadDigest
+	}
+
+	ok = parseAuthParams(params, func(name string, v []byte) {
+		switch name {
+		case "realm":
+			ch.Realm = string(v)
+		case "nonce":
+			ch.Nonce = string(v)
+		case "opaque":
+			ch.Opaque = string(v)
+		case "algorithm":
+			ch.Algorithm = string(v)
+		case "qop":
+			for _, q := range strings.Split(string(v), ",") {
+				if q = strings.TrimSpace(q); q != "" {
+					ch.Qop = append(ch.Qop, q)
+				}
+			}
+		case "stale":
+			ch.Stale = strings.EqualFold(string(v), "true")
+		}
+	})
+
+	if !ok || ch.Nonce == "" {
+		return ch, ErrBadDigest
+	}
+
+	return ch, nil
+}
+
+// Parse an Authorization value holding Digest credentials.
+func ParseDigestCredentials(value []byte) (DigestCredentials, error) {
+	var cr DigestCredentials
+
+	params, ok := digestParams(value)
+	if !ok {
+		return cr, ErrBadDigest
+	}
+
+	ok = parseAuthParams(params, func(name string, v []byte) {
+		switch name {
+		case "username":
+			cr.Username = string(v)
+		case "realm":
+			cr.Realm = string(v)
+		case "nonce":
+			cr.Nonce = string(v)
+		case "uri":
+			cr.URI = string(v)
+		case "response":
+			cr.Response = string(v)
+		case "algorithm":
+			cr.Algorithm = string(v)
+		case "cnonce":
+			cr.Cnonce = string(v)
+		case "opaque":
+			cr.Opaque = string(v)
+		case "qop":
+			cr.Qop = string(v)
+		case "nc":
+			cr.Nc = string(v)
+		}
+	})
+
+	if !ok || cr.Username == "" || cr.Nonce == "" || cr.URI == "" || cr.Response == "" {
+		return cr, ErrBadDigest
+	}
+
+	// With a qop, cnonce and nc are required.
+	if cr.Qop != "" && (cr.Cnonce == "" || cr.Nc == "") {
+		return cr, ErrBadDigest
+	}
+
+	return cr, nil
+}
+
+func appendAuthParam(dst []byte, name, value string, quoted bool) []byte {
+	if dst[len(dst)-1] != ' ' {
+		dst = append(dst, ", "...)
+	}
+
+	dst = append(dst, name...)
+	dst = append(dst, '=')
+
+	if !quoted {
+		return append(dst, value...)
+	}
+
+	dst = append(dst, '"')
+
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			dst = append(dst, '\\')
+		}
+
+		dst = append(dst, value[i])
+	}
+
+	return append(dst, '"')
+}
+
+// Append ch as a WWW-Authenticate value.
+func (ch DigestChallenge) AppendHeader(dst []byte) []byte {
+	dst = append(dst, "Digest "...)
+	dst = appendAuthParam(dst, "realm", ch.Realm, true)
+
+	if len(ch.Qop) > 0 {
+		dst = appendAuthParam(dst, "qop", strings.Join(ch.Qop, ", "), true)
+	}
+
+	if ch.Algorithm != "" {
+		dst = appendAuthParam(dst, "algorithm", ch.Algorithm, false)
+	}
+
+	dst = appendAuthParam(dst, "nonce", ch.Nonce, true)
+
+	if ch.Opaque != "" {
+		dst = appendAuthParam(dst, "opaque", ch.Opaque, true)
+	}
+
+	if ch.Stale {
+		dst = appendAuthParam(dst, "stale", "true", false)
+	}
+
+	return dst
+}
+
+// Append cr as an Authorization value.
+func (cr DigestCredentials) AppendHeader(dst []byte) []byte {
+	dst = append(dst, "Digest "...)
+	dst = appendAuthParam(dst, "username", cr.Username, true)
+	dst = appendAuthParam(dst, "realm", cr.Realm, true)
+	dst = appendAuthParam(dst, "uri", cr.URI, true)
+
+	if cr.Algorithm != "" {
+		dst = appendAuthParam(dst, "algorithm", cr.Algorithm, false)
+	}
+
+	dst = appendAuthParam(dst, "nonce", cr.Nonce, true)
+
+	if cr.Qop != "" {
+		dst = appendAuthParam(dst, "nc", cr.Nc, false)
+		dst = appendAuthParam(dst, "cnonce", cr.Cnonce, true)
+		dst = appendAuthParam(dst, "qop", cr.Qop, false)
+	}
+
+	dst = appendAuthParam(dst, "response", cr.Response, true)
+
+	if cr.Opaque != "" {
+		dst = appendAuthParam(dst, "opaque", cr.Opaque, true)
+	}
+
+	return dst
+}
+
+// Return the hash for a digest algorithm name, and whether it's a -sess
+// variant. An empty name is MD5.
+func digestHash(algorithm string) (func() hash.Hash, bool, error) {
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5":
+		return md5.New, false, nil
+	case "MD5-SESS":
+		return md5.New, true, nil
+	case "SHA-256":
+		return sha256.New, false, nil
+	case "SHA-256-SESS":
+		return sha256.New, true, nil
+	}
+
+	return nil, false, ErrDigestAlgorithm
+}
+
+func digestHex(h func() hash.Hash, parts ...string) string {
+	d := h()
+	d.Write([]byte(strings.Join(parts, ":")))
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+// Return H(username:realm:password), which servers can store instead of
+// the password.
+func DigestHA1(algorithm, username, realm, password string) (string, error) {
+	h, _, err := digestHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	return digestHex(h, username, realm, password), nil
+}
+
+// Compute the response for cr given ha1 (see DigestHA1). Only the "auth"
+// qop is supported, as "auth-int" needs the body up front.
+func digestResponse(cr *DigestCredentials, method, ha1 string) (string, error) {
+	h, sess, err := digestHash(cr.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	if sess {
+		ha1 = digestHex(h, ha1, cr.Nonce, cr.Cnonce)
+	}
+
+	ha2 := digestHex(h, method, cr.URI)
+
+	switch cr.Qop {
+	case "":
+		return digestHex(h, ha1, cr.Nonce, ha2), nil
+	case "auth":
+		return digestHex(h, ha1, cr.Nonce, cr.Nc, cr.Cnonce, cr.Qop, ha2), nil
+	}
+
+	return "", ErrDigestAlgorithm
+}
+
+// Answer ch for a request with method and uri, as a client would. nc is
+// the number of requests made with this nonce so far, including this one.
+func (ch DigestChallenge) Respond(method, uri, username, password string, nc uint32) (DigestCredentials, error) {
+	cr := DigestCredentials{
+		Username:  username,
+		Realm:     ch.Realm,
+		Nonce:     ch.Nonce,
+		URI:       uri,
+		Algorithm: ch.Algorithm,
+		Opaque:    ch.Opaque,
+	}
+
+	for _, q := range ch.Qop {
+		if q == "auth" {
+			var b [12]byte
+			if _, err := rand.Read(b[:]); err != nil {
+				return cr, err
+			}
+
+			cr.Qop = "auth"
+			cr.Cnonce = hex.EncodeToString(b[:])
+			cr.Nc = strconv.FormatUint(uint64(nc)|1<<32, 16)[1:]
+		}
+	}
+
+	ha1, err := DigestHA1(ch.Algorithm, username, ch.Realm, password)
+	if err != nil {
+		return cr, err
+	}
+
+	cr.Response, err = digestResponse(&cr, method, ha1)
+	return cr, err
+}
+
+// The default lifetime of nonces issued by a DigestVerifier.
+const DefaultDigestNonceLifetime = 5 * time.Minute
+
+// DigestVerifier issues challenges and checks Digest credentials on the
+// server. Nonces are self-validating (a timestamp signed with a random
+// key), and nonce counts are tracked so a captured Authorization can't be
+// replayed.
+type DigestVerifier struct {
+	Realm     string
+	Algorithm string
+
+	// How long a nonce is accepted. 0 means DefaultDigestNonceLifetime.
+	NonceLifetime time.Duration
+
+	// Return the HA1 (see DigestHA1) for username, or false if there's no
+	// such user.
+	Lookup func(username string) (ha1 string, ok bool)
+
+	once sync.Once
+	key  [32]byte
+
+	mu  sync.Mutex
+	ncs map[string]uint64
+
+	now func() time.Time
+}
+
+func NewDigestVerifier(realm string, lookup func(username string) (string, bool)) *DigestVerifier {
+	v := &DigestVerifier{
+		Realm:     realm,
+		Algorithm: "SHA-256",
+		Lookup:    lookup,
+	}
+
+	v.setup()
+
+	return v
+}
+
+// Fill in what a DigestVerifier built without NewDigestVerifier lacks.
+func (v *DigestVerifier) setup() {
+	v.once.Do(func() {
+		rand.Read(v.key[:])
+		v.ncs = make(map[string]uint64)
+
+		if v.now == nil {
+			v.now = time.Now
+		}
+	})
+}
+
+// Return an algorithm name as compared by Verify: one left out is MD5
+// (RFC 7616, section 3.3).
+func digestAlgorithm(name string) string {
+	if name == "" {
+		return "MD5"
+	}
+
+	return name
+}
+
+func (v *DigestVerifier) lifetime() time.Duration {
+	if v.NonceLifetime > 0 {
+		return v.NonceLifetime
+	}
+
+	return DefaultDigestNonceLifetime
+}
+
+func (v *DigestVerifier) sign(ts []byte) []byte {
+	mac := hmac.New(sha256.New, v.key[:])
+	mac.Write(ts)
+	return mac.Sum(nil)[:16]
+}
+
+func (v *DigestVerifier) newNonce() string {
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(v.now().UnixNano()))
+
+	return hex.EncodeToString(append(ts[:], v.sign(ts[:])...))
+}
+
+// Check a nonce's signature, returning when it was issued.
+func (v *DigestVerifier) nonceTime(nonce string) (time.Time, bool) {
+	b, err := hex.DecodeString(nonce)
+	if err != nil || len(b) != 24 {
+		return time.Time{}, false
+	}
+
+	if !hmac.Equal(b[8:], v.sign(b[:8])) {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b[:8]))), true
+}
+
+// Return a new challenge. Set stale when answering credentials that failed
+// with ErrDigestStaleNonce, so the client retries without prompting.
+func (v *DigestVerifier) Challenge(stale bool) DigestChallenge {
+	v.setup()
+
+	return DigestChallenge{
+		Realm:     v.Realm,
+		Nonce:     v.newNonce(),
+		Algorithm: v.Algorithm,
+		Qop:       []string{"auth"},
+		Stale:     stale,
+	}
+}
+
+// Check the request's Digest credentials, returning the username.
+func (v *DigestVerifier) Verify(hp *HTTPParser) (string, error) {
+	v.setup()
+
+	auth := hp.findHeaderClass(hAuthorization)
+	if auth == nil {
+		return "", ErrBadDigest
+	}
+
+	cr, err := ParseDigestCredentials(auth)
+	if err != nil {
+		return "", err
+	}
+
+	if cr.Realm != v.Realm || cr.URI != string(hp.Path) || cr.Qop != "auth" {
+		return "", ErrBadDigest
+	}
+
+	if !strings.EqualFold(digestAlgorithm(cr.Algorithm), digestAlgorithm(v.Algorithm)) {
+		return "", ErrDigestAlgorithm
+	}
+
+	issued, ok := v.nonceTime(cr.Nonce)
+	if !ok {
+		return "", ErrBadDigest
+	}
+
+	now := v.now()
+	if now.Sub(issued) > v.lifetime() {
+		return "", ErrDigestStaleNonce
+	}
+
+	ha1, ok := v.Lookup(cr.Username)
+	if !ok {
+		return "", ErrDigestBadResponse
+	}
+
+	want, err := digestResponse(&cr, string(hp.Method), ha1)
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(want), []byte(strings.ToLower(cr.Response))) != 1 {
+		return "", ErrDigestBadResponse
+	}
+
+	nc, err := strconv.ParseUint(cr.Nc, 16, 32)
+	if err != nil {
+		return "", ErrBadDigest
+	}
+
+	if !v.useCount(cr.Nonce, nc, now) {
+		return "", ErrDigestReplay
+	}
+
+	return cr.Username, nil
+}
+
+// Record nc for nonce, reporting whether it's higher than any seen before.
+func (v *DigestVerifier) useCount(nonce string, nc uint64, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if nc <= v.ncs[nonce] {
+		return false
+	}
+
+	v.ncs[nonce] = nc
+
+	// Forget expired nonces now and then; they're rejected anyway.
+	if len(v.ncs) > 1024 {
+		for n := range v.ncs {
+			if t, _ := v.nonceTime(n); now.Sub(t) > v.lifetime() {
+				delete(v.ncs, n)
+			}
+		}
+	}
+
+	return true
+}
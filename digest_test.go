@@ -0,0 +1,144 @@
+package wildcat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The example from RFC 7616, section 3.9.1.
+func rfc7616Credentials(algorithm string) DigestCredentials {
+	return DigestCredentials{
+		Username:  "Mufasa",
+		Realm:     "http-auth@example.org",
+		Nonce:     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		URI:       "/dir/index.html",
+		Algorithm: algorithm,
+		Cnonce:    "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+		Qop:       "auth",
+		Nc:        "00000001",
+	}
+}
+
+func TestDigestResponse(t *testing.T) {
+	for algorithm, want := range map[string]string{
+		"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+		"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	} {
+		cr := rfc7616Credentials(algorithm)
+
+		ha1, err := DigestHA1(algorithm, cr.Username, cr.Realm, "Circle of Life")
+		require.NoError(t, err)
+
+		got, err := digestResponse(&cr, "GET", ha1)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, algorithm)
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	ch, err := ParseDigestChallenge([]byte(`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf", opaque="FQhe", stale=TRUE`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "http-auth@example.org", ch.Realm)
+	assert.Equal(t, []string{"auth", "auth-int"}, ch.Qop)
+	assert.Equal(t, "SHA-256", ch.Algorithm)
+	assert.Equal(t, "7ypf", ch.Nonce)
+	assert.Equal(t, "FQhe", ch.Opaque)
+	assert.True(t, ch.Stale)
+
+	back, err := ParseDigestChallenge(ch.AppendHeader(nil))
+	require.NoError(t, err)
+	assert.Equal(t, ch, back)
+
+	_, err = ParseDigestChallenge([]byte(`Basic realm="x"`))
+	assert.Equal(t, ErrBadDigest, err)
+
+	_, err = ParseDigestChallenge([]byte(`Digest realm="x`))
+	assert.Equal(t, ErrBadDigest, err)
+}
+
+func TestParseDigestCredentials(t *testing.T) {
+	cr := rfc7616Credentials("SHA-256")
+	cr.Response = "abc"
+	cr.Username = `Mu"fasa`
+
+	back, err := ParseDigestCredentials(cr.AppendHeader(nil))
+	require.NoError(t, err)
+	assert.Equal(t, cr, back)
+
+	_, err = ParseDigestCredentials([]byte(`Digest username="a", nonce="n", uri="/", response="r", qop=auth`))
+	assert.Equal(t, ErrBadDigest, err)
+}
+
+func digestRequest(t *testing.T, cr DigestCredentials) *HTTPParser {
+	return parseRequest(t, "GET "+cr.URI+" HTTP/1.1\r\nAuthorization: "+string(cr.AppendHeader(nil))+"\r\n\r\n")
+}
+
+func TestDigestVerifier(t *testing.T) {
+	ha1, err := DigestHA1("SHA-256", "alice", "api", "secret")
+	require.NoError(t, err)
+
+	v := NewDigestVerifier("api", func(user string) (string, bool) {
+		return ha1, user == "alice"
+	})
+
+	now := time.Unix(1700000000, 0)
+	v.now = func() time.Time { return now }
+
+	ch := v.Challenge(false)
+
+	cr, err := ch.Respond("GET", "/x", "alice", "secret", 1)
+	require.NoError(t, err)
+
+	user, err := v.Verify(digestRequest(t, cr))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user)
+
+	// Replaying the same nonce count fails.
+	_, err = v.Verify(digestRequest(t, cr))
+	assert.Equal(t, ErrDigestReplay, err)
+
+	bad, err := ch.Respond("GET", "/x", "alice", "wrong", 2)
+	require.NoError(t, err)
+
+	_, err = v.Verify(digestRequest(t, bad))
+	assert.Equal(t, ErrDigestBadResponse, err)
+
+	now = now.Add(DefaultDigestNonceLifetime + time.Second)
+
+	late, err := ch.Respond("GET", "/x", "alice", "secret", 3)
+	require.NoError(t, err)
+
+	_, err = v.Verify(digestRequest(t, late))
+	assert.Equal(t, ErrDigestStaleNonce, err)
+
+	// A forged nonce is rejected.
+	forged := late
+	forged.Nonce = "00" + forged.Nonce[2:]
+
+	_, err = v.Verify(digestRequest(t, forged))
+	assert.Equal(t, ErrBadDigest, err)
+}
+
+func TestDigestVerifierOmittedAlgorithm(t *testing.T) {
+	ha1, err := DigestHA1("MD5", "alice", "api", "secret")
+	require.NoError(t, err)
+
+	// Usable without NewDigestVerifier.
+	v := &DigestVerifier{Realm: "api", Algorithm: "MD5", Lookup: func(user string) (string, bool) {
+		return ha1, user == "alice"
+	}}
+
+	cr, err := v.Challenge(false).Respond("GET", "/x", "alice", "secret", 1)
+	require.NoError(t, err)
+
+	// Leaving algorithm out means MD5.
+	cr.Algorithm = ""
+
+	user, err := v.Verify(digestRequest(t, cr))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user)
+}
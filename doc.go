@@ -12,7 +12,7 @@ there end up being more than 10, this is resized up to 20, then 30, etc.
 
 This can be overriden by using NewSizedHTTPParser to pass in the initial size
 to use, eliminated allocations for your use case (you could set this to 1000
-for instance).
+for instance), or SetHeaderGrowth to grow geometrically and cap the count.
 
 2. If a mime-style multiline header is encountered, wildcat will make a new
 buffer to contain the concatination of values.
@@ -0,0 +1,85 @@
+package wildcat
+
+import "github.com/vektra/errors"
+
+var (
+	ErrRequestLineTooLong = errors.New("request line too long")
+	ErrBodyTooLarge       = errors.New("request body too large")
+)
+
+// Return the status to answer a request with when reading or handling it
+// failed with err: 4xx for problems with the request, 500 when err isn't
+// one that says what went wrong.
+func StatusForError(err error) int {
+	if me, ok := err.(*MethodError); ok {
+		return me.Status
+	}
+
+	switch errors.Cause(err) {
+	case ErrHeaderTooLarge, ErrTooManyHeaders:
+		return statusRequestHeaderFieldsTooLarge
+	case ErrRequestLineTooLong:
+		return StatusRequestURITooLong
+	case ErrBodyTooLarge:
+		return StatusRequestEntityTooLarge
+	case ErrBodyTimeout:
+		return StatusRequestTimeout
+	case ErrBadProto, ErrBadHeaderByte, ErrBadContentLength, ErrBadPath, ErrBadEscape,
+		ErrBadChunk, ErrChunkExtension, ErrChunkExtensionTooLarge, ErrBodyLengthMismatch,
+		ErrMissingHost, ErrDuplicateHost, ErrHostMismatch:
+		return StatusBadRequest
+	case ErrUnsupported:
+		return StatusNotImplemented
+	case ErrHandlerTimeout:
+		return StatusServiceUnavailable
+	}
+
+	return StatusInternalServerError
+}
+
+// Complete responses for the statuses StatusForError returns, rendered
+// once so answering a bad request takes a single Write.
+var errorResponses = map[int][]byte{}
+
+func init() {
+	for _, code := range []int{
+		StatusBadRequest,
+		StatusRequestTimeout,
+		StatusRequestEntityTooLarge,
+		StatusRequestURITooLong,
+		statusRequestHeaderFieldsTooLarge,
+		StatusInternalServerError,
+		StatusNotImplemented,
+		StatusServiceUnavailable,
+	} {
+		errorResponses[code] = appendErrorResponse(nil, code)
+	}
+}
+
+func appendErrorResponse(dst []byte, code int) []byte {
+	dst = appendStatusLine(dst, code)
+	dst = append(dst, cConnClose...)
+	return append(dst, "Content-Length: 0\r\n\r\n"...)
+}
+
+// Return a minimal response for err: the status from StatusForError with
+// "Connection: close" and an empty body. The connection should be closed
+// after it's written. The result is shared and must not be modified.
+func ErrorResponse(err error) []byte {
+	code := StatusForError(err)
+
+	if resp, ok := errorResponses[code]; ok {
+		return resp
+	}
+
+	return appendErrorResponse(nil, code)
+}
+
+// The error for a request head that filled the whole buffer.
+func (hp *HTTPParser) overflowError() error {
+	if hp.progress.Waiting == WaitingRequestLine {
+		return ErrRequestLineTooLong
+	}
+
+	return ErrHeaderTooLarge
+}
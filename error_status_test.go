@@ -0,0 +1,90 @@
+package wildcat
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+func TestStatusForError(t *testing.T) {
+	cases := map[error]int{
+		ErrHeaderTooLarge:     431,
+		ErrTooManyHeaders:     431,
+		ErrRequestLineTooLong: 414,
+		ErrBodyTooLarge:       413,
+		ErrBadProto:           400,
+		errors.Context(ErrBadProto, "some detail"): 400,
+		ErrHostMismatch:     400,
+		ErrBadContentLength: 400,
+		ErrBodyTimeout:      408,
+		ErrUnsupported:      501,
+		ErrServerClosed:     500,
+		&MethodError{Status: StatusNotImplemented}: 501,
+	}
+
+	for err, status := range cases {
+		assert.Equal(t, status, StatusForError(err), err.Error())
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	assert.Equal(t, "HTTP/1.1 431 Request Header Fields Too Large\r\nConnection: close\r\nContent-Length: 0\r\n\r\n",
+		string(ErrorResponse(ErrHeaderTooLarge)))
+
+	assert.Equal(t, "HTTP/1.1 405 Method Not Allowed\r\nConnection: close\r\nContent-Length: 0\r\n\r\n",
+		string(ErrorResponse(&MethodError{Status: StatusMethodNotAllowed})))
+
+	allocs := testing.AllocsPerRun(10, func() {
+		ErrorResponse(ErrBadProto)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestServerAnswersMalformedRequests(t *testing.T) {
+	s := &Server{Handler: handlerFunc(helloHandler)}
+
+	addr, _ := startServer(t, s)
+
+	cases := map[string]string{
+		"GET / HTTP/1.1\r\nHost: x\rX\r\n\r\n":                             "400 Bad Request",
+		"GET /" + strings.Repeat("a", 2*OptimalBufferSize) + " HTTP/1.1":   "414 Request URI Too Long",
+		"GET / HTTP/1.1\r\nX: " + strings.Repeat("a", 2*OptimalBufferSize): "431 Request Header Fields Too Large",
+	}
+
+	for req, status := range cases {
+		c, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+
+		_, err = c.Write([]byte(req))
+		require.NoError(t, err)
+
+		head := readResponse(t, bufio.NewReader(c))
+		assert.Contains(t, head, "HTTP/1.1 "+status+"\r\n")
+		assert.Contains(t, head, "Connection: close\r\n")
+
+		c.Close()
+	}
+}
+
+func TestServerRejectsBadContentLength(t *testing.T) {
+	s := &Server{Handler: handlerFunc(helloHandler)}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	_, err = c.Write([]byte("POST / HTTP/1.1\r\nHost: x\r\nContent-Length: -5\r\n\r\nhello"))
+	require.NoError(t, err)
+
+	head := readResponse(t, bufio.NewReader(c))
+	assert.Contains(t, head, "HTTP/1.1 400 Bad Request\r\n")
+	assert.Contains(t, head, "Connection: close\r\n")
+}
@@ -0,0 +1,181 @@
+package wildcat
+
+import (
+	"bytes"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// FileServer is a Handler that serves files from a directory. It supports
+// GET and HEAD, Range requests, and conditional requests (see
+// EvaluatePreconditions) against an ETag and Last-Modified it derives from
+// the file.
+// Directories are served by their index file if one exists; there are no
+// directory listings.
+type FileServer struct {
+	Root string
+
+	// File served for requests for a directory. Defaults to "index.html".
+	Index string
+}
+
+// Create a FileServer for the directory root.
+func NewFileServer(root string) *FileServer {
+	return &FileServer{Root: root, Index: "index.html"}
+}
+
+var (
+	cHead         = []byte("HEAD")
+	cAllow        = []byte("Allow")
+	cGetHead      = []byte("GET, HEAD")
+	cContentType  = []byte("Content-Type")
+	cETag         = []byte("ETag")
+	cLastModified = []byte("Last-Modified")
+	cOctetStream  = []byte("application/octet-stream")
+)
+
+func (fs *FileServer) HandleConnection(hp *HTTPParser, rest []byte, c net.Conn) {
+	resp := NewResponse(c)
+
+	head := bytes.Equal(hp.Method, cHead)
+	if !hp.Get() && !head {
+		resp.AddHeader(cAllow, cGetHead)
+		writeStatusResponse(resp, StatusMethodNotAllowed)
+		return
+	}
+
+	name, err := CleanPath(hp.Path)
+	if err != nil {
+		writeStatusResponse(resp, StatusBadRequest)
+		return
+	}
+
+	f, fi, err := fs.open(string(name))
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			writeStatusResponse(resp, StatusNotFound)
+		case os.IsPermission(err):
+			writeStatusResponse(resp, StatusForbidden)
+		default:
+			writeStatusResponse(resp, StatusInternalServerError)
+		}
+		return
+	}
+
+	defer f.Close()
+
+	modTime := fi.ModTime()
+	etag := fileETag(fi)
+
+	resp.AddHeader(cETag, etag)
+	resp.AddHeader(cLastModified, []byte(modTime.UTC().Format(http.TimeFormat)))
+
+	status := EvaluatePreconditions(hp, Validators{ETag: etag, LastModified: modTime})
+
+	switch status {
+	case StatusNotModified:
+		resp.WriteStatus(StatusNotModified)
+		resp.WriteHeaders()
+		c.Write(cCRLF)
+		return
+	case StatusPreconditionFailed:
+		writeStatusResponse(resp, StatusPreconditionFailed)
+		return
+	}
+
+	resp.AddHeader(cContentType, fileContentType(f, fi.Name()))
+
+	if head {
+		resp.AddHeader(cAcceptRanges, cBytes)
+		resp.WriteStatus(StatusOK)
+		resp.WriteHeaders()
+		c.Write([]byte("Content-Length: " + strconv.FormatInt(fi.Size(), 10) + "\r\n\r\n"))
+		return
+	}
+
+	if status == StatusPartialContent {
+		resp.SendFileRange(hp, f, fi.Size())
+		return
+	}
+
+	resp.AddHeader(cAcceptRanges, cBytes)
+	resp.WriteStatus(StatusOK)
+	resp.WriteHeaders()
+	resp.SendFile(f, 0, fi.Size())
+}
+
+// Open the file for the cleaned request path name, resolving directories to
+// their index file.
+func (fs *FileServer) open(name string) (*os.File, os.FileInfo, error) {
+	full := filepath.Join(fs.Root, filepath.FromSlash(path.Clean(name)))
+
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	if !fi.IsDir() {
+		return f, fi, nil
+	}
+
+	f.Close()
+
+	if fs.Index == "" {
+		return nil, nil, os.ErrNotExist
+	}
+
+	f, err = os.Open(filepath.Join(full, fs.Index))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err = f.Stat()
+	if err != nil || fi.IsDir() {
+		f.Close()
+		return nil, nil, os.ErrNotExist
+	}
+
+	return f, fi, nil
+}
+
+// A validator derived from the modification time and size. It's treated as
+// strong so that range requests can be resumed with If-Range.
+func fileETag(fi os.FileInfo) []byte {
+	etag := []byte(`"`)
+	etag = strconv.AppendInt(etag, fi.ModTime().UnixNano(), 16)
+	etag = append(etag, '-')
+	etag = strconv.AppendInt(etag, fi.Size(), 16)
+	return append(etag, '"')
+}
+
+// Return the type for the file's extension or, failing that, the type
+// sniffed from its first bytes.
+func fileContentType(f *os.File, name string) []byte {
+	if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+		return []byte(ct)
+	}
+
+	var buf [SniffLen]byte
+
+	n, _ := f.ReadAt(buf[:], 0)
+	return DetectContentType(buf[:n])
+}
+
+// Write a complete response consisting of the status and its text as body.
+func writeStatusResponse(resp *Response, code int) {
+	resp.WriteStatus(code)
+	resp.WriteHeaders()
+	resp.WriteBodyString(StatusText(code))
+}
@@ -0,0 +1,145 @@
+package wildcat
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fileServerRoundTrip(t *testing.T, h Handler, request string) (string, string) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	hp := NewHTTPParser()
+	_, err := hp.Parse([]byte(request))
+	require.NoError(t, err)
+
+	go func() {
+		h.HandleConnection(hp, nil, server)
+		server.Close()
+	}()
+
+	r := bufio.NewReader(client)
+	head := readResponse(t, r)
+
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return head, string(body)
+}
+
+func TestFileServer(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello file"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "index.html"), []byte("<p>"), 0644))
+
+	fs := NewFileServer(root)
+
+	head, body := fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, head, "Content-Type: text/plain; charset=utf-8\r\n")
+	assert.Equal(t, "hello file", body)
+
+	etag := ""
+	for _, line := range strings.Split(head, "\r\n") {
+		if strings.HasPrefix(line, "ETag: ") {
+			etag = strings.TrimPrefix(line, "ETag: ")
+		}
+	}
+	require.NotEqual(t, "", etag)
+
+	head, body = fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\nIf-None-Match: "+etag+"\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 304 Not Modified\r\n")
+	assert.Equal(t, "", body)
+
+	future := time.Now().Add(time.Hour).UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
+	head, _ = fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\nIf-Modified-Since: "+future+"\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 304 Not Modified\r\n")
+
+	head, body = fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\nRange: bytes=6-\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 206 Partial Content\r\n")
+	assert.Equal(t, "file", body)
+
+	head, body = fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\nRange: bytes=0-1,6-\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 206 Partial Content\r\n")
+	assert.Contains(t, head, "Content-Type: multipart/byteranges; boundary=")
+	assert.Contains(t, body, "Content-Type: text/plain; charset=utf-8\r\nContent-Range: bytes 0-1/10\r\n\r\nhe\r\n")
+	assert.Contains(t, body, "Content-Range: bytes 6-9/10\r\n\r\nfile\r\n")
+
+	head, body = fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\nRange: bytes=6-\r\nIf-Range: \"stale\"\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+	assert.Equal(t, "hello file", body)
+
+	head, _ = fileServerRoundTrip(t, fs, "GET /a.txt HTTP/1.1\r\nIf-Match: \"stale\"\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 412 Precondition Failed\r\n")
+
+	head, body = fileServerRoundTrip(t, fs, "HEAD /a.txt HTTP/1.1\r\n\r\n")
+	assert.Contains(t, head, "Content-Length: 10\r\n")
+	assert.Equal(t, "", body)
+
+	head, body = fileServerRoundTrip(t, fs, "GET /dir/ HTTP/1.1\r\n\r\n")
+	assert.Contains(t, head, "Content-Type: text/html; charset=utf-8\r\n")
+	assert.Equal(t, "<p>", body)
+
+	head, _ = fileServerRoundTrip(t, fs, "GET /missing HTTP/1.1\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 404 Not Found\r\n")
+
+	head, _ = fileServerRoundTrip(t, fs, "POST /a.txt HTTP/1.1\r\n\r\n")
+	assert.Contains(t, head, "HTTP/1.1 405 Method Not Allowed\r\n")
+	assert.Contains(t, head, "Allow: GET, HEAD\r\n")
+}
+
+func TestFileServerTraversal(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	require.NoError(t, os.Mkdir(root, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(parent, "secret"), []byte("no"), 0644))
+
+	fs := NewFileServer(root)
+
+	for _, p := range []string{"/../secret", "/%2e%2e/secret", "/a/..%2f..%2fsecret"} {
+		head, body := fileServerRoundTrip(t, fs, "GET "+p+" HTTP/1.1\r\n\r\n")
+		assert.Contains(t, head, "HTTP/1.1 404 Not Found\r\n", p)
+		assert.NotEqual(t, "no", body, p)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	cases := map[string]string{
+		"/":               "/",
+		"":                "/",
+		"/a/b/../c":       "/a/c",
+		"/a/./b/":         "/a/b/",
+		"/../../etc":      "/etc",
+		"/%2e%2e/x":       "/x",
+		"/a%20b?q=1":      "/a b",
+		"relative/../../": "/",
+	}
+
+	for in, out := range cases {
+		clean, err := CleanPath([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, out, string(clean), in)
+	}
+
+	for _, in := range []string{"/%zz", "/%2", "/a%00b"} {
+		_, err := CleanPath([]byte(in))
+		assert.Equal(t, ErrBadPath, err, in)
+	}
+}
+
+func TestFileServerSniffsContentType(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "logo"), []byte("\x89PNG\x0D\x0A\x1A\x0Arest"), 0644))
+
+	head, _ := fileServerRoundTrip(t, NewFileServer(root), "GET /logo HTTP/1.1\r\n\r\n")
+	assert.Contains(t, head, "Content-Type: image/png\r\n")
+}
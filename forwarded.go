@@ -0,0 +1,200 @@
+package wildcat
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// Return the address of the client that sent the request. That is the
+// address of remote, unless remote is one of the trusted proxies, in which
+// case X-Forwarded-For is walked from the right (the entry added by the
+// nearest proxy) to the first address that isn't a trusted proxy.
+//
+// Entries further left are set by the client or untrusted hops and can be
+// forged, so they're only used when every hop after them is trusted.
+func (hp *HTTPParser) ClientIP(remote net.Addr, trusted []netip.Prefix) netip.Addr {
+	ip := addrIP(remote)
+
+	if !ip.IsValid() || !prefixesContain(trusted, ip) {
+		return ip
+	}
+
+	// Walk the headers and their entries from last to first.
+	for i := hp.numHeaders - 1; i >= 0; i-- {
+		h := &hp.Headers[i]
+		if h.class != hXForwardedFor {
+			continue
+		}
+
+		list := h.Value
+		for len(list) > 0 {
+			var entry []byte
+
+			if c := bytes.LastIndexByte(list, ','); c != -1 {
+				entry, list = list[c+1:], list[:c]
+			} else {
+				entry, list = list, nil
+			}
+
+			addr, err := netip.ParseAddr(string(bytes.TrimSpace(entry)))
+			if err != nil {
+				// We can't know who sent a garbled entry, so stop at the
+				// last address we could trust.
+				return ip
+			}
+
+			ip = addr.Unmap()
+			if !prefixesContain(trusted, ip) {
+				return ip
+			}
+		}
+	}
+
+	return ip
+}
+
+func addrIP(a net.Addr) netip.Addr {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		ip, _ := netip.AddrFromSlice(a.IP)
+		return ip.Unmap()
+	case *net.UDPAddr:
+		ip, _ := netip.AddrFromSlice(a.IP)
+		return ip.Unmap()
+	case nil:
+		return netip.Addr{}
+	}
+
+	ap, err := netip.ParseAddrPort(a.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return ap.Addr().Unmap()
+}
+
+func prefixesContain(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ForwardedElement is one element of a Forwarded header (RFC 7239). For and
+// By are nodes: an IP address with an optional port, "unknown", or an
+// obfuscated identifier such as "_hidden". Empty parameters are left out.
+type ForwardedElement struct {
+	For, By []byte
+	Proto   []byte
+	Host    []byte
+}
+
+var (
+	cForwardedFor   = []byte("for=")
+	cForwardedBy    = []byte("by=")
+	cForwardedProto = []byte("proto=")
+	cForwardedHost  = []byte("host=")
+	cHTTP           = []byte("http")
+	cHTTPS          = []byte("https")
+)
+
+// Append the element as it appears in a Forwarded header, such as
+// `for="[2001:db8::1]:4711";proto=https;host=example.com`. Values that
+// aren't tokens are quoted, and bare IPv6 addresses are put in brackets.
+func (e ForwardedElement) Append(dst []byte) []byte {
+	start := len(dst)
+
+	param := func(name, value []byte, node bool) {
+		if len(value) == 0 {
+			return
+		}
+
+		if len(dst) > start {
+			dst = append(dst, ';')
+		}
+
+		dst = append(dst, name...)
+
+		if node && bytes.IndexByte(value, ':') != -1 && value[0] != '[' {
+			if ip, err := netip.ParseAddr(string(value)); err == nil && ip.Is6() {
+				dst = append(dst, `"[`...)
+				dst = append(dst, value...)
+				dst = append(dst, `]"`...)
+				return
+			}
+		}
+
+		for _, c := range value {
+			if !isTokenChar(c) {
+				dst = appendQuotedString(dst, value)
+				return
+			}
+		}
+
+		dst = append(dst, value...)
+	}
+
+	param(cForwardedFor, e.For, true)
+	param(cForwardedBy, e.By, true)
+	param(cForwardedProto, e.Proto, false)
+	param(cForwardedHost, e.Host, false)
+
+	return dst
+}
+
+// Append the node identifier for a, for use as For or By: the IP address
+// and port, with IPv6 addresses in brackets.
+func AppendForwardedNode(dst []byte, a net.Addr) []byte {
+	ip := addrIP(a)
+	if !ip.IsValid() {
+		return append(dst, "unknown"...)
+	}
+
+	var port int
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		port = a.Port
+	case *net.UDPAddr:
+		port = a.Port
+	}
+
+	if ip.Is6() {
+		dst = append(dst, '[')
+		dst = ip.AppendTo(dst)
+		dst = append(dst, ']')
+	} else {
+		dst = ip.AppendTo(dst)
+	}
+
+	if port != 0 {
+		dst = append(dst, ':')
+		dst = strconv.AppendInt(dst, int64(port), 10)
+	}
+
+	return dst
+}
+
+// Return the element a proxy adds for a request hp it received on c.
+func forwardedElementFor(hp *HTTPParser, c net.Conn) ForwardedElement {
+	e := ForwardedElement{
+		For:   AppendForwardedNode(nil, c.RemoteAddr()),
+		Proto: cHTTP,
+		Host:  hp.Host(),
+	}
+
+	if sc, ok := c.(*serverConn); ok {
+		c = sc.Conn
+	}
+
+	if _, ok := c.(*tls.Conn); ok {
+		e.Proto = cHTTPS
+	}
+
+	return e
+}
@@ -0,0 +1,55 @@
+package wildcat
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardedElementAppend(t *testing.T) {
+	tests := []struct {
+		e    ForwardedElement
+		want string
+	}{
+		{ForwardedElement{For: []byte("192.0.2.60"), Proto: cHTTP, By: []byte("203.0.113.43")},
+			"for=192.0.2.60;by=203.0.113.43;proto=http"},
+		{ForwardedElement{For: []byte("2001:db8:cafe::17")},
+			`for="[2001:db8:cafe::17]"`},
+		{ForwardedElement{For: []byte("[2001:db8:cafe::17]:4711")},
+			`for="[2001:db8:cafe::17]:4711"`},
+		{ForwardedElement{For: []byte("192.0.2.43:47011"), Host: []byte("example.com")},
+			`for="192.0.2.43:47011";host=example.com`},
+		{ForwardedElement{For: []byte("_hidden"), By: []byte("unknown")},
+			"for=_hidden;by=unknown"},
+		{ForwardedElement{Host: []byte(`odd"host`)},
+			`host="odd\"host"`},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, string(tt.e.Append(nil)))
+	}
+}
+
+func TestAppendForwardedNode(t *testing.T) {
+	assert.Equal(t, "192.0.2.1:80",
+		string(AppendForwardedNode(nil, &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 80})))
+	assert.Equal(t, "[2001:db8::1]:443",
+		string(AppendForwardedNode(nil, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443})))
+	assert.Equal(t, "unknown", string(AppendForwardedNode(nil, nil)))
+}
+
+func TestReverseProxyAddsForwarded(t *testing.T) {
+	client, in := tcpPair(t)
+	defer client.Close()
+	defer in.Close()
+
+	hp := parseRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\nForwarded: for=192.0.2.60\r\nAccept: */*\r\n\r\n")
+
+	head := NewReverseProxy(nil).appendHeader(nil, hp, in)
+
+	node := string(AppendForwardedNode(nil, in.RemoteAddr()))
+
+	assert.Equal(t, "GET / HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\n"+
+		`Forwarded: for=192.0.2.60, for="`+node+`";proto=http;host=example.com`+"\r\n\r\n", string(head))
+}
@@ -0,0 +1,153 @@
+package wildcat
+
+// headerClass identifies a well-known header so that checks against it can
+// be done with an integer compare rather than comparing names.
+type headerClass uint8
+
+const (
+	hUnknown headerClass = iota
+	hAccept
+	hAcceptCharset
+	hAcceptEncoding
+	hAcceptLanguage
+	hAuthorization
+	hCacheControl
+	hConnection
+	hContentEncoding
+	hContentLength
+	hContentType
+	hCookie
+	hDate
+	hExpect
+	hForwarded
+	hHost
+	hIfMatch
+	hIfModifiedSince
+	hIfNoneMatch
+	hIfRange
+	hIfUnmodifiedSince
+	hOrigin
+	hPragma
+	hRange
+	hReferer
+	hSetCookie
+	hTe
+	hTrailer
+	hTransferEncoding
+	hUpgrade
+	hUserAgent
+	hVia
+	hXForwardedFor
+	hXForwardedHost
+	hXForwardedProto
+	hXRealIP
+	hXRequestID
+
+	numHeaderClasses
+)
+
+// Canonical names, indexed by headerClass.
+var headerClassNames = [numHeaderClasses][]byte{
+	hAccept:            []byte("Accept"),
+	hAcceptCharset:     []byte("Accept-Charset"),
+	hAcceptEncoding:    []byte("Accept-Encoding"),
+	hAcceptLanguage:    []byte("Accept-Language"),
+	hAuthorization:     []byte("Authorization"),
+	hCacheControl:      []byte("Cache-Control"),
+	hConnection:        []byte("Connection"),
+	hContentEncoding:   []byte("Content-Encoding"),
+	hContentLength:     []byte("Content-Length"),
+	hContentType:       []byte("Content-Type"),
+	hCookie:            []byte("Cookie"),
+	hDate:              []byte("Date"),
+	hExpect:            []byte("Expect"),
+	hForwarded:         []byte("Forwarded"),
+	hHost:              []byte("Host"),
+	hIfMatch:           []byte("If-Match"),
+	hIfModifiedSince:   []byte("If-Modified-Since"),
+	hIfNoneMatch:       []byte("If-None-Match"),
+	hIfRange:           []byte("If-Range"),
+	hIfUnmodifiedSince: []byte("If-Unmodified-Since"),
+	hOrigin:            []byte("Origin"),
+	hPragma:            []byte("Pragma"),
+	hRange:             []byte("Range"),
+	hReferer:           []byte("Referer"),
+	hSetCookie:         []byte("Set-Cookie"),
+	hTe:                []byte("Te"),
+	hTrailer:           []byte("Trailer"),
+	hTransferEncoding:  []byte("Transfer-Encoding"),
+	hUpgrade:           []byte("Upgrade"),
+	hUserAgent:         []byte("User-Agent"),
+	hVia:               []byte("Via"),
+	hXForwardedFor:     []byte("X-Forwarded-For"),
+	hXForwardedHost:    []byte("X-Forwarded-Host"),
+	hXForwardedProto:   []byte("X-Forwarded-Proto"),
+	hXRealIP:           []byte("X-Real-Ip"),
+	hXRequestID:        []byte("X-Request-Id"),
+}
+
+// The hash below was chosen so that every entry in headerClassNames lands in
+// its own slot. Changing the set of names means finding new constants;
+// init panics if there is a collision.
+const headerClassTableSize = 64
+
+var headerClassTable [headerClassTableSize]headerClass
+
+func headerClassHash(name []byte) int {
+	l := len(name)
+
+	h := l*5 +
+		int(toLower(name[0]))*61 +
+		int(toLower(name[l-1]))*7 +
+		int(toLower(name[l/2]))
+
+	return h & (headerClassTableSize - 1)
+}
+
+func init() {
+	for c := hUnknown + 1; c < numHeaderClasses; c++ {
+		slot := headerClassHash(headerClassNames[c])
+		if headerClassTable[slot] != hUnknown {
+			panic("wildcat: header class hash collision: " + string(headerClassNames[c]))
+		}
+
+		headerClassTable[slot] = c
+	}
+}
+
+func toLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+
+	return c
+}
+
+// Compare a and b ignoring ASCII case.
+func equalFoldASCII(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] && toLower(a[i]) != toLower(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Classify name against the well-known headers, case insensitively.
+func lookupHeaderClass(name []byte) headerClass {
+	if len(name) == 0 {
+		return hUnknown
+	}
+
+	c := headerClassTable[headerClassHash(name)]
+	if c == hUnknown || !equalFoldASCII(name, headerClassNames[c]) {
+		return hUnknown
+	}
+
+	return c
+}
@@ -0,0 +1,74 @@
+package wildcat
+
+import "github.com/vektra/errors"
+
+var ErrTooManyHeaders = errors.New("too many headers")
+
+// How the Headers slice grows when a request has more headers than it has
+// room for.
+type HeaderGrowth struct {
+	// Headers to make room for up front. 0 keeps the parser's current size.
+	Initial int
+
+	// Double the slice each time it fills, rather than adding
+	// DefaultHeaderSlice, so header-heavy requests copy it fewer times.
+	Geometric bool
+
+	// The most headers stored. Past it Parse returns ErrTooManyHeaders.
+	// Headers that aren't subscribed to don't count. 0 means no limit.
+	Max int
+}
+
+// Set how Headers grows. Call it before parsing, as growing to Initial
+// replaces Headers. The grown slice is kept across Reset, so once it's big
+// enough for the traffic seen, parsing stops allocating.
+func (hp *HTTPParser) SetHeaderGrowth(g HeaderGrowth) {
+	hp.growth = g
+
+	size := g.Initial
+	if g.Max > 0 && size > g.Max {
+		size = g.Max
+	}
+
+	if size > len(hp.Headers) {
+		hp.resizeHeaders(size)
+	}
+}
+
+func (hp *HTTPParser) growHeaders() {
+	n := hp.TotalHeaders + DefaultHeaderSlice
+	if hp.growth.Geometric {
+		n = hp.TotalHeaders * 2
+	}
+
+	if max := hp.growth.Max; max > 0 && n > max {
+		n = max
+	}
+
+	if n > hp.TotalHeaders {
+		hp.resizeHeaders(n)
+	}
+}
+
+// Make Headers n long, reusing its backing array if it's big enough.
+func (hp *HTTPParser) resizeHeaders(n int) {
+	if n <= cap(hp.Headers) {
+		hp.Headers = hp.Headers[:n]
+	} else {
+		newHeaders := make([]header, n)
+		copy(newHeaders, hp.Headers)
+		hp.Headers = newHeaders
+	}
+
+	hp.TotalHeaders = n
+}
+
+// The length Reset trims Headers to.
+func (hp *HTTPParser) resetHeaderLen() int {
+	keep := len(hp.subscribeHeader) + 1
+	if hp.growth.Initial > keep {
+		keep = hp.growth.Initial
+	}
+
+	return keep
+}
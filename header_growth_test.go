@@ -0,0 +1,78 @@
+package wildcat
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func manyHeaders(n int) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("GET / HTTP/1.1\r\n")
+
+	for i := 0; i < n; i++ {
+		buf.WriteString("X-Header-" + strconv.Itoa(i) + ": value\r\n")
+	}
+
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func TestHeaderGrowthGeometric(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetHeaderGrowth(HeaderGrowth{Initial: 2, Geometric: true})
+
+	_, err := hp.Parse(manyHeaders(40))
+	require.NoError(t, err)
+
+	assert.Equal(t, 40, hp.HeaderCount())
+	assert.Equal(t, 64, hp.TotalHeaders)
+
+	name, _ := hp.HeaderAt(39)
+	assert.Equal(t, "X-Header-39", string(name))
+}
+
+func TestHeaderGrowthMax(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetHeaderGrowth(HeaderGrowth{Geometric: true, Max: 10})
+
+	_, err := hp.Parse(manyHeaders(10))
+	require.NoError(t, err)
+	assert.Equal(t, 10, hp.HeaderCount())
+
+	_, err = hp.Parse(manyHeaders(11))
+	assert.Equal(t, ErrTooManyHeaders, err)
+	assert.Equal(t, 10, hp.TotalHeaders)
+
+	// Unsubscribed headers don't count.
+	hp.SubscribeAllHeader(false)
+	hp.SubscribeHeader([]byte("X-Header-3"))
+
+	_, err = hp.Parse(manyHeaders(20))
+	require.NoError(t, err)
+	assert.Equal(t, 1, hp.HeaderCount())
+}
+
+func TestHeaderGrowthReusedAcrossReset(t *testing.T) {
+	req := manyHeaders(30)
+
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse(req)
+	require.NoError(t, err)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		hp.Reset()
+
+		if _, err := hp.Parse(req); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, 30, hp.HeaderCount())
+}
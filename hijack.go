@@ -0,0 +1,65 @@
+package wildcat
+
+import (
+	"net"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrHijacked      = errors.New("connection has been hijacked")
+	ErrNotHijackable = errors.New("connection can't be hijacked")
+)
+
+// Hijacker is implemented by the connections Server passes to handlers.
+type Hijacker interface {
+	// Take over the connection: the server stops handling it as HTTP and
+	// won't close it. Returns the raw connection and the bytes already read
+	// after the request's headers, which belong to the caller.
+	Hijack() (net.Conn, []byte, error)
+}
+
+// Take over c, the connection a request was received on, so the handler
+// can switch to another protocol such as WebSocket or h2c. See Hijacker.
+func Hijack(c net.Conn) (net.Conn, []byte, error) {
+	h, ok := c.(Hijacker)
+	if !ok {
+		return nil, nil, ErrNotHijackable
+	}
+
+	return h.Hijack()
+}
+
+func (c *serverConn) Hijack() (net.Conn, []byte, error) {
+	c.mu.Lock()
+
+	if c.hijacked {
+		c.mu.Unlock()
+		return nil, nil, ErrHijacked
+	}
+
+	if c.timedOut {
+		c.mu.Unlock()
+		return nil, nil, ErrHandlerTimeout
+	}
+
+	c.hijacked = true
+	c.srv.trackConn(c, false)
+
+	buffered := c.buffered
+	c.buffered = nil
+
+	c.mu.Unlock()
+
+	// Outside the lock, so the callback can use the connection.
+	c.setState(StateHijacked)
+
+	return c.Conn, buffered, nil
+}
+
+func (c *serverConn) isHijacked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hijacked
+}
@@ -0,0 +1,77 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerHijack(t *testing.T) {
+	type result struct {
+		buffered []byte
+		err      error
+	}
+
+	results := make(chan result, 1)
+
+	s := &Server{
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			raw, buffered, err := Hijack(c)
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+
+			_, err = c.Write([]byte("too late"))
+			results <- result{buffered: append([]byte(nil), buffered...), err: err}
+
+			// Answer after the handler returns, to show the server left
+			// the connection alone.
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				raw.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\nping"))
+				raw.Close()
+			}()
+		}),
+	}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nUpgrade: custom\r\nConnection: Upgrade\r\n\r\nhello"))
+	require.NoError(t, err)
+
+	res := <-results
+	assert.Equal(t, ErrHijacked, res.err)
+	assert.Equal(t, "hello", string(res.buffered))
+
+	// A hijacked connection doesn't hold up shutdown.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+
+	r := bufio.NewReader(c)
+	readResponse(t, r)
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(rest))
+}
+
+func TestHijackNotHijackable(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	_, _, err := Hijack(server)
+	assert.Equal(t, ErrNotHijackable, err)
+}
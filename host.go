@@ -0,0 +1,117 @@
+package wildcat
+
+import (
+	"bytes"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrMissingHost   = errors.New("missing Host header")
+	ErrDuplicateHost = errors.New("more than one Host header")
+	ErrHostMismatch  = errors.New("Host header doesn't match request target")
+)
+
+var (
+	cSchemeSep = []byte("://")
+	cPort80    = []byte(":80")
+	cPort443   = []byte(":443")
+)
+
+// Set whether Parse checks the Host header once the headers are read: an
+// HTTP/1.1 request must have exactly one, no request may have more than
+// one, and for an absolute-form target (RFC 9112, section 3.2.2) it must
+// name the same authority as the target. Requests that disagree are a
+// common way to smuggle requests past a proxy or poison a cache.
+func (hp *HTTPParser) SetCheckHost(check bool) {
+	hp.checkHost = check
+}
+
+// Finish a successful parse of h headers ending at n.
+func (hp *HTTPParser) endHeaders(h, n int) (int, error) {
+	hp.numHeaders = h
+
+	// A message with both is framed differently by recipients that honor
+	// one or the other (RFC 9112, section 6.1).
+	if hp.sawContentLength && hp.sawTransferEncoding {
+		hp.errOffset = 0
+		return 0, ErrBadProto
+	}
+
+	if hp.checkHost {
+		if err := hp.validateHost(); err != nil {
+			hp.errOffset = 0
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+func (hp *HTTPParser) validateHost() error {
+	switch {
+	case hp.hostCount > 1:
+		return ErrDuplicateHost
+	case hp.hostCount == 0 && bytes.Equal(hp.Version, cHTTP11):
+		return ErrMissingHost
+	}
+
+	scheme, authority, ok := targetAuthority(hp.Path)
+	if !ok {
+		return nil
+	}
+
+	if !sameAuthority(scheme, authority, hp.Host()) {
+		return errors.Context(ErrHostMismatch, string(authority))
+	}
+
+	return nil
+}
+
+// Split an absolute-form target into its scheme and authority, without
+// any userinfo.
+func targetAuthority(target []byte) (scheme, authority []byte, ok bool) {
+	if len(target) == 0 || target[0] == '/' {
+		return nil, nil, false
+	}
+
+	i := bytes.Index(target, cSchemeSep)
+	if i <= 0 {
+		return nil, nil, false
+	}
+
+	scheme, authority = target[:i], target[i+len(cSchemeSep):]
+
+	for j, c := range authority {
+		if c == '/' || c == '?' || c == '#' {
+			authority = authority[:j]
+			break
+		}
+	}
+
+	if at := bytes.LastIndexByte(authority, '@'); at != -1 {
+		authority = authority[at+1:]
+	}
+
+	return scheme, authority, true
+}
+
+// Report whether authority and host name the same host and port, treating
+// a missing port as the scheme's default.
+func sameAuthority(scheme, authority, host []byte) bool {
+	var port []byte
+
+	switch {
+	case equalFoldASCII(scheme, cHTTP):
+		port = cPort80
+	case equalFoldASCII(scheme, cHTTPS):
+		port = cPort443
+	}
+
+	if port != nil {
+		authority = bytes.TrimSuffix(authority, port)
+		host = bytes.TrimSuffix(host, port)
+	}
+
+	return equalFoldASCII(authority, host)
+}
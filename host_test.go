@@ -0,0 +1,62 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+func TestCheckHost(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetCheckHost(true)
+
+	ok := []string{
+		"GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"GET / HTTP/1.0\r\n\r\n",
+		"GET http://example.com/a?b HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"GET http://EXAMPLE.com:80/ HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"GET https://user@example.com HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+		"CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+	}
+
+	for _, req := range ok {
+		_, err := hp.Parse([]byte(req))
+		assert.NoError(t, err, req)
+	}
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\n\r\n"))
+	assert.Equal(t, ErrMissingHost, err)
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.1\r\nHost: a.example\r\nHost: b.example\r\n\r\n"))
+	assert.Equal(t, ErrDuplicateHost, err)
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.0\r\nHost: a.example\r\nHost: a.example\r\n\r\n"))
+	assert.Equal(t, ErrDuplicateHost, err)
+
+	_, err = hp.Parse([]byte("GET http://a.example/ HTTP/1.1\r\nHost: b.example\r\n\r\n"))
+	assert.Equal(t, ErrHostMismatch, errors.Cause(err))
+
+	_, err = hp.Parse([]byte("GET http://a.example:8080/ HTTP/1.1\r\nHost: a.example\r\n\r\n"))
+	assert.Equal(t, ErrHostMismatch, errors.Cause(err))
+}
+
+func TestCheckHostOff(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET http://a.example/ HTTP/1.1\r\nHost: b.example\r\nHost: c.example\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "b.example", string(hp.Host()))
+}
+
+func TestCheckHostUnsubscribed(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SubscribeAllHeader(false)
+	hp.SetCaptureHeaders(0)
+	hp.SetCheckHost(true)
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\nHost: a.example\r\nHost: b.example\r\n\r\n"))
+	assert.Equal(t, ErrDuplicateHost, err)
+}
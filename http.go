@@ -2,8 +2,8 @@ package wildcat
 
 import (
 	"bytes"
+	"context"
 	"io"
-	"strconv"
 
 	"github.com/vektra/errors"
 )
@@ -13,21 +13,58 @@ const OptimalBufferSize = 1500
 type header struct {
 	Name  []byte
 	Value []byte
+
+	class headerClass
 }
 
 type HTTPParser struct {
 	subscribeHeader       [][]byte
 	subscribeAllHeader    bool
+	internHeaderNames     bool
+	redactHeaders         [][]byte
+	parseErrorHook        ParseErrorHook
+	errOffset             int
+	allowedMethods        [][]byte
+	maxMethodLength       int
+	stopHeaders           []header
+	resume                parseResume
+	resuming              bool
+	progress              ParseProgress
+	strict                *ValidationTables
+	growth                HeaderGrowth
 	Method, Path, Version []byte
 
 	Headers      []header
 	TotalHeaders int
 
-	host     []byte
-	hostRead bool
+	numHeaders int
+
+	host      []byte
+	hostRead  bool
+	hostCount int
+	checkHost bool
+
+	capture  CaptureHeaders
+	captured [numCaptureSlots][]byte
+
+	// Every Set-Cookie value, which can't be combined into one.
+	setCookies [][]byte
 
 	contentLength     int64
 	contentLengthRead bool
+
+	// Whether parseHeaders saw Content-Length and Transfer-Encoding headers.
+	sawContentLength    bool
+	sawTransferEncoding bool
+
+	chunkExtensions      ChunkExtensions
+	maxChunkExtensionLen int
+
+	sizedBody   *sizedBodyReader
+	chunkedBody *ChunkedReader
+
+	ctx       context.Context
+	requestID []byte
 }
 
 const DefaultHeaderSlice = 4
@@ -44,6 +81,7 @@ func NewSizedHTTPParser(size int) *HTTPParser {
 		TotalHeaders:       size,
 		contentLength:      -1,
 		subscribeAllHeader: true,
+		capture:            DefaultCaptureHeaders,
 	}
 }
 
@@ -51,6 +89,10 @@ var (
 	ErrBadProto    = errors.New("bad protocol")
 	ErrMissingData = errors.New("missing data")
 	ErrUnsupported = errors.New("unsupported http feature")
+
+	ErrHeaderTooLarge = errors.New("request header too large")
+
+	ErrBadContentLength = errors.New("invalid Content-Length")
 )
 
 const (
@@ -72,6 +114,56 @@ const (
 // Returns the number of bytes used by the header (thus where the body begins).
 // Also can return ErrUnsupported if an HTTP feature is detected but not supported.
 func (hp *HTTPParser) Parse(input []byte) (int, error) {
+	n, err := hp.parse(input)
+	if err != nil && err != ErrMissingData && err != ErrStoppedAtHeader && hp.parseErrorHook != nil {
+		hp.reportParseError(input, err)
+	}
+
+	return n, err
+}
+
+// Parse just the request line at the start of buf, for callers that
+// already know where the request line and headers are, such as replay
+// tools. Returns the length of the line, including its line ending.
+// Headers from an earlier parse are cleared.
+func (hp *HTTPParser) ParseRequestLine(buf []byte) (int, error) {
+	hp.resetParse()
+
+	n, err := hp.parseRequestLine(buf)
+	if err != nil && err != ErrMissingData && hp.parseErrorHook != nil {
+		hp.reportParseError(buf, err)
+	}
+
+	return n, err
+}
+
+// Parse header lines from the start of buf up to and including the blank
+// line that ends them, keeping the request line already parsed. Returns
+// the number of bytes used, so buf[n:] is the body.
+func (hp *HTTPParser) ParseHeaders(buf []byte) (int, error) {
+	hp.resetParse()
+
+	n, err := hp.parseHeaders(buf, parseResume{slot: -1, state: eNextHeader})
+	if err != nil && err != ErrMissingData && err != ErrStoppedAtHeader && hp.parseErrorHook != nil {
+		hp.reportParseError(buf, err)
+	}
+
+	return n, err
+}
+
+func (hp *HTTPParser) parse(input []byte) (int, error) {
+	hp.resetParse()
+
+	headers, err := hp.parseRequestLine(input)
+	if err != nil {
+		return 0, err
+	}
+
+	return hp.parseHeaders(input, parseResume{at: headers, slot: -1, state: eNextHeader})
+}
+
+// Parse the request line, returning where the headers start.
+func (hp *HTTPParser) parseRequestLine(input []byte) (int, error) {
 	var headers int
 	var path int
 	var ok bool
@@ -87,10 +179,22 @@ method:
 			path = i + 1
 			break method
 		}
+
+		if hp.maxMethodLength > 0 && i >= hp.maxMethodLength {
+			hp.errOffset = i
+			return 0, &MethodError{Method: input[:i], Status: StatusNotImplemented}
+		}
 	}
 
 	if !ok {
-		return 0, ErrMissingData
+		return 0, hp.missingRequestLine(total)
+	}
+
+	if hp.allowedMethods != nil {
+		if err := hp.checkMethod(); err != nil {
+			hp.errOffset = 0
+			return 0, err
+		}
 	}
 
 	var version int
@@ -109,7 +213,7 @@ path:
 	}
 
 	if !ok {
-		return 0, ErrMissingData
+		return 0, hp.missingRequestLine(total)
 	}
 
 	var readN bool
@@ -133,6 +237,7 @@ loop:
 			}
 		case true:
 			if c != '\n' {
+				hp.errOffset = i
 				return 0, errors.Context(ErrBadProto, "missing newline in version")
 			}
 			headers = i + 1
@@ -142,50 +247,86 @@ loop:
 	}
 
 	if !ok {
-		return 0, ErrMissingData
+		return 0, hp.missingRequestLine(total)
 	}
 
-	var h int
+	return headers, nil
+}
 
+// Parse the header lines of input, starting from the position in r.
+func (hp *HTTPParser) parseHeaders(input []byte, r parseResume) (int, error) {
 	var headerName []byte
 
-	state := eNextHeader
+	// h headers are stored so far. kept tracks whether the last header line
+	// was stored, so that multiline continuations of skipped headers are
+	// dropped too. slot is the capture slot the line went to, or -1. stop is
+	// set once the current line is a header to stop at.
+	h, kept, slot, stop := r.h, r.kept, r.slot, r.stop
 
-	start := headers
+	state := r.state
 
-	for i := headers; i < total; i++ {
+	// Where the current line started, and the state at that point, for
+	// when the input ends partway through it.
+	line := r
+
+	start := r.at
+	total := len(input)
+
+	for i := r.at; i < total; i++ {
 		switch state {
 		case eNextHeader:
+			line = parseResume{at: i, h: h, kept: kept, slot: slot, state: eNextHeader}
+
 			switch input[i] {
 			case '\r':
 				state = eNextHeaderN
 			case '\n':
-				return i + 1, nil
+				return hp.endHeaders(h, i+1)
 			case ' ', '\t':
 				state = eMLHeaderStart
 			default:
+				if hp.strict != nil && !hp.strict.Name[input[i]] {
+					hp.errOffset = i
+					return 0, ErrBadHeaderByte
+				}
+
 				start = i
 				state = eHeader
 			}
 		case eNextHeaderN:
 			if input[i] != '\n' {
+				hp.errOffset = i
 				return 0, ErrBadProto
 			}
 
-			return i + 1, nil
+			return hp.endHeaders(h, i+1)
 		case eHeader:
 			if input[i] == ':' {
 				headerName = input[start:i]
 				state = eHeaderValueSpace
+			} else if hp.strict != nil && !hp.strict.Name[input[i]] {
+				hp.errOffset = i
+				return 0, ErrBadHeaderByte
 			}
 		case eHeaderValueSpace:
 			switch input[i] {
 			case ' ', '\t':
 				continue
+			case '\r', '\n':
+				// An empty value, which ends here.
+				start = i
+			default:
+				if hp.strict != nil && !hp.strict.Value[input[i]] {
+					hp.errOffset = i
+					return 0, ErrBadHeaderByte
+				}
+
+				start = i
+				state = eHeaderValue
+				continue
 			}
 
-			start = i
-			state = eHeaderValue
+			fallthrough
 		case eHeaderValue:
 			switch input[i] {
 			case '\r':
@@ -193,34 +334,79 @@ loop:
 			case '\n':
 				state = eNextHeader
 			default:
+				if hp.strict != nil && !hp.strict.Value[input[i]] {
+					hp.errOffset = i
+					return 0, ErrBadHeaderByte
+				}
+
 				continue
 			}
-			if headerName[0] == 'C' && bytes.Equal(headerName, cContentLength) {
-				i, err := strconv.ParseInt(string(input[start:i]), 10, 0)
-				if err == nil {
-					hp.contentLength = i
+			class := lookupHeaderClass(headerName)
+
+			stop = len(hp.stopHeaders) > 0 && hp.stopAt(class, headerName)
+
+			slot = hp.captureHeader(class, input[start:i])
+
+			switch class {
+			case hHost:
+				hp.hostCount++
+			case hSetCookie:
+				hp.setCookies = append(hp.setCookies, input[start:i])
+			case hTransferEncoding:
+				hp.sawTransferEncoding = true
+			}
+
+			if class == hContentLength {
+				size, ok := parseContentLength(input[start:i])
+
+				// Repeats are only allowed if they agree (RFC 9112,
+				// section 6.3), or the body's length depends on which
+				// one a recipient picks.
+				if !ok || (hp.sawContentLength && size != hp.contentLength) {
+					hp.errOffset = start
+					return 0, ErrBadContentLength
 				}
+
+				hp.contentLength = size
 				hp.contentLengthRead = true
-				hp.addHeader(h, headerName, input[start:i])
+				hp.sawContentLength = true
+				kept = true
 			} else if hp.subscribeAllHeader {
-				hp.addHeader(h, headerName, input[start:i])
+				kept = true
 			} else {
+				kept = false
 				for _, b := range hp.subscribeHeader {
 					if headerName[0] == b[0] {
 						if bytes.Equal(headerName, b) {
-							hp.addHeader(h, headerName, input[start:i])
+							kept = true
 							break
 						}
 					}
 				}
 			}
-			h++
+
+			if kept {
+				if !hp.addHeader(h, class, headerName, input[start:i]) {
+					hp.errOffset = start
+					return 0, ErrTooManyHeaders
+				}
+				h++
+			}
+
+			if stop && state == eNextHeader {
+				return hp.stopAfter(parseResume{at: i + 1, h: h, kept: kept, slot: slot, state: eNextHeader})
+			}
 		case eHeaderValueN:
 			if input[i] != '\n' {
+				hp.errOffset = i
 				return 0, ErrBadProto
 			}
 			state = eNextHeader
 
+			if stop {
+				return hp.stopAfter(parseResume{at: i + 1, h: h, kept: kept, slot: slot, state: eNextHeader})
+			}
+
 		case eMLHeaderStart:
 			switch input[i] {
 			case ' ', '\t':
@@ -236,43 +422,102 @@ loop:
 			case '\n':
 				state = eNextHeader
 			default:
+				if hp.strict != nil && !hp.strict.Value[input[i]] {
+					hp.errOffset = i
+					return 0, ErrBadHeaderByte
+				}
+
+				continue
+			}
+
+			if !kept && slot < 0 {
 				continue
 			}
 
-			cur := hp.Headers[h-1].Value
+			var cur []byte
+			if kept {
+				cur = hp.Headers[h-1].Value
+			} else {
+				cur = hp.captured[slot]
+			}
 
 			newheader := make([]byte, len(cur)+1+(i-start))
 			copy(newheader, cur)
 			copy(newheader[len(cur):], []byte(" "))
 			copy(newheader[len(cur)+1:], input[start:i])
 
-			hp.Headers[h-1].Value = newheader
+			if kept {
+				hp.Headers[h-1].Value = newheader
+			}
+
+			if slot >= 0 {
+				hp.captured[slot] = newheader
+			}
 		}
 	}
 
-	return 0, ErrMissingData
+	// A line that ended in CR just needs its LF; it's stored already, so
+	// pick up right there rather than redoing it. Likewise if the input
+	// ends right after a line.
+	switch state {
+	case eHeaderValueN, eNextHeader:
+		line = parseResume{at: total, h: h, kept: kept, slot: slot, state: state, stop: stop}
+	}
+
+	return 0, hp.missingHeaders(line, state == eNextHeader || state == eNextHeaderN, total)
+}
+
+// Clear what the last parse found.
+func (hp *HTTPParser) resetParse() {
+	hp.numHeaders = 0
+	hp.hostRead = false
+	hp.hostCount = 0
+	hp.contentLengthRead = false
+	hp.contentLength = -1
+	hp.sawContentLength = false
+	hp.sawTransferEncoding = false
+	hp.sizedBody = nil
+	hp.chunkedBody = nil
+	hp.captured = [numCaptureSlots][]byte{}
+	hp.setCookies = hp.setCookies[:0]
+	hp.resuming = false
 }
 
-func (hp *HTTPParser) addHeader(headerIndex int, headerName, headerValue []byte) {
-	hp.Headers[headerIndex] = header{headerName, headerValue}
+// Store a header at headerIndex, growing Headers as needed. Returns false
+// if the header limit set by SetHeaderGrowth has been reached.
+func (hp *HTTPParser) addHeader(headerIndex int, class headerClass, headerName, headerValue []byte) bool {
+	if hp.growth.Max > 0 && headerIndex >= hp.growth.Max {
+		return false
+	}
+
+	if hp.internHeaderNames && class != hUnknown {
+		headerName = headerClassNames[class]
+	}
+
+	hp.Headers[headerIndex] = header{headerName, headerValue, class}
 	if headerIndex+1 == hp.TotalHeaders {
-		newHeaders := make([]header, hp.TotalHeaders+DefaultHeaderSlice)
-		copy(newHeaders, hp.Headers)
-		hp.Headers = newHeaders
-		hp.TotalHeaders += DefaultHeaderSlice
+		hp.growHeaders()
 	}
+
+	return true
 }
 
 func (hp *HTTPParser) Reset() {
-	for _, h := range hp.Headers {
-		h.Name = nil
-		h.Value = nil
+	for i := range hp.Headers {
+		hp.Headers[i] = header{}
 	}
+	hp.numHeaders = 0
 	hp.hostRead = false
 	hp.contentLengthRead = false
 	hp.contentLength = -1
-	if len(hp.Headers) > len(hp.subscribeHeader)+1 {
-		hp.Headers = hp.Headers[:len(hp.subscribeHeader)+1]
+	hp.sizedBody = nil
+	hp.chunkedBody = nil
+	hp.captured = [numCaptureSlots][]byte{}
+	// The backing array is kept, so growing again up to its size
+	// doesn't allocate.
+	if keep := hp.resetHeaderLen(); len(hp.Headers) > keep {
+		hp.Headers = hp.Headers[:keep]
+		hp.TotalHeaders = len(hp.Headers)
 	}
 }
 
@@ -284,15 +529,44 @@ func (hp *HTTPParser) SubscribeHeader(name []byte) {
 	hp.subscribeHeader = append(hp.subscribeHeader, name)
 }
 
+// When enabled, the names of well-known headers are replaced with shared
+// canonical slices (see InternHeaderName) as they're parsed, so that keeping
+// a name around doesn't keep the input buffer alive.
+func (hp *HTTPParser) InternHeaderNames(intern bool) {
+	hp.internHeaderNames = intern
+}
+
+// Return the number of headers stored by the last Parse.
+func (hp *HTTPParser) HeaderCount() int {
+	return hp.numHeaders
+}
+
+// Return the name and value of the i'th stored header, in the order they
+// appeared in the request. Returns nil, nil if i is out of range.
+func (hp *HTTPParser) HeaderAt(i int) (name, value []byte) {
+	if i < 0 || i >= hp.numHeaders {
+		return nil, nil
+	}
+
+	h := &hp.Headers[i]
+	return h.Name, h.Value
+}
+
 // Return a value of a header matching name.
 func (hp *HTTPParser) FindHeader(name []byte) []byte {
-	for _, header := range hp.Headers {
+	if class := lookupHeaderClass(name); class != hUnknown {
+		return hp.findHeaderClass(class)
+	}
+
+	headers := hp.Headers[:hp.numHeaders]
+
+	for _, header := range headers {
 		if bytes.Equal(header.Name, name) {
 			return header.Value
 		}
 	}
 
-	for _, header := range hp.Headers {
+	for _, header := range headers {
 		if bytes.EqualFold(header.Name, name) {
 			return header.Value
 		}
@@ -301,11 +575,26 @@ func (hp *HTTPParser) FindHeader(name []byte) []byte {
 	return nil
 }
 
+// Return the first value of a well-known header.
+func (hp *HTTPParser) findHeaderClass(class headerClass) []byte {
+	if slot := hp.captureSlot(class); slot >= 0 {
+		return hp.captured[slot]
+	}
+
+	for i := 0; i < hp.numHeaders; i++ {
+		if hp.Headers[i].class == class {
+			return hp.Headers[i].Value
+		}
+	}
+
+	return nil
+}
+
 // Return all values of a header matching name.
 func (hp *HTTPParser) FindAllHeaders(name []byte) [][]byte {
 	var headers [][]byte
 
-	for _, header := range hp.Headers {
+	for _, header := range hp.Headers[:hp.numHeaders] {
 		if bytes.EqualFold(header.Name, name) {
 			headers = append(headers, header.Value)
 		}
@@ -314,8 +603,6 @@ func (hp *HTTPParser) FindAllHeaders(name []byte) [][]byte {
 	return headers
 }
 
-var cHost = []byte("Host")
-
 // Return the value of the Host header
 func (hp *HTTPParser) Host() []byte {
 	if hp.hostRead {
@@ -323,12 +610,10 @@ func (hp *HTTPParser) Host() []byte {
 	}
 
 	hp.hostRead = true
-	hp.host = hp.FindHeader(cHost)
+	hp.host = hp.findHeaderClass(hHost)
 	return hp.host
 }
 
-var cContentLength = []byte("Content-Length")
-
 // Return the value of the Content-Length header.
 // A value of -1 indicates the header was not set.
 func (hp *HTTPParser) ContentLength() int64 {
@@ -336,11 +621,10 @@ func (hp *HTTPParser) ContentLength() int64 {
 		return hp.contentLength
 	}
 
-	header := hp.FindHeader(cContentLength)
+	header := hp.findHeaderClass(hContentLength)
 	if header != nil {
-		i, err := strconv.ParseInt(string(header), 10, 0)
-		if err == nil {
-			hp.contentLength = i
+		if size, ok := parseContentLength(header); ok {
+			hp.contentLength = size
 		}
 	}
 
@@ -348,8 +632,74 @@ func (hp *HTTPParser) ContentLength() int64 {
 	return hp.contentLength
 }
 
+// Parse a Content-Length value, which must be 1*DIGIT (RFC 9110, section
+// 8.6). Signs, spaces inside the number and overflow are rejected, as a
+// value other parsers read differently is a smuggling vector.
+func parseContentLength(b []byte) (int64, bool) {
+	b = bytes.TrimRight(b, " \t")
+
+	n, ok := parseBindUint(b, 63)
+	return int64(n), ok
+}
+
+// Set how chunk extensions are handled when decoding chunked bodies
+// returned by BodyReader. max limits the size of skipped extensions per
+// chunk; 0 means DefaultMaxChunkExtensionBytes.
+func (hp *HTTPParser) SetChunkExtensions(policy ChunkExtensions, max int) {
+	hp.chunkExtensions = policy
+	hp.maxChunkExtensionLen = max
+}
+
+// Return a reader for the request body. Chunked bodies are decoded.
 func (hp *HTTPParser) BodyReader(rest []byte, in io.ReadCloser) io.ReadCloser {
-	return BodyReader(hp.ContentLength(), rest, in)
+	if te := hp.findHeaderClass(hTransferEncoding); te != nil && isChunked(te) {
+		cr := NewChunkedReader(rest, in)
+		cr.Extensions = hp.chunkExtensions
+
+		if hp.maxChunkExtensionLen > 0 {
+			cr.MaxExtensionBytes = hp.maxChunkExtensionLen
+		}
+
+		hp.chunkedBody = cr
+		return cr
+	}
+
+	br := BodyReader(hp.ContentLength(), rest, in)
+
+	if sb, ok := br.(*sizedBodyReader); ok {
+		hp.sizedBody = sb
+	}
+
+	return br
+}
+
+// After the request has been handled, return the bytes of rest (as passed
+// to the handler) that follow the body, and whether the body has been
+// consumed so that they're usable as the start of the next request.
+func (hp *HTTPParser) bodyLeftover(rest []byte) ([]byte, bool) {
+	if hp.sizedBody != nil {
+		return hp.sizedBody.leftover()
+	}
+
+	if hp.chunkedBody != nil {
+		return hp.chunkedBody.leftover()
+	}
+
+	if hp.findHeaderClass(hTransferEncoding) != nil {
+		return nil, false
+	}
+
+	size := hp.ContentLength()
+	if size <= 0 {
+		return rest, true
+	}
+
+	// The handler didn't read the body; skip it if we have all of it.
+	if int64(len(rest)) >= size {
+		return rest[size:], true
+	}
+
+	return nil, false
 }
 
 var cGet = []byte("GET")
@@ -381,3 +731,18 @@ func (hp *HTTPParser) PostOrPut() bool {
 func (hp *HTTPParser) MethodExistBody() bool {
 	return hp.PostOrPut() || hp.Patch()
 }
+
+// Report whether method is idempotent (RFC 9110, section 9.2.2), so that a
+// request using it can safely be retried.
+func IsIdempotent(method []byte) bool {
+	switch string(method) {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+func (hp *HTTPParser) IsIdempotent() bool {
+	return IsIdempotent(hp.Method)
+}
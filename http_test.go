@@ -143,3 +143,251 @@ func TestFindAllHeaders(t *testing.T) {
 	assert.Equal(t, []byte("foo"), hp.FindHeader(bar))
 	assert.Equal(t, [][]byte{[]byte("foo"), []byte("quz")}, hp.FindAllHeaders(bar))
 }
+
+func TestHeaderAt(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse(simple3Headers)
+	require.NoError(t, err)
+
+	require.Equal(t, 3, hp.HeaderCount())
+
+	name, value := hp.HeaderAt(1)
+	assert.Equal(t, []byte("Date"), name)
+	assert.Equal(t, []byte("foobar"), value)
+
+	name, value = hp.HeaderAt(3)
+	assert.Nil(t, name)
+	assert.Nil(t, value)
+}
+
+func TestHeaderAtSkipsUnsubscribed(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SubscribeAllHeader(false)
+	hp.SubscribeHeader([]byte("Accept"))
+
+	_, err := hp.Parse(simple3Headers)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, hp.HeaderCount())
+
+	name, value := hp.HeaderAt(0)
+	assert.Equal(t, []byte("Accept"), name)
+	assert.Equal(t, []byte("these/that"), value)
+}
+
+func TestHeaderCountAfterReparse(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse(simple3Headers)
+	require.NoError(t, err)
+
+	_, err = hp.Parse(simpleHeaders)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, hp.HeaderCount())
+	assert.Nil(t, hp.FindHeader([]byte("Date")))
+}
+
+func TestInternHeaderNames(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.InternHeaderNames(true)
+
+	input := []byte("GET / HTTP/1.0\r\nhost: cookie.com\r\nX-Custom: yes\r\n\r\n")
+
+	_, err := hp.Parse(input)
+	require.NoError(t, err)
+
+	name, _ := hp.HeaderAt(0)
+	assert.Equal(t, []byte("Host"), name)
+	assert.True(t, &name[0] == &InternHeaderName([]byte("HOST"))[0])
+
+	name, _ = hp.HeaderAt(1)
+	assert.Equal(t, []byte("X-Custom"), name)
+	assert.True(t, &name[0] == &input[34])
+}
+
+func TestHeaderClassTable(t *testing.T) {
+	for c := hUnknown + 1; c < numHeaderClasses; c++ {
+		name := headerClassNames[c]
+
+		assert.Equal(t, c, lookupHeaderClass(name))
+		assert.Equal(t, c, lookupHeaderClass(bytes.ToLower(name)))
+	}
+
+	assert.Equal(t, hUnknown, lookupHeaderClass([]byte("X-Custom")))
+	assert.Equal(t, hUnknown, lookupHeaderClass([]byte("Hose")))
+	assert.Equal(t, hUnknown, lookupHeaderClass(nil))
+}
+
+func TestParseLowercaseContentLength(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("POST / HTTP/1.1\r\ncontent-length: 12\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(12), hp.ContentLength())
+	assert.Equal(t, []byte("12"), hp.FindHeader([]byte("Content-Length")))
+}
+
+func TestParseRejectsBadContentLength(t *testing.T) {
+	for _, v := range []string{"-5", "+5", "abc", "5 5", "0x10", "99999999999999999999", ""} {
+		hp := NewHTTPParser()
+
+		_, err := hp.Parse([]byte("POST / HTTP/1.1\r\nContent-Length: " + v + "\r\n\r\n"))
+		assert.Equal(t, ErrBadContentLength, err, v)
+	}
+
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("POST / HTTP/1.1\r\nContent-Length: 5 \r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), hp.ContentLength())
+}
+
+func TestParseDuplicateContentLength(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\nContent-Length: 50\r\n\r\n"))
+	assert.Equal(t, ErrBadContentLength, err)
+
+	_, err = hp.Parse([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), hp.ContentLength())
+}
+
+func TestParseContentLengthWithTransferEncoding(t *testing.T) {
+	hp := NewHTTPParser()
+
+	for _, req := range []string{
+		"POST / HTTP/1.1\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n",
+	} {
+		_, err := hp.Parse([]byte(req))
+		assert.Equal(t, ErrBadProto, err, "%q", req)
+	}
+
+	_, err := hp.Parse([]byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n"))
+	require.NoError(t, err)
+}
+
+func TestSnapshot(t *testing.T) {
+	hp := NewHTTPParser()
+
+	input := append([]byte(nil), simple3Headers...)
+
+	_, err := hp.Parse(input)
+	require.NoError(t, err)
+
+	req := hp.Snapshot()
+
+	for i := range input {
+		input[i] = 'x'
+	}
+
+	assert.Equal(t, []byte("GET"), req.Method)
+	assert.Equal(t, []byte("/"), req.Path)
+	assert.Equal(t, []byte("HTTP/1.0"), req.Version)
+	assert.Equal(t, 3, req.HeaderCount())
+	assert.Equal(t, []byte("cookie.com"), req.FindHeader([]byte("host")))
+	assert.Equal(t, []byte("these/that"), req.FindHeader([]byte("Accept")))
+
+	name, value := req.HeaderAt(1)
+	assert.Equal(t, []byte("Date"), name)
+	assert.Equal(t, []byte("foobar"), value)
+}
+
+func TestAppendJSON(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET /a\"b HTTP/1.1\r\nHost: cookie.com\r\nCookie: secret\r\nX-Bin: \x01\xff\r\n\r\n"))
+	require.NoError(t, err)
+
+	out, err := hp.MarshalJSON()
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"method":"GET","path":"/a\"b","version":"HTTP/1.1","headers":[["Host","cookie.com"],["Cookie","[REDACTED]"],["X-Bin","\u0001�"]]}`, string(out))
+
+	hp.RedactHeaders([]byte("host"))
+
+	out = hp.AppendJSON([]byte("req="))
+	assert.Equal(t, `req={"method":"GET","path":"/a\"b","version":"HTTP/1.1","headers":[["Host","[REDACTED]"],["Cookie","secret"],["X-Bin","\u0001�"]]}`, string(out))
+}
+
+func TestParseErrorHook(t *testing.T) {
+	hp := NewHTTPParser()
+
+	var got *ParseError
+	hp.SetParseErrorHook(func(pe *ParseError) {
+		got = pe
+	})
+
+	_, err := hp.Parse(short)
+	assert.Equal(t, ErrMissingData, err)
+	assert.Nil(t, got)
+
+	input := []byte("GET / HTTP/1.1\r\nHost: cookie.com\rX\r\n\r\n")
+
+	_, err = hp.Parse(input)
+	assert.Equal(t, ErrBadProto, err)
+
+	require.NotNil(t, got)
+	assert.Equal(t, ErrBadProto, got.Err)
+	assert.Equal(t, 33, got.Offset)
+	assert.Equal(t, 0, got.InputOffset)
+	assert.Equal(t, input, got.Input)
+	assert.Contains(t, got.Dump(), "|GET / HTTP/1.1..|")
+}
+
+func TestParseRequestLineAndHeaders(t *testing.T) {
+	hp := NewHTTPParser()
+
+	n, err := hp.ParseRequestLine(simple3Headers)
+	require.NoError(t, err)
+
+	assert.Equal(t, len("GET / HTTP/1.0\r\n"), n)
+	assert.Equal(t, []byte("GET"), hp.Method)
+	assert.Equal(t, []byte("/"), hp.Path)
+	assert.Equal(t, []byte("HTTP/1.0"), hp.Version)
+	assert.Equal(t, 0, hp.HeaderCount())
+
+	headers := simple3Headers[n:]
+
+	m, err := hp.ParseHeaders(headers)
+	require.NoError(t, err)
+
+	assert.Equal(t, len(headers), m)
+	assert.Equal(t, 3, hp.HeaderCount())
+	assert.Equal(t, []byte("cookie.com"), hp.Host())
+	assert.Equal(t, []byte("GET"), hp.Method)
+
+	_, err = hp.ParseRequestLine([]byte("GET /"))
+	assert.Equal(t, ErrMissingData, err)
+
+	_, err = hp.ParseHeaders([]byte("Host: cookie.com\r\n"))
+	assert.Equal(t, ErrMissingData, err)
+
+	// Pieces can come from different buffers.
+	_, err = hp.ParseHeaders([]byte("\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, hp.HeaderCount())
+}
+
+func BenchmarkParseRequestLine(b *testing.B) {
+	hp := NewHTTPParser()
+
+	for i := 0; i < b.N; i++ {
+		hp.ParseRequestLine(simple3Headers)
+	}
+}
+
+func BenchmarkParseHeaders(b *testing.B) {
+	hp := NewHTTPParser()
+
+	n, _ := hp.ParseRequestLine(simple3Headers)
+	headers := simple3Headers[n:]
+
+	for i := 0; i < b.N; i++ {
+		hp.ParseHeaders(headers)
+	}
+}
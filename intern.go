@@ -0,0 +1,13 @@
+package wildcat
+
+// Return the canonical, shared slice for name if it is a well-known header
+// (matched case insensitively). Otherwise name is returned as is.
+//
+// The returned slice must not be modified.
+func InternHeaderName(name []byte) []byte {
+	if c := lookupHeaderClass(name); c != hUnknown {
+		return headerClassNames[c]
+	}
+
+	return name
+}
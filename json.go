@@ -0,0 +1,124 @@
+package wildcat
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Headers whose values are replaced by AppendJSON unless the parser has been
+// given its own list via RedactHeaders.
+var DefaultRedactedHeaders = [][]byte{
+	[]byte("Authorization"),
+	[]byte("Cookie"),
+	[]byte("Proxy-Authorization"),
+	[]byte("Set-Cookie"),
+}
+
+var cRedacted = []byte("[REDACTED]")
+
+// Set the headers whose values AppendJSON should redact, replacing
+// DefaultRedactedHeaders for this parser. Pass no names to disable redaction.
+func (hp *HTTPParser) RedactHeaders(names ...[]byte) {
+	if names == nil {
+		names = [][]byte{}
+	}
+
+	hp.redactHeaders = names
+}
+
+func (hp *HTTPParser) redacted(name []byte) bool {
+	list := hp.redactHeaders
+	if list == nil {
+		list = DefaultRedactedHeaders
+	}
+
+	for _, r := range list {
+		if bytes.EqualFold(name, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Append a JSON object describing the request to dst, for use in structured
+// logs. Headers are emitted as [name, value] pairs in wire order, with the
+// values of sensitive headers redacted.
+func (hp *HTTPParser) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"method":`...)
+	dst = appendJSONString(dst, hp.Method)
+	dst = append(dst, `,"path":`...)
+	dst = appendJSONString(dst, hp.Path)
+	dst = append(dst, `,"version":`...)
+	dst = appendJSONString(dst, hp.Version)
+	dst = append(dst, `,"headers":[`...)
+
+	for i := 0; i < hp.numHeaders; i++ {
+		h := &hp.Headers[i]
+
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+
+		dst = append(dst, '[')
+		dst = appendJSONString(dst, h.Name)
+		dst = append(dst, ',')
+
+		if hp.redacted(h.Name) {
+			dst = appendJSONString(dst, cRedacted)
+		} else {
+			dst = appendJSONString(dst, h.Value)
+		}
+
+		dst = append(dst, ']')
+	}
+
+	return append(dst, "]}"...)
+}
+
+// Implements json.Marshaler using AppendJSON.
+func (hp *HTTPParser) MarshalJSON() ([]byte, error) {
+	return hp.AppendJSON(nil), nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// Append s as a quoted JSON string. Invalid UTF-8 is replaced with U+FFFD.
+func appendJSONString(dst, s []byte) []byte {
+	dst = append(dst, '"')
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20 || c == 0x7f:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, "\ufffd"...)
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+
+		i += size
+	}
+
+	return append(dst, '"')
+}
@@ -0,0 +1,55 @@
+package wildcat
+
+import (
+	"net"
+	"os"
+
+	"github.com/vektra/errors"
+)
+
+var ErrNotSocket = errors.New("file exists and is not a socket")
+
+// ListenUnix listens on a unix domain socket at path and sets the socket
+// file's permissions to mode. A socket file left behind by a previous process
+// is removed first, but any other kind of file at path is an error. The file
+// is removed again when the listener is closed.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Context(ErrNotSocket, path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// ListenAndServeUnix listens on the unix domain socket at path and then
+// calls Serve to handle requests on incoming connections.
+func (s *Server) ListenAndServeUnix(path string, mode os.FileMode) error {
+	l, err := ListenUnix(path, mode)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+func ListenAndServeUnix(path string, mode os.FileMode, handler Handler) error {
+	s := &Server{Handler: handler}
+
+	return s.ListenAndServeUnix(path, mode)
+}
@@ -0,0 +1,93 @@
+package wildcat
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrNotImplemented   = errors.New("method not implemented")
+)
+
+// MethodError is returned by Parse for a request whose method isn't in the
+// set given to SetAllowedMethods, or is longer than SetMaxMethodLength
+// allows. Status is the response to send: 405 for a standard method the
+// server doesn't accept, 501 for anything else.
+type MethodError struct {
+	Method []byte
+	Status int
+}
+
+func (e *MethodError) Error() string {
+	return e.Unwrap().Error() + ": " + strconv.Quote(string(e.Method))
+}
+
+// Return ErrMethodNotAllowed or ErrNotImplemented, matching Status.
+func (e *MethodError) Unwrap() error {
+	if e.Status == StatusMethodNotAllowed {
+		return ErrMethodNotAllowed
+	}
+
+	return ErrNotImplemented
+}
+
+// Restrict the methods Parse accepts; others fail with a *MethodError. No
+// methods means any is accepted.
+func (hp *HTTPParser) SetAllowedMethods(methods ...[]byte) {
+	hp.allowedMethods = methods
+}
+
+// Limit the length of the method, so that garbage without a space fails
+// right away instead of waiting for more data. 0 means no limit.
+func (hp *HTTPParser) SetMaxMethodLength(n int) {
+	hp.maxMethodLength = n
+}
+
+// The methods defined by RFC 9110 and RFC 5789.
+var standardMethods = [][]byte{
+	[]byte("GET"), []byte("HEAD"), []byte("POST"), []byte("PUT"), []byte("DELETE"),
+	[]byte("CONNECT"), []byte("OPTIONS"), []byte("TRACE"), []byte("PATCH"),
+}
+
+func containsMethod(methods [][]byte, method []byte) bool {
+	for _, m := range methods {
+		if bytes.Equal(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (hp *HTTPParser) checkMethod() error {
+	if len(hp.allowedMethods) == 0 || containsMethod(hp.allowedMethods, hp.Method) {
+		return nil
+	}
+
+	status := StatusNotImplemented
+	if containsMethod(standardMethods, hp.Method) {
+		status = StatusMethodNotAllowed
+	}
+
+	return &MethodError{Method: hp.Method, Status: status}
+}
+
+// Append the response for a MethodError. A 405 lists allowed in Allow.
+func appendMethodErrorResponse(dst []byte, e *MethodError, allowed [][]byte) []byte {
+	dst = appendStatusLine(dst, e.Status)
+
+	if e.Status == StatusMethodNotAllowed {
+		dst = append(dst, cAllow...)
+		dst = append(dst, cColon...)
+		dst = append(dst, bytes.Join(allowed, []byte(", "))...)
+		dst = append(dst, cCRLF...)
+	}
+
+	dst = append(dst, cConnClose...)
+	dst = append(dst, "Content-Length: 0\r\n\r\n"...)
+
+	return dst
+}
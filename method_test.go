@@ -0,0 +1,67 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserAllowedMethods(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetAllowedMethods([]byte("GET"), []byte("POST"))
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	_, err = hp.Parse([]byte("DELETE / HTTP/1.1\r\n\r\n"))
+	me, ok := err.(*MethodError)
+	require.True(t, ok)
+	assert.Equal(t, StatusMethodNotAllowed, me.Status)
+	assert.Equal(t, "DELETE", string(me.Method))
+	assert.Equal(t, ErrMethodNotAllowed, me.Unwrap())
+
+	_, err = hp.Parse([]byte("BREW / HTTP/1.1\r\n\r\n"))
+	me, ok = err.(*MethodError)
+	require.True(t, ok)
+	assert.Equal(t, StatusNotImplemented, me.Status)
+}
+
+func TestParserMaxMethodLength(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetMaxMethodLength(7)
+
+	_, err := hp.Parse([]byte("OPTIONS * HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	// Fails without waiting for the rest of the request.
+	_, err = hp.Parse([]byte("\x16\x03\x01\x02\x00\x01\x00\x01"))
+	me, ok := err.(*MethodError)
+	require.True(t, ok)
+	assert.Equal(t, StatusNotImplemented, me.Status)
+
+	_, err = hp.Parse([]byte("GE"))
+	assert.Equal(t, ErrMissingData, err)
+}
+
+func TestServerAllowedMethods(t *testing.T) {
+	s := &Server{Handler: handlerFunc(helloHandler), AllowedMethods: []string{"GET", "HEAD"}}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("PUT / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	head := readResponse(t, bufio.NewReader(c))
+	assert.Contains(t, head, "HTTP/1.1 405 Method Not Allowed\r\n")
+	assert.Contains(t, head, "Allow: GET, HEAD\r\n")
+	assert.Contains(t, head, "Connection: close\r\n")
+}
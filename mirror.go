@@ -0,0 +1,136 @@
+package wildcat
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// The default MaxBody of a Mirror.
+const DefaultMirrorMaxBody = 64 << 10
+
+// The default Timeout of a Mirror.
+const DefaultMirrorTimeout = 5 * time.Second
+
+// The default MaxInFlight of a Mirror.
+const DefaultMirrorMaxInFlight = 64
+
+// Mirror copies a share of the requests a ReverseProxy handles to a shadow
+// upstream, such as a canary of a new backend. Responses from the shadow
+// are read and thrown away, and failures to reach it are ignored, so it
+// never affects the client.
+type Mirror struct {
+	// The shadow upstream, as given to net.Dial.
+	Network, Addr string
+
+	// The percentage of requests to copy, from 0 to 100.
+	Percent float64
+
+	// Requests are copied along with bodies of up to MaxBody bytes, which
+	// are read in full before being proxied. Requests with larger or
+	// chunked bodies aren't copied. 0 means DefaultMirrorMaxBody.
+	MaxBody int
+
+	// How long a copied request may take, including reading the response.
+	// 0 means DefaultMirrorTimeout.
+	Timeout time.Duration
+
+	// How many copied requests may be outstanding at once. Requests that
+	// would go over are not copied, so a slow shadow can't pile up
+	// connections. 0 means DefaultMirrorMaxInFlight.
+	MaxInFlight int
+
+	inFlight int32
+}
+
+func (m *Mirror) sample() bool {
+	return m.Percent > 0 && rand.Float64()*100 < m.Percent
+}
+
+// Take a slot for a copied request, reporting whether one was free.
+func (m *Mirror) acquire() bool {
+	max := m.MaxInFlight
+	if max <= 0 {
+		max = DefaultMirrorMaxInFlight
+	}
+
+	if atomic.AddInt32(&m.inFlight, 1) > int32(max) {
+		atomic.AddInt32(&m.inFlight, -1)
+		return false
+	}
+
+	return true
+}
+
+func (m *Mirror) release() {
+	atomic.AddInt32(&m.inFlight, -1)
+}
+
+// Read the body of the request from rest and c so that it can be copied.
+// Returns the bytes to forward in place of rest, the body, and whether the
+// request can be mirrored.
+func (m *Mirror) readBody(hp *HTTPParser, rest []byte, c net.Conn) (forward, body []byte, ok bool) {
+	if hp.TransferEncoding() != nil {
+		return rest, nil, false
+	}
+
+	n := hp.ContentLength()
+	if n <= 0 {
+		return rest, nil, true
+	}
+
+	max := m.MaxBody
+	if max == 0 {
+		max = DefaultMirrorMaxBody
+	}
+
+	if n > int64(max) {
+		return rest, nil, false
+	}
+
+	body = make([]byte, n)
+
+	read, err := io.ReadFull(hp.BodyReader(rest, c), body)
+	if err != nil {
+		// Pass on what arrived; the upstream sees the request cut short,
+		// as it would have without mirroring.
+		return body[:read], nil, false
+	}
+
+	after, _ := hp.bodyLeftover(rest)
+
+	return append(body[:n:n], after...), body, true
+}
+
+// Send a request to the shadow upstream and discard the response, then
+// give back the slot taken by acquire.
+func (m *Mirror) send(head, body []byte) {
+	defer m.release()
+
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = DefaultMirrorTimeout
+	}
+
+	conn, err := net.DialTimeout(m.Network, m.Addr, timeout)
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(append(head, body...)); err != nil {
+		return
+	}
+
+	// Nothing else is coming, so let the shadow close once it's answered.
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+
+	io.Copy(io.Discard, conn)
+}
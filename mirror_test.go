@@ -0,0 +1,113 @@
+package wildcat
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticRedirector string
+
+func (s staticRedirector) Redirect(hp *HTTPParser) (string, string, error) {
+	return "tcp", string(s), nil
+}
+
+// Accept one connection on a new listener and send what was read on it,
+// up to the client closing its side or n bytes.
+func captureUpstream(t *testing.T, n int, reply string) (string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	got := make(chan string, 1)
+
+	go func() {
+		defer l.Close()
+
+		c, err := l.Accept()
+		if err != nil {
+			got <- ""
+			return
+		}
+		defer c.Close()
+
+		c.SetDeadline(time.Now().Add(2 * time.Second))
+
+		buf, _ := io.ReadAll(io.LimitReader(c, int64(n)))
+		c.Write([]byte(reply))
+		got <- string(buf)
+	}()
+
+	return l.Addr().String(), got
+}
+
+func TestReverseProxyMirror(t *testing.T) {
+	req := "POST /x HTTP/1.1\r\nHost: example.com\r\nContent-Length: 11\r\n\r\n"
+
+	// Forwarded adds a header of unknown length, so read the mirrored
+	// request until the shadow side closes it.
+	shadow, shadowGot := captureUpstream(t, 1<<20, "HTTP/1.1 500 Nope\r\nContent-Length: 0\r\n\r\n")
+
+	rp := &ReverseProxy{OmitForwarded: true, Mirror: &Mirror{Network: "tcp", Addr: shadow, Percent: 100}}
+
+	up, upGot := captureUpstream(t, len(req)+11, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	rp.dir = staticRedirector(up)
+
+	s := &Server{Handler: rp}
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte(req + "hello"))
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.Write([]byte(" world"))
+	require.NoError(t, err)
+
+	assert.Equal(t, req+"hello world", <-upGot)
+	assert.Equal(t, req+"hello world", <-shadowGot)
+
+	c.SetReadDeadline(time.Now().Add(time.Second))
+
+	buf := make([]byte, 64)
+	n, _ := io.ReadAtLeast(c, buf, 1)
+	assert.Contains(t, string(buf[:n]), "200 OK")
+}
+
+func TestMirrorSkipsLargeBodies(t *testing.T) {
+	hp := parseRequest(t, "POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\n")
+
+	m := &Mirror{MaxBody: 4}
+
+	forward, body, ok := m.readBody(hp, []byte("0123"), nil)
+	assert.False(t, ok)
+	assert.Nil(t, body)
+	assert.Equal(t, "0123", string(forward))
+
+	hp = parseRequest(t, "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n")
+
+	_, _, ok = m.readBody(hp, nil, nil)
+	assert.False(t, ok)
+}
+
+func TestMirrorMaxInFlight(t *testing.T) {
+	m := &Mirror{Network: "tcp", Addr: "127.0.0.1:0", MaxInFlight: 2}
+
+	assert.True(t, m.acquire())
+	assert.True(t, m.acquire())
+	assert.False(t, m.acquire())
+
+	// A finished copy gives its slot back, even when the shadow is down.
+	m.send([]byte("GET / HTTP/1.1\r\n\r\n"), nil)
+	assert.True(t, m.acquire())
+	assert.False(t, m.acquire())
+}
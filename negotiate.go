@@ -0,0 +1,287 @@
+package wildcat
+
+import "bytes"
+
+// Offers lists the representations a resource can produce along each
+// negotiation dimension, in the server's order of preference. A dimension
+// with no offers isn't negotiated.
+type Offers struct {
+	Types     [][]byte // media types such as "application/json"
+	Encodings [][]byte // content codings such as "gzip" or "identity"
+	Languages [][]byte // language tags such as "en-US"
+	Charsets  [][]byte // such as "utf-8"
+}
+
+// Negotiated is the outcome of Negotiate. A field is nil if its dimension
+// wasn't offered or nothing offered was acceptable.
+type Negotiated struct {
+	Type     []byte
+	Encoding []byte
+	Language []byte
+	Charset  []byte
+
+	// The value for the response's Vary header, naming the request headers
+	// consulted. Nil if nothing was negotiated. Shared, do not modify.
+	Vary []byte
+}
+
+const (
+	varyAccept = 1 << iota
+	varyAcceptEncoding
+	varyAcceptLanguage
+	varyAcceptCharset
+)
+
+// Vary values for every combination of negotiated dimensions, rendered up
+// front so Negotiate doesn't have to build them.
+var varyValues [16][]byte
+
+func init() {
+	names := []string{"Accept", "Accept-Encoding", "Accept-Language", "Accept-Charset"}
+
+	for mask := 1; mask < len(varyValues); mask++ {
+		var b []byte
+		for i, name := range names {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+
+			if b != nil {
+				b = append(b, ", "...)
+			}
+			b = append(b, name...)
+		}
+
+		varyValues[mask] = b
+	}
+}
+
+var cIdentity = []byte("identity")
+
+// Select the best offer in each dimension using the request's Accept,
+// Accept-Encoding, Accept-Language and Accept-Charset headers. When a
+// header is absent the first offer is chosen. The identity coding is
+// acceptable unless explicitly refused. ok is false if some offered
+// dimension has nothing acceptable, in which case the caller would normally
+// respond with 406.
+func Negotiate(hp *HTTPParser, offers *Offers) (n Negotiated, ok bool) {
+	ok = true
+	mask := 0
+
+	if len(offers.Types) > 0 {
+		mask |= varyAccept
+		n.Type = hp.negotiate(hAccept, offers.Types, matchMediaRange, nil)
+		ok = ok && n.Type != nil
+	}
+
+	if len(offers.Encodings) > 0 {
+		mask |= varyAcceptEncoding
+		n.Encoding = hp.negotiate(hAcceptEncoding, offers.Encodings, matchToken, cIdentity)
+		ok = ok && n.Encoding != nil
+	}
+
+	if len(offers.Languages) > 0 {
+		mask |= varyAcceptLanguage
+		n.Language = hp.negotiate(hAcceptLanguage, offers.Languages, matchLanguageRange, nil)
+		ok = ok && n.Language != nil
+	}
+
+	if len(offers.Charsets) > 0 {
+		mask |= varyAcceptCharset
+		n.Charset = hp.negotiate(hAcceptCharset, offers.Charsets, matchToken, nil)
+		ok = ok && n.Charset != nil
+	}
+
+	n.Vary = varyValues[mask]
+	return n, ok
+}
+
+// A match function reports how specifically rng matches offer, or -1 if it
+// doesn't match at all.
+type matchFunc func(rng, offer []byte) int
+
+// Pick the offer with the highest quality in the headers of the given
+// class. Quality ties go to the earlier offer. implicit, if set, is an offer
+// that is acceptable unless a range says otherwise, but is only chosen when
+// nothing explicitly listed is.
+func (hp *HTTPParser) negotiate(class headerClass, offers [][]byte, match matchFunc, implicit []byte) []byte {
+	present := false
+
+	var best []byte
+	bestQ := 0
+
+	for _, offer := range offers {
+		spec, q := -1, 0
+
+		for i := 0; i < hp.numHeaders; i++ {
+			h := &hp.Headers[i]
+			if h.class != class {
+				continue
+			}
+
+			present = true
+
+			for list := h.Value; len(list) > 0; {
+				var item []byte
+				item, list = nextListItem(list)
+
+				rng, iq := splitQuality(item)
+				if len(rng) == 0 {
+					continue
+				}
+
+				if s := match(rng, offer); s > spec {
+					spec, q = s, iq
+				}
+			}
+		}
+
+		if spec == -1 && implicit != nil && bytes.EqualFold(offer, implicit) {
+			q = 1
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	if !present {
+		return offers[0]
+	}
+
+	return best
+}
+
+// Return the first element of a comma separated list, trimmed, and the rest
+// of the list. Commas inside quoted strings don't split.
+func nextListItem(list []byte) (item, rest []byte) {
+	quoted := false
+
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case ',':
+			if !quoted {
+				return bytes.TrimSpace(list[:i]), list[i+1:]
+			}
+		}
+	}
+
+	return bytes.TrimSpace(list), nil
+}
+
+// Split the q parameter off a list item, returning the item without its
+// parameters and the quality in thousandths (1000 if unspecified, 0 if
+// malformed).
+func splitQuality(item []byte) ([]byte, int) {
+	q := 1000
+
+	value := ParseParameterizedValue(item, func(name, value []byte) bool {
+		if len(name) == 1 && (name[0] == 'q' || name[0] == 'Q') {
+			q = parseQValue(value)
+			return false
+		}
+
+		return true
+	})
+
+	return value, q
+}
+
+// Parse a qvalue, "0" to "1" with up to three decimals, into thousandths.
+func parseQValue(b []byte) int {
+	if len(b) == 0 || len(b) > 5 || (b[0] != '0' && b[0] != '1') {
+		return 0
+	}
+
+	q := int(b[0]-'0') * 1000
+
+	if len(b) > 1 {
+		if b[1] != '.' {
+			return 0
+		}
+
+		scale := 100
+		for _, c := range b[2:] {
+			if !isDigit(c) {
+				return 0
+			}
+
+			q += int(c-'0') * scale
+			scale /= 10
+		}
+	}
+
+	if q > 1000 {
+		return 0
+	}
+
+	return q
+}
+
+// "*/*" matches anything, "type/*" any subtype, otherwise it must be exact.
+func matchMediaRange(rng, offer []byte) int {
+	if semi := bytes.IndexByte(offer, ';'); semi != -1 {
+		offer = bytes.TrimSpace(offer[:semi])
+	}
+
+	if len(rng) == 3 && rng[0] == '*' && rng[1] == '/' && rng[2] == '*' {
+		return 0
+	}
+
+	slash := bytes.IndexByte(rng, '/')
+	oslash := bytes.IndexByte(offer, '/')
+	if slash == -1 || oslash == -1 {
+		return -1
+	}
+
+	if !equalFoldASCII(rng[:slash], offer[:oslash]) {
+		return -1
+	}
+
+	if sub := rng[slash+1:]; len(sub) == 1 && sub[0] == '*' {
+		return 1
+	}
+
+	if equalFoldASCII(rng[slash+1:], offer[oslash+1:]) {
+		return 2
+	}
+
+	return -1
+}
+
+// "*" matches anything, otherwise the tokens must match ignoring case.
+func matchToken(rng, offer []byte) int {
+	if len(rng) == 1 && rng[0] == '*' {
+		return 0
+	}
+
+	if equalFoldASCII(rng, offer) {
+		return 1
+	}
+
+	return -1
+}
+
+// Basic filtering from RFC 4647: a range matches a tag equal to it or
+// that starts with it followed by "-". Longer ranges are more specific.
+func matchLanguageRange(rng, offer []byte) int {
+	if len(rng) == 1 && rng[0] == '*' {
+		return 0
+	}
+
+	if len(offer) < len(rng) || !equalFoldASCII(rng, offer[:len(rng)]) {
+		return -1
+	}
+
+	if len(offer) > len(rng) && offer[len(rng)] != '-' {
+		return -1
+	}
+
+	return len(rng)
+}
@@ -0,0 +1,94 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bs(s ...string) [][]byte {
+	out := make([][]byte, len(s))
+	for i, v := range s {
+		out[i] = []byte(v)
+	}
+	return out
+}
+
+func negotiateRequest(t *testing.T, headers string, offers *Offers) (Negotiated, bool) {
+	hp := NewHTTPParser()
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\n" + headers + "\r\n"))
+	require.NoError(t, err)
+
+	return Negotiate(hp, offers)
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := &Offers{
+		Types:     bs("application/json", "text/html"),
+		Encodings: bs("gzip", "identity"),
+		Languages: bs("en-US", "fr"),
+		Charsets:  bs("utf-8"),
+	}
+
+	n, ok := negotiateRequest(t,
+		"Accept: text/*;q=0.8, application/json;q=0.5, */*;q=0.1\r\n"+
+			"Accept-Encoding: br, gzip;q=0.9\r\n"+
+			"Accept-Language: fr-CA, fr;q=0.9, en;q=0.8\r\n"+
+			"Accept-Charset: UTF-8\r\n", offers)
+
+	assert.True(t, ok)
+	assert.Equal(t, []byte("text/html"), n.Type)
+	assert.Equal(t, []byte("gzip"), n.Encoding)
+	assert.Equal(t, []byte("fr"), n.Language)
+	assert.Equal(t, []byte("utf-8"), n.Charset)
+	assert.Equal(t, []byte("Accept, Accept-Encoding, Accept-Language, Accept-Charset"), n.Vary)
+}
+
+func TestNegotiateDefaults(t *testing.T) {
+	offers := &Offers{
+		Types:     bs("application/json", "text/html"),
+		Encodings: bs("gzip", "identity"),
+	}
+
+	n, ok := negotiateRequest(t, "", offers)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("application/json"), n.Type)
+	assert.Equal(t, []byte("gzip"), n.Encoding)
+	assert.Equal(t, []byte("Accept, Accept-Encoding"), n.Vary)
+
+	// identity is implicitly acceptable.
+	n, ok = negotiateRequest(t, "Accept-Encoding: br\r\n", offers)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("identity"), n.Encoding)
+
+	n, ok = negotiateRequest(t, "Accept-Encoding: br, *;q=0\r\n", offers)
+	assert.False(t, ok)
+	assert.Nil(t, n.Encoding)
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	n, ok := negotiateRequest(t, "Accept: image/png\r\n", &Offers{Types: bs("text/html")})
+	assert.False(t, ok)
+	assert.Nil(t, n.Type)
+	assert.Equal(t, []byte("Accept"), n.Vary)
+
+	_, ok = negotiateRequest(t, "Accept: text/html;q=0\r\n", &Offers{Types: bs("text/html")})
+	assert.False(t, ok)
+
+	// The more specific range wins even though it's listed later.
+	n, ok = negotiateRequest(t, "Accept: text/*, text/plain;q=0\r\n", &Offers{Types: bs("text/plain", "text/csv")})
+	assert.True(t, ok)
+	assert.Equal(t, []byte("text/csv"), n.Type)
+}
+
+func TestParseQValue(t *testing.T) {
+	cases := map[string]int{
+		"1": 1000, "1.0": 1000, "1.000": 1000, "0": 0, "0.5": 500,
+		"0.123": 123, "0.0001": 0, "1.5": 0, "2": 0, "": 0, "0.x": 0,
+	}
+
+	for in, q := range cases {
+		assert.Equal(t, q, parseQValue([]byte(in)), in)
+	}
+}
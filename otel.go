@@ -0,0 +1,352 @@
+package wildcat
+
+import (
+	"bytes"
+
+	"github.com/vektra/errors"
+)
+
+// AttributeAppender receives span attributes extracted from a request. It's
+// implemented by the caller to bridge into whatever tracing library is in use,
+// so wildcat doesn't need to depend on one.
+type AttributeAppender interface {
+	AppendAttribute(key string, value []byte)
+}
+
+// Attribute names from the OpenTelemetry HTTP semantic conventions.
+const (
+	AttrHTTPRequestMethod         = "http.request.method"
+	AttrHTTPRequestMethodOriginal = "http.request.method_original"
+	AttrURLPath                   = "url.path"
+	AttrURLQuery                  = "url.query"
+	AttrServerAddress             = "server.address"
+	AttrServerPort                = "server.port"
+	AttrUserAgentOriginal         = "user_agent.original"
+	AttrNetworkProtocolName       = "network.protocol.name"
+	AttrNetworkProtocolVersion    = "network.protocol.version"
+)
+
+var (
+	cOtherMethod = []byte("_OTHER")
+	cHTTPProto   = []byte("http")
+
+	knownMethods = [][]byte{
+		[]byte("GET"),
+		[]byte("HEAD"),
+		[]byte("POST"),
+		[]byte("PUT"),
+		[]byte("DELETE"),
+		[]byte("CONNECT"),
+		[]byte("OPTIONS"),
+		[]byte("TRACE"),
+		[]byte("PATCH"),
+	}
+)
+
+// Append the standard HTTP server span attributes for the parsed request to
+// out. Attributes that aren't present in the request are skipped. The values
+// alias the parser's input.
+func (hp *HTTPParser) AppendSpanAttributes(out AttributeAppender) {
+	known := false
+	for _, m := range knownMethods {
+		if bytes.Equal(hp.Method, m) {
+			known = true
+			break
+		}
+	}
+
+	if known {
+		out.AppendAttribute(AttrHTTPRequestMethod, hp.Method)
+	} else {
+		out.AppendAttribute(AttrHTTPRequestMethod, cOtherMethod)
+		out.AppendAttribute(AttrHTTPRequestMethodOriginal, hp.Method)
+	}
+
+	path, query := hp.Path, []byte(nil)
+	if q := bytes.IndexByte(path, '?'); q != -1 {
+		path, query = path[:q], path[q+1:]
+	}
+
+	out.AppendAttribute(AttrURLPath, path)
+	if len(query) > 0 {
+		out.AppendAttribute(AttrURLQuery, query)
+	}
+
+	if host := hp.Host(); len(host) > 0 {
+		addr, port := splitHostPort(host)
+		out.AppendAttribute(AttrServerAddress, addr)
+		if len(port) > 0 {
+			out.AppendAttribute(AttrServerPort, port)
+		}
+	}
+
+	if ua := hp.findHeaderClass(hUserAgent); ua != nil {
+		out.AppendAttribute(AttrUserAgentOriginal, ua)
+	}
+
+	if v := bytes.IndexByte(hp.Version, '/'); v != -1 {
+		out.AppendAttribute(AttrNetworkProtocolName, cHTTPProto)
+		out.AppendAttribute(AttrNetworkProtocolVersion, hp.Version[v+1:])
+	}
+}
+
+// Split a Host header value into host and port, removing the brackets from
+// an IPv6 literal.
+func splitHostPort(host []byte) ([]byte, []byte) {
+	if len(host) > 0 && host[0] == '[' {
+		end := bytes.IndexByte(host, ']')
+		if end == -1 {
+			return host, nil
+		}
+
+		if end+1 < len(host) && host[end+1] == ':' {
+			return host[1:end], host[end+2:]
+		}
+
+		return host[1:end], nil
+	}
+
+	if c := bytes.LastIndexByte(host, ':'); c != -1 {
+		return host[:c], host[c+1:]
+	}
+
+	return host, nil
+}
+
+var (
+	ErrBadTraceparent = errors.New("malformed traceparent")
+	ErrBadTracestate  = errors.New("malformed tracestate")
+	ErrNoTraceparent  = errors.New("no traceparent header")
+)
+
+// TraceParent is a parsed W3C Trace Context traceparent header.
+type TraceParent struct {
+	Version  byte
+	TraceID  [16]byte
+	ParentID [8]byte
+	Flags    byte
+}
+
+// Report whether the sampled flag is set.
+func (tp *TraceParent) Sampled() bool {
+	return tp.Flags&1 == 1
+}
+
+// Parse a traceparent header value, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(value []byte) (TraceParent, error) {
+	var tp TraceParent
+
+	value = bytes.TrimSpace(value)
+
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return tp, ErrBadTraceparent
+	}
+
+	if !decodeLowerHex(tp.TraceID[:], value[3:35]) ||
+		!decodeLowerHex(tp.ParentID[:], value[36:52]) {
+		return tp, ErrBadTraceparent
+	}
+
+	var b [1]byte
+
+	if !decodeLowerHex(b[:], value[0:2]) || b[0] == 0xff {
+		return tp, ErrBadTraceparent
+	}
+	tp.Version = b[0]
+
+	if !decodeLowerHex(b[:], value[53:55]) {
+		return tp, ErrBadTraceparent
+	}
+	tp.Flags = b[0]
+
+	// Version 00 has a fixed length, later versions may append fields.
+	if len(value) > 55 && (tp.Version == 0 || value[55] != '-') {
+		return tp, ErrBadTraceparent
+	}
+
+	if allZero(tp.TraceID[:]) || allZero(tp.ParentID[:]) {
+		return tp, ErrBadTraceparent
+	}
+
+	return tp, nil
+}
+
+// Parse the traceparent header of the request. Returns ErrNoTraceparent if
+// there isn't one, so that a request starting a new trace can be told from
+// one with a broken header.
+func (hp *HTTPParser) TraceParent() (TraceParent, error) {
+	value := hp.FindHeader(cTraceparent)
+	if value == nil {
+		return TraceParent{}, ErrNoTraceparent
+	}
+
+	return ParseTraceparent(value)
+}
+
+var (
+	cTraceparent = []byte("traceparent")
+	cTracestate  = []byte("tracestate")
+)
+
+// Maximum number of list members in a tracestate header.
+const maxTracestateMembers = 32
+
+// Call fn for each key=value list member of a tracestate header value, in
+// order. Empty members are skipped. If the value is malformed,
+// ErrBadTracestate is returned and fn may have been called for the members
+// before the bad one.
+func VisitTracestate(value []byte, fn func(key, value []byte)) error {
+	members := 0
+
+	for len(value) > 0 {
+		var member []byte
+
+		if c := bytes.IndexByte(value, ','); c != -1 {
+			member, value = value[:c], value[c+1:]
+		} else {
+			member, value = value, nil
+		}
+
+		member = bytes.Trim(member, " \t")
+		if len(member) == 0 {
+			continue
+		}
+
+		members++
+		if members > maxTracestateMembers {
+			return ErrBadTracestate
+		}
+
+		eq := bytes.IndexByte(member, '=')
+		if eq == -1 {
+			return ErrBadTracestate
+		}
+
+		key, val := member[:eq], member[eq+1:]
+		if !validTracestateKey(key) || !validTracestateValue(val) {
+			return ErrBadTracestate
+		}
+
+		fn(key, val)
+	}
+
+	return nil
+}
+
+// Visit the members of the request's tracestate headers.
+func (hp *HTTPParser) VisitTracestate(fn func(key, value []byte)) error {
+	for i := 0; i < hp.numHeaders; i++ {
+		h := &hp.Headers[i]
+		if !bytes.EqualFold(h.Name, cTracestate) {
+			continue
+		}
+
+		if err := VisitTracestate(h.Value, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isLowerAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isTracestateKeyChar(c byte) bool {
+	return isLowerAlpha(c) || isDigit(c) || c == '_' || c == '-' || c == '*' || c == '/'
+}
+
+func validTracestateKey(key []byte) bool {
+	if len(key) == 0 || len(key) > 256 {
+		return false
+	}
+
+	tenant, system := key, []byte(nil)
+	if at := bytes.IndexByte(key, '@'); at != -1 {
+		tenant, system = key[:at], key[at+1:]
+
+		if len(tenant) == 0 || len(tenant) > 241 || len(system) == 0 || len(system) > 14 {
+			return false
+		}
+
+		if !isLowerAlpha(system[0]) {
+			return false
+		}
+
+		for _, c := range system[1:] {
+			if !isTracestateKeyChar(c) {
+				return false
+			}
+		}
+
+		if !isLowerAlpha(tenant[0]) && !isDigit(tenant[0]) {
+			return false
+		}
+	} else if !isLowerAlpha(tenant[0]) {
+		return false
+	}
+
+	for _, c := range tenant[1:] {
+		if !isTracestateKeyChar(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validTracestateValue(val []byte) bool {
+	if len(val) == 0 || len(val) > 256 || val[len(val)-1] == ' ' {
+		return false
+	}
+
+	for _, c := range val {
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func fromLowerHex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	}
+
+	return 0, false
+}
+
+// Decode lower case hex from src into dst, which must be half its length.
+func decodeLowerHex(dst, src []byte) bool {
+	for i := range dst {
+		hi, ok1 := fromLowerHex(src[i*2])
+		lo, ok2 := fromLowerHex(src[i*2+1])
+		if !ok1 || !ok2 {
+			return false
+		}
+
+		dst[i] = hi<<4 | lo
+	}
+
+	return true
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
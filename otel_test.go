@@ -0,0 +1,116 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type attrs map[string]string
+
+func (a attrs) AppendAttribute(key string, value []byte) {
+	a[key] = string(value)
+}
+
+func TestAppendSpanAttributes(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET /search?q=1 HTTP/1.1\r\nHost: [::1]:8080\r\nUser-Agent: curl/8.0\r\n\r\n"))
+	require.NoError(t, err)
+
+	a := attrs{}
+	hp.AppendSpanAttributes(a)
+
+	assert.Equal(t, attrs{
+		AttrHTTPRequestMethod:      "GET",
+		AttrURLPath:                "/search",
+		AttrURLQuery:               "q=1",
+		AttrServerAddress:          "::1",
+		AttrServerPort:             "8080",
+		AttrUserAgentOriginal:      "curl/8.0",
+		AttrNetworkProtocolName:    "http",
+		AttrNetworkProtocolVersion: "1.1",
+	}, a)
+}
+
+func TestAppendSpanAttributesOtherMethod(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("PURGE / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.NoError(t, err)
+
+	a := attrs{}
+	hp.AppendSpanAttributes(a)
+
+	assert.Equal(t, "_OTHER", a[AttrHTTPRequestMethod])
+	assert.Equal(t, "PURGE", a[AttrHTTPRequestMethodOriginal])
+	assert.Equal(t, "example.com", a[AttrServerAddress])
+	_, ok := a[AttrServerPort]
+	assert.False(t, ok)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tp, err := ParseTraceparent([]byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	require.NoError(t, err)
+
+	assert.Equal(t, byte(0), tp.Version)
+	assert.Equal(t, byte(0x4b), tp.TraceID[0])
+	assert.Equal(t, byte(0xb7), tp.ParentID[7])
+	assert.True(t, tp.Sampled())
+
+	bad := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+
+	for _, s := range bad {
+		_, err := ParseTraceparent([]byte(s))
+		assert.Equal(t, ErrBadTraceparent, err, s)
+	}
+
+	_, err = ParseTraceparent([]byte("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"))
+	assert.NoError(t, err)
+}
+
+func TestHTTPParserTraceParent(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	_, err = hp.TraceParent()
+	assert.Equal(t, ErrNoTraceparent, err)
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.1\r\ntraceparent: bogus\r\n\r\n"))
+	require.NoError(t, err)
+
+	_, err = hp.TraceParent()
+	assert.Equal(t, ErrBadTraceparent, err)
+}
+
+func TestVisitTracestate(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\ntracestate: congo=t61rcWkgMzE, rojo@vendor=00f067aa0ba902b7\r\ntracestate: ,foo=bar\r\n\r\n"))
+	require.NoError(t, err)
+
+	var seen []string
+	err = hp.VisitTracestate(func(key, value []byte) {
+		seen = append(seen, string(key)+"="+string(value))
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"congo=t61rcWkgMzE", "rojo@vendor=00f067aa0ba902b7", "foo=bar"}, seen)
+
+	err = VisitTracestate([]byte("Upper=1"), func(key, value []byte) {})
+	assert.Equal(t, ErrBadTracestate, err)
+
+	err = VisitTracestate([]byte("novalue"), func(key, value []byte) {})
+	assert.Equal(t, ErrBadTracestate, err)
+}
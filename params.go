@@ -0,0 +1,87 @@
+package wildcat
+
+import "bytes"
+
+// Split a header value of the form `value; name=value; name="quoted"`, as
+// used by Content-Type, Content-Disposition, Accept items and extension
+// offers, returning the leading value trimmed of whitespace. fn, if not
+// nil, is called for each parameter in order; quoted values are unquoted,
+// and the value is nil for a parameter with no '='. Stops early if fn
+// returns false. Values only need copying when they hold escapes, so
+// nothing is allocated in the usual case.
+func ParseParameterizedValue(b []byte, fn func(name, value []byte) bool) []byte {
+	semi := bytes.IndexByte(b, ';')
+	if semi == -1 {
+		return bytes.TrimSpace(b)
+	}
+
+	value := bytes.TrimSpace(b[:semi])
+
+	for params := b[semi:]; len(params) > 0; {
+		params = bytes.TrimLeft(params[1:], " \t")
+
+		end := 0
+		for end < len(params) && params[end] != '=' && params[end] != ';' {
+			end++
+		}
+
+		name := bytes.TrimSpace(params[:end])
+		params = params[end:]
+
+		var pv []byte
+
+		if len(params) > 0 && params[0] == '=' {
+			pv, params = unquotePrefix(bytes.TrimLeft(params[1:], " \t"), ';')
+
+			// Skip anything between a closing quote and the next ';'.
+			if next := bytes.IndexByte(params, ';'); next != -1 {
+				params = params[next:]
+			} else {
+				params = nil
+			}
+		}
+
+		if len(name) == 0 {
+			continue
+		}
+
+		if fn != nil && !fn(name, pv) {
+			break
+		}
+	}
+
+	return value
+}
+
+// Return the value of the parameter called name (compared without regard
+// to case) in a value ParseParameterizedValue accepts, or nil.
+func FindParameter(b, name []byte) []byte {
+	var found []byte
+
+	ParseParameterizedValue(b, func(n, v []byte) bool {
+		if equalFoldASCII(n, name) {
+			found = v
+			return false
+		}
+
+		return true
+	})
+
+	return found
+}
+
+// Return the media type of the Content-Type header, without parameters.
+func (hp *HTTPParser) MediaType() []byte {
+	ct := hp.ContentType()
+	if ct == nil {
+		return nil
+	}
+
+	return ParseParameterizedValue(ct, nil)
+}
+
+// Return the value of a Content-Type parameter, such as charset or
+// boundary.
+func (hp *HTTPParser) ContentTypeParameter(name []byte) []byte {
+	return FindParameter(hp.ContentType(), name)
+}
@@ -0,0 +1,63 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseParameterizedValue(t *testing.T) {
+	var names, values []string
+
+	value := ParseParameterizedValue([]byte(`form-data ; name="a \"b\"; c" ;filename=x.txt; flag;;`), func(name, value []byte) bool {
+		names = append(names, string(name))
+		if value == nil {
+			values = append(values, "<nil>")
+		} else {
+			values = append(values, string(value))
+		}
+		return true
+	})
+
+	assert.Equal(t, "form-data", string(value))
+	assert.Equal(t, []string{"name", "filename", "flag"}, names)
+	assert.Equal(t, []string{`a "b"; c`, "x.txt", "<nil>"}, values)
+
+	assert.Equal(t, "text/plain", string(ParseParameterizedValue([]byte(" text/plain "), nil)))
+
+	calls := 0
+	ParseParameterizedValue([]byte("a; b=1; c=2"), func(name, value []byte) bool {
+		calls++
+		return false
+	})
+	assert.Equal(t, 1, calls)
+}
+
+func TestParseParameterizedValueNoAlloc(t *testing.T) {
+	b := []byte(`text/html; charset="utf-8"; level=1`)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		FindParameter(b, []byte("level"))
+	})
+
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, "utf-8", string(FindParameter(b, []byte("CHARSET"))))
+	assert.Nil(t, FindParameter(b, []byte("boundary")))
+}
+
+func TestContentTypeParameter(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("POST / HTTP/1.1\r\nContent-Type: multipart/form-data; boundary=\"xyz\"\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "multipart/form-data", string(hp.MediaType()))
+	assert.Equal(t, "xyz", string(hp.ContentTypeParameter([]byte("boundary"))))
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Nil(t, hp.MediaType())
+	assert.Nil(t, hp.ContentTypeParameter([]byte("charset")))
+}
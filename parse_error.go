@@ -0,0 +1,68 @@
+package wildcat
+
+import (
+	"encoding/hex"
+	"log/slog"
+)
+
+// The most input bytes included in a ParseError.
+const ParseErrorDumpSize = 256
+
+// ParseError describes a request that Parse rejected as malformed.
+type ParseError struct {
+	Err error
+
+	// Offset into the input of the byte that was rejected.
+	Offset int
+
+	// Up to ParseErrorDumpSize bytes of the input around Offset, starting at
+	// InputOffset. This aliases the input and is only valid during the hook.
+	Input       []byte
+	InputOffset int
+}
+
+// Return a hex/ASCII dump of Input, as formatted by encoding/hex.Dump.
+func (pe *ParseError) Dump() string {
+	return hex.Dump(pe.Input)
+}
+
+// ParseErrorHook is called when Parse fails with an error other than
+// ErrMissingData.
+type ParseErrorHook func(pe *ParseError)
+
+// Set a hook to call whenever Parse rejects malformed input, so that
+// operators can see what was sent. Pass nil to remove it.
+func (hp *HTTPParser) SetParseErrorHook(fn ParseErrorHook) {
+	hp.parseErrorHook = fn
+}
+
+func (hp *HTTPParser) reportParseError(input []byte, err error) {
+	start := hp.errOffset - ParseErrorDumpSize/2
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + ParseErrorDumpSize
+	if end > len(input) {
+		end = len(input)
+	}
+
+	hp.parseErrorHook(&ParseError{
+		Err:         err,
+		Offset:      hp.errOffset,
+		Input:       input[start:end],
+		InputOffset: start,
+	})
+}
+
+// Return a ParseErrorHook that logs malformed requests to l at warning level.
+func SlogParseErrorHook(l *slog.Logger) ParseErrorHook {
+	return func(pe *ParseError) {
+		l.Warn("malformed http request",
+			slog.String("error", pe.Err.Error()),
+			slog.Int("offset", pe.Offset),
+			slog.Int("dump_offset", pe.InputOffset),
+			slog.String("dump", pe.Dump()),
+		)
+	}
+}
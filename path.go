@@ -0,0 +1,125 @@
+package wildcat
+
+import (
+	"bytes"
+	"path"
+
+	"github.com/vektra/errors"
+)
+
+var ErrBadPath = errors.New("malformed request path")
+
+// CleanPath normalizes a request path for mapping onto a filesystem or
+// routing table: the query is removed, percent escapes are decoded, and the
+// result is cleaned like path.Clean so it is rooted at "/" and contains no
+// "." or ".." elements. Paths with invalid escapes or NUL bytes are rejected.
+func CleanPath(p []byte) ([]byte, error) {
+	if q := bytes.IndexByte(p, '?'); q != -1 {
+		p = p[:q]
+	}
+
+	buf := make([]byte, 0, len(p)+1)
+	buf = append(buf, '/')
+
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+
+		if c == '%' {
+			if i+2 >= len(p) {
+				return nil, ErrBadPath
+			}
+
+			hi, ok1 := fromHex(p[i+1])
+			lo, ok2 := fromHex(p[i+2])
+			if !ok1 || !ok2 {
+				return nil, ErrBadPath
+			}
+
+			c = hi<<4 | lo
+			i += 2
+		}
+
+		if c == 0 {
+			return nil, ErrBadPath
+		}
+
+		buf = append(buf, c)
+	}
+
+	clean := path.Clean(string(buf))
+
+	// Keep a trailing slash, it matters for directory handling.
+	if len(buf) > 1 && buf[len(buf)-1] == '/' && clean != "/" {
+		clean += "/"
+	}
+
+	return []byte(clean), nil
+}
+
+func fromHex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+var cSlash = []byte("/")
+
+// Replace prefix at the start of the request path with replacement, so a
+// gateway can map "/api/v1/users" onto "/users" before proxying. prefix
+// only matches whole segments: "/api/v1" matches "/api/v1", "/api/v1/x" and
+// "/api/v1?q" but not "/api/v1x". Bytes are compared as sent, escapes and
+// all. A slash where replacement and the rest of the path meet isn't
+// doubled, and an empty result becomes "/".
+//
+// When the result is no longer than the original the path is rewritten in
+// place, overwriting the end of the old prefix in the parser's input;
+// otherwise a new buffer is allocated. Returns false, leaving Path alone,
+// if prefix doesn't match.
+func (hp *HTTPParser) ReplacePathPrefix(prefix, replacement []byte) bool {
+	p := hp.Path
+
+	if len(p) == 0 || p[0] != '/' || !bytes.HasPrefix(p, prefix) {
+		return false
+	}
+
+	at := len(prefix)
+
+	if at < len(p) && p[at] != '/' && p[at] != '?' && (at == 0 || prefix[at-1] != '/') {
+		return false
+	}
+
+	rest := p[at:]
+
+	if n := len(replacement); n > 0 && replacement[n-1] == '/' && len(rest) > 0 && rest[0] == '/' {
+		rest = rest[1:]
+		at++
+	}
+
+	if len(replacement) == 0 && (len(rest) == 0 || rest[0] != '/') {
+		replacement = cSlash
+	}
+
+	if start := at - len(replacement); start >= 0 {
+		copy(p[start:], replacement)
+		hp.Path = p[start:]
+		return true
+	}
+
+	buf := make([]byte, 0, len(replacement)+len(rest))
+	buf = append(buf, replacement...)
+	hp.Path = append(buf, rest...)
+	return true
+}
+
+// Remove prefix from the start of the request path, as ReplacePathPrefix
+// does with a replacement of "/".
+func (hp *HTTPParser) StripPathPrefix(prefix []byte) bool {
+	return hp.ReplacePathPrefix(prefix, cSlash)
+}
@@ -0,0 +1,71 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplacePathPrefix(t *testing.T) {
+	cases := []struct {
+		path, prefix, replacement, want string
+		ok                              bool
+	}{
+		{"/api/v1/users", "/api/v1", "/", "/users", true},
+		{"/api/v1", "/api/v1", "/", "/", true},
+		{"/api/v1?q=1", "/api/v1", "/", "/?q=1", true},
+		{"/api/v1/users", "/api/v1/", "", "/users", true},
+		{"/api/v1/users", "/api/v1", "", "/users", true},
+		{"/api/v1/users", "/api/v1", "/v2", "/v2/users", true},
+		{"/v1/users", "/v1", "/internal/api", "/internal/api/users", true},
+		{"/api/v1x", "/api/v1", "/", "/api/v1x", false},
+		{"/other", "/api", "/", "/other", false},
+		{"http://example.com/api", "/api", "/", "http://example.com/api", false},
+	}
+
+	for _, c := range cases {
+		hp := NewHTTPParser()
+
+		_, err := hp.Parse([]byte("GET " + c.path + " HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+		require.NoError(t, err)
+
+		assert.Equal(t, c.ok, hp.ReplacePathPrefix([]byte(c.prefix), []byte(c.replacement)), c.path)
+		assert.Equal(t, c.want, string(hp.Path), c.path)
+	}
+}
+
+func TestStripPathPrefixInPlace(t *testing.T) {
+	req := []byte("GET /api/v1/users?id=2 HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	prefix := []byte("/api/v1")
+
+	hp := NewHTTPParser()
+
+	allocs := testing.AllocsPerRun(10, func() {
+		input := append(req[:0:0], req...)
+
+		if _, err := hp.Parse(input); err != nil {
+			t.Fatal(err)
+		}
+
+		hp.StripPathPrefix(prefix)
+	})
+
+	// Just the copy of the input.
+	assert.Equal(t, 1.0, allocs)
+
+	assert.Equal(t, "/users?id=2", string(hp.Path))
+	assert.Equal(t, "id=2", string(hp.Query()))
+}
+
+func TestStripPathPrefixProxied(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET /api/v1/users HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.NoError(t, err)
+
+	require.True(t, hp.StripPathPrefix([]byte("/api/v1")))
+
+	rp := &ReverseProxy{OmitForwarded: true}
+	assert.Equal(t, "GET /users HTTP/1.1\r\nHost: example.com\r\n\r\n", string(rp.appendHeader(nil, hp, nil)))
+}
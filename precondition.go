@@ -0,0 +1,156 @@
+package wildcat
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// Validators describes the current state of the selected representation
+// for precondition evaluation. Either field may be empty if the resource
+// doesn't have that validator.
+type Validators struct {
+	ETag         []byte
+	LastModified time.Time
+}
+
+// EvaluatePreconditions applies the conditional request headers of hp to a
+// representation with validators v, in the order and with the precedence
+// given by RFC 9110 section 13.2.2, and returns the status the response
+// should have:
+//
+//   - StatusPreconditionFailed if If-Match or If-Unmodified-Since fail, or
+//     If-None-Match fails for a method other than GET or HEAD
+//   - StatusNotModified if If-None-Match or If-Modified-Since fail for GET or HEAD
+//   - StatusPartialContent if the request is a GET with a Range header that
+//     should be honored (If-Range, if present, passed)
+//   - StatusOK otherwise, including when a Range is to be ignored
+//
+// The engine does not parse the Range itself; a caller that gets
+// StatusPartialContent may still fall back to 200 if the range is invalid.
+func EvaluatePreconditions(hp *HTTPParser, v Validators) int {
+	get := hp.Get()
+	getOrHead := get || bytes.Equal(hp.Method, cHead)
+
+	if im := hp.findHeaderClass(hIfMatch); im != nil {
+		if !etagListMatch(im, v.ETag, true) {
+			return StatusPreconditionFailed
+		}
+	} else if ius := hp.findHeaderClass(hIfUnmodifiedSince); ius != nil && !v.LastModified.IsZero() {
+		if t, err := http.ParseTime(string(ius)); err == nil && truncSecond(v.LastModified).After(t) {
+			return StatusPreconditionFailed
+		}
+	}
+
+	if inm := hp.findHeaderClass(hIfNoneMatch); inm != nil {
+		if etagListMatch(inm, v.ETag, false) {
+			if getOrHead {
+				return StatusNotModified
+			}
+
+			return StatusPreconditionFailed
+		}
+	} else if ims := hp.findHeaderClass(hIfModifiedSince); ims != nil && getOrHead && !v.LastModified.IsZero() {
+		if t, err := http.ParseTime(string(ims)); err == nil && !truncSecond(v.LastModified).After(t) {
+			return StatusNotModified
+		}
+	}
+
+	if get && hp.findHeaderClass(hRange) != nil {
+		if ir := hp.findHeaderClass(hIfRange); ir != nil && !ifRangeMatch(ir, v) {
+			return StatusOK
+		}
+
+		return StatusPartialContent
+	}
+
+	return StatusOK
+}
+
+func truncSecond(t time.Time) time.Time {
+	return t.Truncate(time.Second)
+}
+
+// Report whether If-Range value ir, an entity tag or a date, matches v.
+func ifRangeMatch(ir []byte, v Validators) bool {
+	ir = bytes.TrimSpace(ir)
+
+	if len(ir) > 0 && (ir[0] == '"' || bytes.HasPrefix(ir, cWeakPrefix)) {
+		tag, _, ok := scanETag(ir)
+		return ok && etagMatch(tag, v.ETag, true)
+	}
+
+	if v.LastModified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(string(ir))
+	return err == nil && truncSecond(v.LastModified).Equal(t)
+}
+
+var cWeakPrefix = []byte("W/")
+
+// Scan one entity-tag from the start of s (after optional whitespace),
+// returning it and the rest of s.
+func scanETag(s []byte) (tag, rest []byte, ok bool) {
+	s = bytes.TrimLeft(s, " \t")
+
+	start := 0
+	if bytes.HasPrefix(s, cWeakPrefix) {
+		start = 2
+	}
+
+	if len(s) <= start || s[start] != '"' {
+		return nil, s, false
+	}
+
+	for i := start + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return s[:i+1], s[i+1:], true
+		case c == 0x21 || c >= 0x23 && c != 0x7f:
+		default:
+			return nil, s, false
+		}
+	}
+
+	return nil, s, false
+}
+
+// Compare two entity tags. With strong set both must be strong validators.
+func etagMatch(a, b []byte, strong bool) bool {
+	aw := bytes.HasPrefix(a, cWeakPrefix)
+	bw := bytes.HasPrefix(b, cWeakPrefix)
+
+	if strong && (aw || bw) {
+		return false
+	}
+
+	return len(b) > 0 && bytes.Equal(bytes.TrimPrefix(a, cWeakPrefix), bytes.TrimPrefix(b, cWeakPrefix))
+}
+
+// Report whether the If-Match/If-None-Match list matches etag. "*" matches
+// any current representation.
+func etagListMatch(list, etag []byte, strong bool) bool {
+	if t := bytes.TrimSpace(list); len(t) == 1 && t[0] == '*' {
+		return true
+	}
+
+	for {
+		list = bytes.TrimLeft(list, " \t,")
+		if len(list) == 0 {
+			return false
+		}
+
+		tag, rest, ok := scanETag(list)
+		if !ok {
+			return false
+		}
+
+		if etagMatch(tag, etag, strong) {
+			return true
+		}
+
+		list = rest
+	}
+}
@@ -0,0 +1,64 @@
+package wildcat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluatePreconditions(t *testing.T) {
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := Validators{ETag: []byte(`"abc"`), LastModified: mod}
+
+	before := "Mon, 01 Jan 2024 00:00:00 GMT"
+	at := "Tue, 02 Jan 2024 03:04:05 GMT"
+	after := "Wed, 03 Jan 2024 00:00:00 GMT"
+
+	cases := []struct {
+		req    string
+		status int
+	}{
+		{"GET / HTTP/1.1\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nIf-Match: \"abc\"\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nIf-Match: \"x\", \"abc\"\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nIf-Match: *\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nIf-Match: W/\"abc\"\r\n\r\n", StatusPreconditionFailed},
+		{"PUT / HTTP/1.1\r\nIf-Match: \"x\"\r\n\r\n", StatusPreconditionFailed},
+		{"PUT / HTTP/1.1\r\nIf-Unmodified-Since: " + before + "\r\n\r\n", StatusPreconditionFailed},
+		{"PUT / HTTP/1.1\r\nIf-Unmodified-Since: " + at + "\r\n\r\n", StatusOK},
+		// If-Match takes precedence over If-Unmodified-Since.
+		{"PUT / HTTP/1.1\r\nIf-Match: \"abc\"\r\nIf-Unmodified-Since: " + before + "\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nIf-None-Match: W/\"abc\"\r\n\r\n", StatusNotModified},
+		{"HEAD / HTTP/1.1\r\nIf-None-Match: \"x\", \"abc\"\r\n\r\n", StatusNotModified},
+		{"GET / HTTP/1.1\r\nIf-None-Match: \"x\"\r\n\r\n", StatusOK},
+		{"DELETE / HTTP/1.1\r\nIf-None-Match: *\r\n\r\n", StatusPreconditionFailed},
+		{"GET / HTTP/1.1\r\nIf-Modified-Since: " + at + "\r\n\r\n", StatusNotModified},
+		{"GET / HTTP/1.1\r\nIf-Modified-Since: " + before + "\r\n\r\n", StatusOK},
+		// If-None-Match takes precedence over If-Modified-Since.
+		{"GET / HTTP/1.1\r\nIf-None-Match: \"x\"\r\nIf-Modified-Since: " + after + "\r\n\r\n", StatusOK},
+		{"POST / HTTP/1.1\r\nIf-Modified-Since: " + after + "\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nRange: bytes=0-1\r\n\r\n", StatusPartialContent},
+		{"GET / HTTP/1.1\r\nRange: bytes=0-1\r\nIf-Range: \"abc\"\r\n\r\n", StatusPartialContent},
+		{"GET / HTTP/1.1\r\nRange: bytes=0-1\r\nIf-Range: \"old\"\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nRange: bytes=0-1\r\nIf-Range: " + at + "\r\n\r\n", StatusPartialContent},
+		{"GET / HTTP/1.1\r\nRange: bytes=0-1\r\nIf-Range: " + after + "\r\n\r\n", StatusOK},
+		{"HEAD / HTTP/1.1\r\nRange: bytes=0-1\r\n\r\n", StatusOK},
+	}
+
+	for _, c := range cases {
+		hp := NewHTTPParser()
+		_, err := hp.Parse([]byte(c.req))
+		require.NoError(t, err)
+
+		assert.Equal(t, c.status, EvaluatePreconditions(hp, v), c.req)
+	}
+}
+
+func TestETagListWithComma(t *testing.T) {
+	assert.True(t, etagListMatch([]byte(`"a,b", "c"`), []byte(`"a,b"`), true))
+	assert.True(t, etagListMatch([]byte(`"a,b", "c"`), []byte(`"c"`), true))
+	assert.False(t, etagListMatch([]byte(`"a,b", "c"`), []byte(`"a"`), true))
+	assert.False(t, etagListMatch([]byte(`abc`), []byte(`"abc"`), false))
+}
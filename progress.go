@@ -0,0 +1,69 @@
+package wildcat
+
+// What Parse was waiting for when it returned ErrMissingData.
+type ParseStage uint8
+
+const (
+	// The request line isn't complete.
+	WaitingRequestLine ParseStage = iota
+
+	// A header line isn't complete.
+	WaitingHeaders
+
+	// Every line so far is complete; next is another header or the blank
+	// line ending the headers.
+	WaitingHeaderEnd
+)
+
+func (s ParseStage) String() string {
+	switch s {
+	case WaitingRequestLine:
+		return "request line"
+	case WaitingHeaders:
+		return "headers"
+	case WaitingHeaderEnd:
+		return "end of headers"
+	default:
+		return "unknown"
+	}
+}
+
+// How far Parse or Resume got before returning ErrMissingData.
+type ParseProgress struct {
+	// Bytes at the start of the input that have been fully dealt with:
+	// complete lines, plus a header line that only lacks its LF. Once
+	// headers are being parsed, Resume continues from here.
+	Consumed int
+
+	// Bytes looked at, which is all of the input.
+	Scanned int
+
+	Waiting ParseStage
+}
+
+// Return how far the last Parse or Resume that returned ErrMissingData
+// got, so that a read loop can tell how much of the buffer holds the
+// request so far and what's still to come.
+func (hp *HTTPParser) Progress() ParseProgress {
+	return hp.progress
+}
+
+func (hp *HTTPParser) missingRequestLine(total int) error {
+	hp.progress = ParseProgress{Scanned: total, Waiting: WaitingRequestLine}
+	return ErrMissingData
+}
+
+// Record the start of the incomplete line, so Resume can pick up there.
+// atEnd is set when every line so far is complete.
+func (hp *HTTPParser) missingHeaders(line parseResume, atEnd bool, total int) error {
+	hp.resume = line
+	hp.resuming = true
+
+	waiting := WaitingHeaders
+	if atEnd {
+		waiting = WaitingHeaderEnd
+	}
+
+	hp.progress = ParseProgress{Consumed: line.at, Scanned: total, Waiting: waiting}
+	return ErrMissingData
+}
@@ -0,0 +1,68 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserProgress(t *testing.T) {
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\n\r\n")
+
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse(req[:10])
+	require.Equal(t, ErrMissingData, err)
+	assert.Equal(t, ParseProgress{Consumed: 0, Scanned: 10, Waiting: WaitingRequestLine}, hp.Progress())
+
+	_, err = hp.Parse(req[:20])
+	require.Equal(t, ErrMissingData, err)
+	assert.Equal(t, ParseProgress{Consumed: 16, Scanned: 20, Waiting: WaitingHeaders}, hp.Progress())
+
+	_, err = hp.Parse(req[:34])
+	require.Equal(t, ErrMissingData, err)
+	assert.Equal(t, ParseProgress{Consumed: 34, Scanned: 34, Waiting: WaitingHeaders}, hp.Progress())
+
+	_, err = hp.Parse(req[:35])
+	require.Equal(t, ErrMissingData, err)
+	assert.Equal(t, ParseProgress{Consumed: 35, Scanned: 35, Waiting: WaitingHeaderEnd}, hp.Progress())
+
+	_, err = hp.Parse(req[:len(req)-1])
+	require.Equal(t, ErrMissingData, err)
+	assert.Equal(t, ParseProgress{Consumed: len(req) - 2, Scanned: len(req) - 1, Waiting: WaitingHeaderEnd}, hp.Progress())
+	assert.Equal(t, "end of headers", hp.Progress().Waiting.String())
+}
+
+func TestParserResumeByteByByte(t *testing.T) {
+	req := []byte("POST /x HTTP/1.1\r\nHost: example.com\r\nX-Long: a\r\n  b\r\n" +
+		"Content-Length: 4\r\nAccept: */*\r\n\r\nbody")
+
+	want := NewHTTPParser()
+	wantN, err := want.Parse(req)
+	require.NoError(t, err)
+
+	hp := NewHTTPParser()
+
+	var n int
+
+	err = ErrMissingData
+	for i := 1; err == ErrMissingData; i++ {
+		require.True(t, i <= len(req))
+		n, err = hp.Resume(req[:i])
+	}
+
+	require.NoError(t, err)
+	assert.Equal(t, wantN, n)
+	assert.Equal(t, want.HeaderCount(), hp.HeaderCount())
+	assert.Equal(t, "a b", string(hp.FindHeader([]byte("X-Long"))))
+	assert.Equal(t, "example.com", string(hp.Host()))
+	assert.Equal(t, int64(4), hp.ContentLength())
+
+	for i := 0; i < want.HeaderCount(); i++ {
+		wn, wv := want.HeaderAt(i)
+		gn, gv := hp.HeaderAt(i)
+		assert.Equal(t, string(wn), string(gn))
+		assert.Equal(t, string(wv), string(gv))
+	}
+}
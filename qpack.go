@@ -0,0 +1,451 @@
+package wildcat
+
+import (
+	"bytes"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrBadFieldSection = errors.New("malformed QPACK field section")
+	ErrDynamicTable    = errors.New("QPACK dynamic table not supported")
+	ErrBadHuffman      = errors.New("invalid Huffman encoded string")
+)
+
+// The QPACK static table (RFC 9204, Appendix A).
+var qpackStaticTable = [...][2]string{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
+
+// The static table as header values, so decoded fields can share them.
+var qpackStatic [len(qpackStaticTable)]header
+
+// Huffman decoding tree; each node has children for a 0 and a 1 bit.
+// Leaves are stored as -(sym+1).
+var qpackHuffmanTree [][2]int16
+
+func init() {
+	for i, e := range qpackStaticTable {
+		name := []byte(e[0])
+		qpackStatic[i] = header{name, []byte(e[1]), lookupHeaderClass(name)}
+	}
+
+	qpackHuffmanTree = make([][2]int16, 1, 256)
+
+	for sym, code := range qpackHuffmanCodes {
+		node := 0
+
+		for bit := int(qpackHuffmanCodeLen[sym]) - 1; bit >= 0; bit-- {
+			b := (code >> uint(bit)) & 1
+
+			if bit == 0 {
+				qpackHuffmanTree[node][b] = int16(-(sym + 1))
+				break
+			}
+
+			next := qpackHuffmanTree[node][b]
+			if next == 0 {
+				qpackHuffmanTree = append(qpackHuffmanTree, [2]int16{})
+				next = int16(len(qpackHuffmanTree) - 1)
+				qpackHuffmanTree[node][b] = next
+			}
+
+			node = int(next)
+		}
+	}
+}
+
+// Append the Huffman decoding of src to dst.
+func appendHuffmanDecode(dst, src []byte) ([]byte, error) {
+	node := 0
+
+	// Bits consumed since the last symbol, and whether they were all ones,
+	// to check the padding at the end.
+	depth := 0
+	ones := true
+
+	for _, c := range src {
+		for bit := 7; bit >= 0; bit-- {
+			b := (c >> uint(bit)) & 1
+
+			next := qpackHuffmanTree[node][b]
+			switch {
+			case next < 0:
+				dst = append(dst, byte(-next-1))
+				node, depth, ones = 0, 0, true
+			case next == 0:
+				// Only EOS is missing from the tree.
+				return dst, ErrBadHuffman
+			default:
+				node = int(next)
+				depth++
+				ones = ones && b == 1
+			}
+		}
+	}
+
+	if depth > 7 || !ones {
+		return dst, ErrBadHuffman
+	}
+
+	return dst, nil
+}
+
+// Decode an integer with an n bit prefix (RFC 7541, section 5.1) from the
+// start of b, returning the value and the remaining bytes.
+func qpackInt(b []byte, n uint) (uint64, []byte, error) {
+	if len(b) == 0 {
+		return 0, nil, ErrBadFieldSection
+	}
+
+	max := uint64(1)<<n - 1
+
+	v := uint64(b[0]) & max
+	b = b[1:]
+
+	if v < max {
+		return v, b, nil
+	}
+
+	var shift uint
+
+	for i, c := range b {
+		if shift > 56 {
+			return 0, nil, ErrBadFieldSection
+		}
+
+		v += uint64(c&0x7f) << shift
+		shift += 7
+
+		if c&0x80 == 0 {
+			return v, b[i+1:], nil
+		}
+	}
+
+	return 0, nil, ErrBadFieldSection
+}
+
+// A QPACKDecoder decodes HTTP/3 field sections into the same headers the
+// HTTP/1 parser produces. It only uses the static table, which is what a
+// peer sends when the decoder advertises a dynamic table capacity of 0;
+// references to the dynamic table fail with ErrDynamicTable.
+type QPACKDecoder struct {
+	// The maximum size of a decoded field section, counted as in RFC 9114
+	// (name + value + 32 per field). 0 means no limit.
+	MaxFieldSectionSize int
+
+	Headers []header
+
+	buf []byte
+}
+
+func NewQPACKDecoder() *QPACKDecoder {
+	return &QPACKDecoder{}
+}
+
+// Decode a string literal whose first byte has an n bit length prefix,
+// with the Huffman flag in the bit above it.
+func (d *QPACKDecoder) readString(b []byte, n uint) ([]byte, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, ErrBadFieldSection
+	}
+
+	huffman := b[0]&(1<<n) != 0
+
+	l, b, err := qpackInt(b, n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if uint64(len(b)) < l {
+		return nil, nil, ErrBadFieldSection
+	}
+
+	s, b := b[:l], b[l:]
+
+	if !huffman {
+		return s, b, nil
+	}
+
+	start := len(d.buf)
+
+	d.buf, err = appendHuffmanDecode(d.buf, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.buf[start:len(d.buf):len(d.buf)], b, nil
+}
+
+func staticEntry(idx uint64) (header, error) {
+	if idx >= uint64(len(qpackStatic)) {
+		return header{}, ErrBadFieldSection
+	}
+
+	return qpackStatic[idx], nil
+}
+
+// Decode a complete field section. The returned headers (and d.Headers)
+// are valid until the next call and may refer to block.
+func (d *QPACKDecoder) Decode(block []byte) ([]header, error) {
+	d.Headers = d.Headers[:0]
+	d.buf = d.buf[:0]
+
+	ric, b, err := qpackInt(block, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	if ric != 0 {
+		return nil, ErrDynamicTable
+	}
+
+	// Delta Base is meaningless without a dynamic table.
+	if _, b, err = qpackInt(b, 7); err != nil {
+		return nil, err
+	}
+
+	size := 0
+
+	for len(b) > 0 {
+		var h header
+
+		c := b[0]
+
+		switch {
+		case c&0x80 != 0:
+			// Indexed field line.
+			if c&0x40 == 0 {
+				return nil, ErrDynamicTable
+			}
+
+			var idx uint64
+			if idx, b, err = qpackInt(b, 6); err != nil {
+				return nil, err
+			}
+
+			if h, err = staticEntry(idx); err != nil {
+				return nil, err
+			}
+		case c&0x40 != 0:
+			// Literal field line with name reference.
+			if c&0x10 == 0 {
+				return nil, ErrDynamicTable
+			}
+
+			var idx uint64
+			if idx, b, err = qpackInt(b, 4); err != nil {
+				return nil, err
+			}
+
+			if h, err = staticEntry(idx); err != nil {
+				return nil, err
+			}
+
+			if h.Value, b, err = d.readString(b, 7); err != nil {
+				return nil, err
+			}
+		case c&0x20 != 0:
+			// Literal field line with literal name.
+			if h.Name, b, err = d.readString(b, 3); err != nil {
+				return nil, err
+			}
+
+			if h.Value, b, err = d.readString(b, 7); err != nil {
+				return nil, err
+			}
+
+			h.class = lookupHeaderClass(h.Name)
+		default:
+			// Post-base references only point into the dynamic table.
+			return nil, ErrDynamicTable
+		}
+
+		size += len(h.Name) + len(h.Value) + 32
+		if d.MaxFieldSectionSize > 0 && size > d.MaxFieldSectionSize {
+			return nil, ErrHeaderTooLarge
+		}
+
+		d.Headers = append(d.Headers, h)
+	}
+
+	return d.Headers, nil
+}
+
+var (
+	cPseudoMethod    = []byte(":method")
+	cPseudoPath      = []byte(":path")
+	cPseudoAuthority = []byte(":authority")
+	cHTTP3           = []byte("HTTP/3")
+)
+
+// Decode a request field section into hp, as if it had been parsed from
+// HTTP/1: :method and :path become Method and Path, :authority becomes the
+// Host header and Version is "HTTP/3". Other pseudo-headers are dropped.
+func (d *QPACKDecoder) DecodeRequest(hp *HTTPParser, block []byte) error {
+	headers, err := d.Decode(block)
+	if err != nil {
+		return err
+	}
+
+	hp.Reset()
+	hp.resetParse()
+	hp.Method, hp.Path, hp.Version = nil, nil, cHTTP3
+
+	h := 0
+
+	var authority []byte
+
+	for _, f := range headers {
+		// Field names must be lowercase (RFC 9114, section 4.2).
+		if hasUpperASCII(f.Name) {
+			return ErrBadFieldSection
+		}
+
+		if len(f.Name) > 0 && f.Name[0] == ':' {
+			switch string(f.Name) {
+			case string(cPseudoMethod):
+				hp.Method = f.Value
+			case string(cPseudoPath):
+				hp.Path = f.Value
+			case string(cPseudoAuthority):
+				if !hp.addHeader(h, hHost, headerClassNames[hHost], f.Value) {
+					return ErrTooManyHeaders
+				}
+				hp.captureHeader(hHost, f.Value)
+				hp.hostCount++
+				authority = f.Value
+				h++
+			}
+
+			continue
+		}
+
+		// A Host repeating :authority is allowed (RFC 9114, section
+		// 4.3.1), so it isn't counted again.
+		if f.class == hHost && (authority == nil || !bytes.Equal(f.Value, authority)) {
+			hp.hostCount++
+		}
+
+		if !hp.addHeader(h, f.class, f.Name, f.Value) {
+			return ErrTooManyHeaders
+		}
+		hp.captureHeader(f.class, f.Value)
+		h++
+	}
+
+	if hp.Method == nil || hp.Path == nil {
+		return ErrBadFieldSection
+	}
+
+	_, err = hp.endHeaders(h, 0)
+	return err
+}
+
+func hasUpperASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			return true
+		}
+	}
+
+	return false
+}
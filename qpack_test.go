@@ -0,0 +1,124 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+func qpackBlock(parts ...interface{}) []byte {
+	var b []byte
+
+	for _, p := range parts {
+		switch v := p.(type) {
+		case int:
+			b = append(b, byte(v))
+		case string:
+			b = append(b, v...)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+
+	return b
+}
+
+// "www.example.com", Huffman encoded (RFC 7541, C.4.1).
+var huffmanExample = []byte{0xf1, 0xe3, 0xc2, 0xe5, 0xf2, 0x3a, 0x6b, 0xa0, 0xab, 0x90, 0xf4, 0xff}
+
+func TestQPACKDecode(t *testing.T) {
+	d := NewQPACKDecoder()
+
+	block := qpackBlock(
+		0x00, 0x00,
+		0xd1,                      // :method GET
+		0x51, 0x0b, "/index.html", // :path, literal value
+		0x50, 0x8c, huffmanExample, // :authority, Huffman value
+		0x23, "x-a", 0x01, "b", // literal name
+		0xc4, // content-length: 0
+	)
+
+	headers, err := d.Decode(block)
+	require.NoError(t, err)
+	require.Len(t, headers, 5)
+
+	assert.Equal(t, ":method", string(headers[0].Name))
+	assert.Equal(t, "GET", string(headers[0].Value))
+	assert.Equal(t, "/index.html", string(headers[1].Value))
+	assert.Equal(t, "www.example.com", string(headers[2].Value))
+	assert.Equal(t, "x-a", string(headers[3].Name))
+	assert.Equal(t, "b", string(headers[3].Value))
+	assert.Equal(t, hContentLength, headers[4].class)
+}
+
+func TestQPACKDecodeRequest(t *testing.T) {
+	d := NewQPACKDecoder()
+	hp := NewHTTPParser()
+
+	block := qpackBlock(
+		0x00, 0x00,
+		0xd4, // :method POST
+		0xd7, // :scheme https
+		0x51, 0x02, "/u",
+		0x50, 0x8c, huffmanExample,
+		0x54, 0x01, "5", // content-length: 5
+	)
+
+	require.NoError(t, d.DecodeRequest(hp, block))
+
+	assert.Equal(t, "POST", string(hp.Method))
+	assert.Equal(t, "/u", string(hp.Path))
+	assert.Equal(t, "HTTP/3", string(hp.Version))
+	assert.Equal(t, "www.example.com", string(hp.Host()))
+	assert.Equal(t, int64(5), hp.ContentLength())
+	assert.Equal(t, 2, hp.HeaderCount())
+}
+
+func TestQPACKDecodeRequestCheckHost(t *testing.T) {
+	d := NewQPACKDecoder()
+	hp := NewHTTPParser()
+	hp.SetCheckHost(true)
+
+	block := qpackBlock(0x00, 0x00, 0xd1, 0x51, 0x01, "/", 0x50, 0x8c, huffmanExample, 0x24, "host", 0x04, "evil")
+	assert.Equal(t, ErrDuplicateHost, d.DecodeRequest(hp, block))
+
+	// Host may repeat :authority.
+	block = qpackBlock(0x00, 0x00, 0xd1, 0x51, 0x01, "/", 0x50, 0x8c, huffmanExample, 0x24, "host", 0x0f, "www.example.com")
+	assert.NoError(t, d.DecodeRequest(hp, block))
+
+	block = qpackBlock(0x00, 0x00, 0xd1, 0x51, 0x0e, "http://a.com/x", 0x50, 0x8c, huffmanExample)
+	assert.Equal(t, ErrHostMismatch, errors.Cause(d.DecodeRequest(hp, block)))
+}
+
+func TestQPACKDecodeRequestUppercaseName(t *testing.T) {
+	d := NewQPACKDecoder()
+
+	block := qpackBlock(0x00, 0x00, 0xd1, 0x51, 0x01, "/", 0x23, "X-A", 0x01, "b")
+	assert.Equal(t, ErrBadFieldSection, d.DecodeRequest(NewHTTPParser(), block))
+}
+
+func TestQPACKDecodeErrors(t *testing.T) {
+	d := NewQPACKDecoder()
+
+	_, err := d.Decode(qpackBlock(0x01, 0x00, 0xd1))
+	assert.Equal(t, ErrDynamicTable, err)
+
+	_, err = d.Decode(qpackBlock(0x00, 0x00, 0x80))
+	assert.Equal(t, ErrDynamicTable, err)
+
+	_, err = d.Decode(qpackBlock(0x00, 0x00, 0xff, 0x40))
+	assert.Equal(t, ErrBadFieldSection, err)
+
+	_, err = d.Decode(qpackBlock(0x00, 0x00, 0x51, 0x0b, "/in"))
+	assert.Equal(t, ErrBadFieldSection, err)
+
+	// Padding longer than 7 bits.
+	_, err = d.Decode(qpackBlock(0x00, 0x00, 0x51, 0x81, 0xff))
+	assert.Equal(t, ErrBadHuffman, err)
+
+	d.MaxFieldSectionSize = 40
+	_, err = d.Decode(qpackBlock(0x00, 0x00, 0xd1, 0xd1))
+	assert.Equal(t, ErrHeaderTooLarge, err)
+}
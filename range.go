@@ -0,0 +1,151 @@
+package wildcat
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrBadRange            = errors.New("malformed range")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// ByteRange is one range from a Range header, resolved against the size of
+// the representation.
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+var cBytesUnit = []byte("bytes=")
+
+// Parse a Range header value such as "bytes=0-99,200-" against a
+// representation of size bytes. Ranges that start past the end are dropped;
+// if that leaves none, ErrRangeNotSatisfiable is returned. Overlapping ranges
+// are returned as is for the caller to deal with.
+func ParseRange(value []byte, size int64) ([]ByteRange, error) {
+	value = bytes.TrimSpace(value)
+	if !bytes.HasPrefix(value, cBytesUnit) {
+		return nil, ErrBadRange
+	}
+
+	var ranges []ByteRange
+	noOverlap := false
+
+	for _, spec := range bytes.Split(value[len(cBytesUnit):], []byte(",")) {
+		spec = bytes.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+
+		dash := bytes.IndexByte(spec, '-')
+		if dash == -1 {
+			return nil, ErrBadRange
+		}
+
+		first := bytes.TrimSpace(spec[:dash])
+		last := bytes.TrimSpace(spec[dash+1:])
+
+		var r ByteRange
+
+		if len(first) == 0 {
+			// Suffix range: the last n bytes.
+			n, err := parseRangeInt(last)
+			if err != nil {
+				return nil, ErrBadRange
+			}
+
+			if n > size {
+				n = size
+			}
+
+			// Nothing to send, including any suffix of an empty
+			// representation.
+			if n == 0 {
+				noOverlap = true
+				continue
+			}
+
+			r.Start = size - n
+			r.Length = n
+		} else {
+			start, err := parseRangeInt(first)
+			if err != nil {
+				return nil, ErrBadRange
+			}
+
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+
+			r.Start = start
+
+			if len(last) == 0 {
+				r.Length = size - start
+			} else {
+				end, err := parseRangeInt(last)
+				if err != nil || end < start {
+					return nil, ErrBadRange
+				}
+
+				if end >= size {
+					end = size - 1
+				}
+
+				r.Length = end - start + 1
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, ErrRangeNotSatisfiable
+		}
+
+		return nil, ErrBadRange
+	}
+
+	return ranges, nil
+}
+
+func parseRangeInt(b []byte) (int64, error) {
+	for _, c := range b {
+		if !isDigit(c) {
+			return 0, ErrBadRange
+		}
+	}
+
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+// Parse the request's Range header against a representation of size bytes.
+// Returns nil, nil if there is no Range header.
+func (hp *HTTPParser) Range(size int64) ([]ByteRange, error) {
+	value := hp.findHeaderClass(hRange)
+	if value == nil {
+		return nil, nil
+	}
+
+	return ParseRange(value, size)
+}
+
+// Append the Content-Range value for r, "bytes start-end/size", to dst.
+func (r ByteRange) AppendContentRange(dst []byte, size int64) []byte {
+	dst = append(dst, "bytes "...)
+	dst = strconv.AppendInt(dst, r.Start, 10)
+	dst = append(dst, '-')
+	dst = strconv.AppendInt(dst, r.Start+r.Length-1, 10)
+	dst = append(dst, '/')
+	return strconv.AppendInt(dst, size, 10)
+}
+
+// Append the Content-Range value sent with a 416, "bytes */size", to dst.
+func appendUnsatisfiedRange(dst []byte, size int64) []byte {
+	dst = append(dst, "bytes */"...)
+	return strconv.AppendInt(dst, size, 10)
+}
@@ -0,0 +1,175 @@
+package wildcat
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		in     string
+		ranges []ByteRange
+		err    error
+	}{
+		{"bytes=0-99", []ByteRange{{0, 100}}, nil},
+		{"bytes=100-", []ByteRange{{100, 900}}, nil},
+		{"bytes=-100", []ByteRange{{900, 100}}, nil},
+		{"bytes=-5000", []ByteRange{{0, 1000}}, nil},
+		{"bytes=990-2000", []ByteRange{{990, 10}}, nil},
+		{"bytes=0-0, 10-19 ,", []ByteRange{{0, 1}, {10, 10}}, nil},
+		{"bytes=1000-, 0-1", []ByteRange{{0, 2}}, nil},
+		{"bytes=1000-", nil, ErrRangeNotSatisfiable},
+		{"bytes=-0", nil, ErrRangeNotSatisfiable},
+		{"bytes=5-1", nil, ErrBadRange},
+		{"bytes=a-b", nil, ErrBadRange},
+		{"bytes=", nil, ErrBadRange},
+		{"items=0-1", nil, ErrBadRange},
+		{"bytes=+1-2", nil, ErrBadRange},
+	}
+
+	for _, c := range cases {
+		ranges, err := ParseRange([]byte(c.in), 1000)
+		assert.Equal(t, c.err, err, c.in)
+		assert.Equal(t, c.ranges, ranges, c.in)
+	}
+
+	for _, in := range []string{"bytes=-5", "bytes=0-", "bytes=-0"} {
+		ranges, err := ParseRange([]byte(in), 0)
+		assert.Equal(t, ErrRangeNotSatisfiable, err, in)
+		assert.Nil(t, ranges, in)
+	}
+}
+
+func TestAppendContentRange(t *testing.T) {
+	r := ByteRange{Start: 10, Length: 5}
+	assert.Equal(t, "bytes 10-14/100", string(r.AppendContentRange(nil, 100)))
+}
+
+func sendFileResponse(t *testing.T, request string, f *os.File, size int64) (string, string) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	hp := NewHTTPParser()
+	_, err := hp.Parse([]byte(request))
+	require.NoError(t, err)
+
+	go func() {
+		resp := NewResponse(server)
+		assert.NoError(t, resp.SendFileRange(hp, f, size))
+		server.Close()
+	}()
+
+	r := bufio.NewReader(client)
+	head := readResponse(t, r)
+
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return head, string(body)
+}
+
+func TestSendFileRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0600))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	head, body := sendFileResponse(t, "GET / HTTP/1.1\r\n\r\n", f, 10)
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, head, "Accept-Ranges: bytes\r\n")
+	assert.Contains(t, head, "Content-Length: 10\r\n")
+	assert.Equal(t, "0123456789", body)
+
+	head, body = sendFileResponse(t, "GET / HTTP/1.1\r\nRange: bytes=2-4\r\n\r\n", f, 10)
+	assert.Contains(t, head, "HTTP/1.1 206 Partial Content\r\n")
+	assert.Contains(t, head, "Content-Range: bytes 2-4/10\r\n")
+	assert.Contains(t, head, "Content-Length: 3\r\n")
+	assert.Equal(t, "234", body)
+
+	head, body = sendFileResponse(t, "GET / HTTP/1.1\r\nRange: bytes=20-\r\n\r\n", f, 10)
+	assert.Contains(t, head, "HTTP/1.1 416 Requested Range Not Satisfiable\r\n")
+	assert.Contains(t, head, "Content-Range: bytes */10\r\n")
+	assert.Equal(t, "", body)
+}
+
+func TestSendFileRanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0600))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	head, body := sendFileResponse(t, "GET / HTTP/1.1\r\nRange: bytes=7-,0-1,1-2\r\n\r\n", f, 10)
+	assert.Contains(t, head, "HTTP/1.1 206 Partial Content\r\n")
+
+	_, params, err := mime.ParseMediaType(contentTypeOf(head))
+	require.NoError(t, err)
+	require.NotEqual(t, "", params["boundary"])
+
+	assert.Contains(t, head, "Content-Length: "+strconv.Itoa(len(body))+"\r\n")
+
+	mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+
+	var parts []string
+
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(p)
+		require.NoError(t, err)
+
+		parts = append(parts, p.Header.Get("Content-Range")+" "+string(data))
+	}
+
+	// Overlapping ranges are merged and put in order.
+	assert.Equal(t, []string{"bytes 0-2/10 012", "bytes 7-9/10 789"}, parts)
+
+	// Ranges that merge into one are sent as a single part.
+	head, body = sendFileResponse(t, "GET / HTTP/1.1\r\nRange: bytes=0-1,2-3\r\n\r\n", f, 10)
+	assert.Contains(t, head, "Content-Range: bytes 0-3/10\r\n")
+	assert.Equal(t, "0123", body)
+}
+
+func contentTypeOf(head string) string {
+	for _, line := range strings.Split(head, "\r\n") {
+		if strings.HasPrefix(line, "Content-Type: ") {
+			return strings.TrimPrefix(line, "Content-Type: ")
+		}
+	}
+
+	return ""
+}
+
+func TestSendFileRangesEmpty(t *testing.T) {
+	assert.Nil(t, coalesceRanges(nil))
+
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	go func() {
+		resp := NewResponse(server)
+		assert.NoError(t, resp.SendFileRanges(nil, nil, 10))
+		server.Close()
+	}()
+
+	head := readResponse(t, bufio.NewReader(client))
+	assert.Contains(t, head, "HTTP/1.1 416 Requested Range Not Satisfiable\r\n")
+	assert.Contains(t, head, "Content-Range: bytes */10\r\n")
+}
@@ -0,0 +1,153 @@
+package wildcat
+
+import (
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter keyed by client IP. Set it as
+// Server.RateLimiter to have requests over the limit answered with 429 Too
+// Many Requests before the handler sees them.
+type RateLimiter struct {
+	// Tokens added per second, and the bucket size.
+	Rate  float64
+	Burst int
+
+	// Proxies whose X-Forwarded-For entries are believed when resolving
+	// the client address. See HTTPParser.ClientIP.
+	TrustedProxies []netip.Prefix
+
+	// The most clients tracked at once. When it's reached, idle buckets are
+	// dropped and, if that isn't enough, an arbitrary one, so a flood of
+	// addresses can't grow memory without bound. Defaults to
+	// DefaultRateLimitClients.
+	MaxClients int
+
+	mu        sync.Mutex
+	buckets   map[netip.Addr]*tokenBucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Create a limiter allowing rate requests per second per client with bursts
+// of up to burst requests.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: make(map[netip.Addr]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// How often idle buckets are dropped so the map doesn't grow without bound.
+const rateLimitSweepInterval = time.Minute
+
+// The default MaxClients of a RateLimiter.
+const DefaultRateLimitClients = 100000
+
+// Take a token for ip. If none is available, ok is false and retryAfter is
+// how long until one will be.
+func (rl *RateLimiter) Allow(ip netip.Addr) (ok bool, retryAfter time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.now == nil {
+		rl.now = time.Now
+	}
+
+	if rl.buckets == nil {
+		rl.buckets = make(map[netip.Addr]*tokenBucket)
+	}
+
+	now := rl.now()
+	burst := float64(rl.Burst)
+
+	if now.Sub(rl.lastSweep) > rateLimitSweepInterval {
+		rl.sweep(now)
+	}
+
+	b := rl.buckets[ip]
+	if b == nil {
+		rl.makeRoom(now)
+
+		b = &tokenBucket{tokens: burst, last: now}
+		rl.buckets[ip] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rl.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if rl.Rate <= 0 {
+		return false, rateLimitSweepInterval
+	}
+
+	wait := time.Duration((1 - b.tokens) / rl.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Drop buckets that have refilled completely, they're the same as new ones.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for ip, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.Rate >= float64(rl.Burst) {
+			delete(rl.buckets, ip)
+		}
+	}
+
+	rl.lastSweep = now
+}
+
+// Make sure there's room for another bucket under MaxClients.
+func (rl *RateLimiter) makeRoom(now time.Time) {
+	max := rl.MaxClients
+	if max <= 0 {
+		max = DefaultRateLimitClients
+	}
+
+	if len(rl.buckets) < max {
+		return
+	}
+
+	rl.sweep(now)
+
+	for ip := range rl.buckets {
+		if len(rl.buckets) < max {
+			break
+		}
+
+		delete(rl.buckets, ip)
+	}
+}
+
+// Render the 429 sent to limited clients. The connection is closed after it
+// since the request body, if any, hasn't been read.
+func appendTooManyRequests(dst []byte, retryAfter time.Duration) []byte {
+	secs := int64((retryAfter + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+
+	dst = appendStatusLine(dst, statusTooManyRequests)
+	dst = append(dst, "Retry-After: "...)
+	dst = strconv.AppendInt(dst, secs, 10)
+	dst = append(dst, cCRLF...)
+	dst = append(dst, cConnClose...)
+	dst = append(dst, "Content-Length: 0\r\n\r\n"...)
+	return dst
+}
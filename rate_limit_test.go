@@ -0,0 +1,107 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	hp := NewHTTPParser()
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\nX-Forwarded-For: 6.6.6.6, 1.2.3.4\r\nX-Forwarded-For: 10.0.0.2\r\n\r\n"))
+	require.NoError(t, err)
+
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), hp.ClientIP(remote, nil))
+	assert.Equal(t, netip.MustParseAddr("1.2.3.4"), hp.ClientIP(remote, trusted))
+
+	untrusted := &net.TCPAddr{IP: net.ParseIP("9.9.9.9"), Port: 1234}
+	assert.Equal(t, netip.MustParseAddr("9.9.9.9"), hp.ClientIP(untrusted, trusted))
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	rl := NewRateLimiter(1, 2)
+
+	now := time.Unix(1000, 0)
+	rl.now = func() time.Time { return now }
+
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	ok, _ := rl.Allow(ip)
+	assert.True(t, ok)
+	ok, _ = rl.Allow(ip)
+	assert.True(t, ok)
+
+	ok, retry := rl.Allow(ip)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retry)
+
+	ok, _ = rl.Allow(netip.MustParseAddr("5.6.7.8"))
+	assert.True(t, ok)
+
+	now = now.Add(1500 * time.Millisecond)
+	ok, _ = rl.Allow(ip)
+	assert.True(t, ok)
+	ok, retry = rl.Allow(ip)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retry)
+}
+
+func TestRateLimiterZeroValue(t *testing.T) {
+	rl := &RateLimiter{Rate: 1, Burst: 1}
+
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	ok, _ := rl.Allow(ip)
+	assert.True(t, ok)
+
+	ok, _ = rl.Allow(ip)
+	assert.False(t, ok)
+}
+
+func TestRateLimiterMaxClients(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	rl.MaxClients = 10
+
+	now := time.Unix(1000, 0)
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 1000; i++ {
+		rl.Allow(netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)}))
+		assert.True(t, len(rl.buckets) <= 10)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	s := &Server{
+		Handler:     handlerFunc(helloHandler),
+		RateLimiter: NewRateLimiter(0.001, 1),
+	}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+
+	c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	head := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+	r.Discard(5)
+
+	c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	head = readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 429 Too Many Requests\r\n")
+	assert.Contains(t, head, "Retry-After: 1000\r\n")
+}
@@ -0,0 +1,36 @@
+package wildcat
+
+import "io"
+
+// ReadRequest reads from r into buf until hp can parse a complete request
+// header. It returns the size of the header and the number of bytes read
+// into buf; buf[headerLen:n] is the start of the body (or of the next
+// request) and can be passed to BodyReader as rest.
+//
+// If buf fills up before the header is complete, ErrHeaderTooLarge is
+// returned.
+func ReadRequest(hp *HTTPParser, r io.Reader, buf []byte) (headerLen, n int, err error) {
+	for {
+		if n == len(buf) {
+			return 0, n, ErrHeaderTooLarge
+		}
+
+		m, err := r.Read(buf[n:])
+		n += m
+
+		if m > 0 {
+			headerLen, perr := hp.Parse(buf[:n])
+			if perr != ErrMissingData {
+				return headerLen, n, perr
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return 0, n, err
+		}
+	}
+}
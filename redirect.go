@@ -0,0 +1,106 @@
+package wildcat
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrBadLocation      = errors.New("invalid Location in redirect")
+)
+
+// The number of redirects a RedirectChain follows by default.
+const DefaultMaxRedirects = 10
+
+// One hop of a redirect chain: the request to send next.
+type RedirectStep struct {
+	Status   int
+	Method   []byte
+	URL      *url.URL
+	KeepBody bool
+}
+
+// Report whether status is a redirect that can be followed.
+func IsRedirect(status int) bool {
+	switch status {
+	case StatusMovedPermanently, StatusFound, StatusSeeOther,
+		StatusTemporaryRedirect, StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// Work out the request that follows a redirect response to method on
+// base. location is the response's Location, resolved against base.
+//
+// 307 and 308 repeat the request as is. 303 turns anything but HEAD into a
+// GET without a body, and so do 301 and 302 for POST, as browsers do.
+func NextRedirect(method []byte, base *url.URL, status int, location []byte) (RedirectStep, error) {
+	if !IsRedirect(status) {
+		return RedirectStep{}, ErrBadLocation
+	}
+
+	if len(location) == 0 {
+		return RedirectStep{}, ErrBadLocation
+	}
+
+	loc, err := url.Parse(string(location))
+	if err != nil {
+		return RedirectStep{}, errors.Context(ErrBadLocation, err.Error())
+	}
+
+	target := base.ResolveReference(loc)
+
+	// A fragment on the original request carries over.
+	if target.Fragment == "" {
+		target.Fragment = base.Fragment
+	}
+
+	step := RedirectStep{Status: status, Method: method, URL: target, KeepBody: true}
+
+	switch status {
+	case StatusSeeOther:
+		if !bytes.Equal(method, cHead) {
+			step.Method, step.KeepBody = cGet, false
+		}
+	case StatusMovedPermanently, StatusFound:
+		if bytes.Equal(method, cPost) {
+			step.Method, step.KeepBody = cGet, false
+		}
+	}
+
+	return step, nil
+}
+
+// RedirectChain records the redirects followed for one request.
+type RedirectChain struct {
+	// 0 means DefaultMaxRedirects; negative means don't follow any.
+	MaxRedirects int
+
+	Steps []RedirectStep
+}
+
+// Add the redirect that follows a response and return the request to send,
+// or ErrTooManyRedirects once the limit is reached.
+func (rc *RedirectChain) Follow(method []byte, base *url.URL, status int, location []byte) (RedirectStep, error) {
+	max := rc.MaxRedirects
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+
+	if len(rc.Steps) >= max {
+		return RedirectStep{}, ErrTooManyRedirects
+	}
+
+	step, err := NextRedirect(method, base, status, location)
+	if err != nil {
+		return RedirectStep{}, err
+	}
+
+	rc.Steps = append(rc.Steps, step)
+	return step, nil
+}
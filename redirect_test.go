@@ -0,0 +1,59 @@
+package wildcat
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextRedirect(t *testing.T) {
+	base, err := url.Parse("http://example.com/a/b?q=1#top")
+	require.NoError(t, err)
+
+	tests := []struct {
+		method   string
+		status   int
+		want     string
+		keepBody bool
+	}{
+		{"POST", 301, "GET", false},
+		{"PUT", 302, "PUT", true},
+		{"POST", 303, "GET", false},
+		{"HEAD", 303, "HEAD", true},
+		{"POST", 307, "POST", true},
+		{"DELETE", 308, "DELETE", true},
+	}
+
+	for _, tt := range tests {
+		step, err := NextRedirect([]byte(tt.method), base, tt.status, []byte("../c"))
+		require.NoError(t, err)
+
+		assert.Equal(t, tt.want, string(step.Method), "%s %d", tt.method, tt.status)
+		assert.Equal(t, tt.keepBody, step.KeepBody, "%s %d", tt.method, tt.status)
+		assert.Equal(t, "http://example.com/c#top", step.URL.String())
+	}
+
+	_, err = NextRedirect(cGet, base, 304, []byte("/x"))
+	assert.Equal(t, ErrBadLocation, err)
+
+	_, err = NextRedirect(cGet, base, 302, nil)
+	assert.Equal(t, ErrBadLocation, err)
+}
+
+func TestRedirectChainLimit(t *testing.T) {
+	base, err := url.Parse("http://example.com/")
+	require.NoError(t, err)
+
+	rc := RedirectChain{MaxRedirects: 2}
+
+	for i := 0; i < 2; i++ {
+		_, err := rc.Follow(cGet, base, 302, []byte("/loop"))
+		require.NoError(t, err)
+	}
+
+	_, err = rc.Follow(cGet, base, 302, []byte("/loop"))
+	assert.Equal(t, ErrTooManyRedirects, err)
+	assert.Len(t, rc.Steps, 2)
+}
@@ -0,0 +1,183 @@
+package wildcat
+
+import (
+	"io"
+
+	"github.com/vektra/errors"
+)
+
+// A request read from a stream by Replay.
+type ReplayRequest struct {
+	*Request
+
+	// Where the request line starts in the stream.
+	Offset int64
+
+	// The size of the body after any chunked coding is removed. It's more
+	// than len(Body) when the body was truncated.
+	BodySize int64
+
+	Truncated bool
+}
+
+// Replay reads the requests out of the client half of a reassembled TCP
+// stream, as produced by a pcap reassembler like gopacket's tcpassembly,
+// for offline traffic analysis. Bodies are framed by Content-Length or the
+// chunked coding as a server would, so pipelined requests come out one at
+// a time.
+//
+// A Replay is not safe for concurrent use.
+type Replay struct {
+	// The largest request head buffered before Next gives up with
+	// ErrHeaderTooLarge. Defaults to DefaultMaxHeaderBytes.
+	MaxHeaderBytes int
+
+	// The most body bytes kept per request; the rest are read and
+	// dropped. 0 keeps the whole body.
+	MaxBodyBytes int64
+
+	r    io.Reader
+	hp   *HTTPParser
+	buf  []byte
+	n    int
+	read int64
+	err  error
+}
+
+// Create a Replay reading the stream from r.
+func NewReplay(r io.Reader) *Replay {
+	return &Replay{
+		MaxHeaderBytes: DefaultMaxHeaderBytes,
+		r:              r,
+		hp:             NewHTTPParser(),
+	}
+}
+
+// Return the parser used, to subscribe to headers or set options on.
+func (rp *Replay) Parser() *HTTPParser {
+	return rp.hp
+}
+
+// Return the next request in the stream. Returns io.EOF once the stream
+// ends between requests, and io.ErrUnexpectedEOF if it ends partway
+// through one.
+func (rp *Replay) Next() (*ReplayRequest, error) {
+	if rp.err != nil {
+		return nil, rp.err
+	}
+
+	req, err := rp.next()
+	if err != nil {
+		rp.err = err
+	}
+
+	return req, err
+}
+
+func (rp *Replay) next() (*ReplayRequest, error) {
+	if rp.buf == nil {
+		rp.buf = make([]byte, rp.MaxHeaderBytes)
+	}
+
+	for {
+		rest, err := skipToRequestLine(rp.buf[:rp.n])
+		if err != nil {
+			return nil, errors.Context(err, "replay stream")
+		}
+
+		rp.n = copy(rp.buf, rest)
+
+		if rp.n > 0 {
+			headLen, err := rp.hp.Parse(rp.buf[:rp.n])
+			if err == nil {
+				return rp.framed(headLen)
+			}
+
+			if err != ErrMissingData {
+				return nil, err
+			}
+
+			if rp.n == len(rp.buf) {
+				return nil, ErrHeaderTooLarge
+			}
+		}
+
+		m, err := rp.r.Read(rp.buf[rp.n:])
+		rp.n += m
+		rp.read += int64(m)
+
+		if m == 0 && err != nil {
+			if err == io.EOF && rp.n > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+	}
+}
+
+// Read the body of the request whose head is rp.buf[:headLen], leaving
+// whatever follows it at the start of rp.buf.
+func (rp *Replay) framed(headLen int) (*ReplayRequest, error) {
+	hp := rp.hp
+
+	req := &ReplayRequest{
+		Request: hp.Snapshot(),
+		Offset:  rp.read - int64(rp.n),
+	}
+
+	rest := rp.buf[headLen:rp.n]
+
+	// Without Content-Length or chunked coding a request has no body.
+	if hp.findHeaderClass(hTransferEncoding) != nil || hp.ContentLength() > 0 {
+		counted := &countingReader{r: rp.r}
+
+		body := hp.BodyReader(rest, io.NopCloser(counted))
+
+		var err error
+
+		if rp.MaxBodyBytes > 0 {
+			req.Body, err = io.ReadAll(io.LimitReader(body, rp.MaxBodyBytes))
+			if err == nil {
+				var dropped int64
+				dropped, err = io.Copy(io.Discard, body)
+				req.BodySize = dropped
+				req.Truncated = dropped > 0
+			}
+		} else {
+			req.Body, err = io.ReadAll(body)
+		}
+
+		rp.read += counted.n
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.BodySize += int64(len(req.Body))
+
+		if len(req.Body) == 0 {
+			req.Body = nil
+		}
+	}
+
+	next, ok := hp.bodyLeftover(rest)
+	if !ok {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	rp.n = copy(rp.buf, next)
+
+	return req, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+	return n, err
+}
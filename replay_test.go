@@ -0,0 +1,90 @@
+package wildcat
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var replayStream = "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n" +
+	"POST /b HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nhello" +
+	"\r\n" +
+	"POST /c HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n" +
+	"GET /d HTTP/1.1\r\nHost: example.com\r\n\r\n"
+
+func TestReplay(t *testing.T) {
+	// One byte at a time, so every request spans many reads.
+	rp := NewReplay(iotest.OneByteReader(bytes.NewReader([]byte(replayStream))))
+
+	var paths, bodies, lines []string
+
+	for {
+		req, err := rp.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		paths = append(paths, string(req.Path))
+		bodies = append(bodies, string(req.Body))
+		lines = append(lines, replayStream[req.Offset:req.Offset+int64(len(req.Method)+1+len(req.Path))])
+	}
+
+	assert.Equal(t, []string{"/a", "/b", "/c", "/d"}, paths)
+	assert.Equal(t, []string{"", "hello", "abcde", ""}, bodies)
+	assert.Equal(t, []string{"GET /a", "POST /b", "POST /c", "GET /d"}, lines)
+}
+
+func TestReplayTruncatesBodies(t *testing.T) {
+	rp := NewReplay(bytes.NewReader([]byte(replayStream)))
+	rp.MaxBodyBytes = 2
+
+	_, err := rp.Next()
+	require.NoError(t, err)
+
+	req, err := rp.Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, "he", string(req.Body))
+	assert.Equal(t, int64(5), req.BodySize)
+	assert.True(t, req.Truncated)
+
+	req, err = rp.Next()
+	require.NoError(t, err)
+
+	assert.Equal(t, "ab", string(req.Body))
+	assert.Equal(t, int64(5), req.BodySize)
+
+	req, err = rp.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "/d", string(req.Path))
+	assert.False(t, req.Truncated)
+}
+
+func TestReplayUnexpectedEOF(t *testing.T) {
+	rp := NewReplay(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: exa")))
+
+	_, err := rp.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	rp = NewReplay(bytes.NewReader([]byte("POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nshort")))
+
+	_, err = rp.Next()
+	assert.Error(t, err)
+
+	// The error sticks.
+	_, err2 := rp.Next()
+	assert.Equal(t, err, err2)
+}
+
+func TestReplayHeaderTooLarge(t *testing.T) {
+	rp := NewReplay(bytes.NewReader([]byte("GET / HTTP/1.1\r\nX-Big: " + string(bytes.Repeat([]byte("a"), 100)))))
+	rp.MaxHeaderBytes = 64
+
+	_, err := rp.Next()
+	assert.Equal(t, ErrHeaderTooLarge, err)
+}
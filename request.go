@@ -0,0 +1,109 @@
+package wildcat
+
+import "bytes"
+
+// Request is a copy of the results of a parse that owns its memory, so that
+// it remains valid after the parser is reset and the input buffer reused.
+type Request struct {
+	Method, Path, Version []byte
+
+	Headers []header
+
+	// The request body, when the request was framed by a Codec. Snapshot
+	// leaves it nil.
+	Body []byte
+}
+
+// Return a deep copy of the method, path, version and headers of the last
+// Parse. All the data is copied into a single new buffer.
+func (hp *HTTPParser) Snapshot() *Request {
+	headers := hp.Headers[:hp.numHeaders]
+
+	size := len(hp.Method) + len(hp.Path) + len(hp.Version)
+
+	for _, h := range headers {
+		size += len(h.Name) + len(h.Value)
+	}
+
+	buf := make([]byte, 0, size)
+
+	own := func(b []byte) []byte {
+		if b == nil {
+			return nil
+		}
+
+		start := len(buf)
+		buf = append(buf, b...)
+		return buf[start:len(buf):len(buf)]
+	}
+
+	req := &Request{
+		Method:  own(hp.Method),
+		Path:    own(hp.Path),
+		Version: own(hp.Version),
+		Headers: make([]header, len(headers)),
+	}
+
+	for i, h := range headers {
+		name := h.Name
+
+		// Interned names are shared and immutable already.
+		if !hp.internHeaderNames || h.class == hUnknown {
+			name = own(name)
+		}
+
+		req.Headers[i] = header{name, own(h.Value), h.class}
+	}
+
+	return req
+}
+
+// Return the number of headers in the request.
+func (r *Request) HeaderCount() int {
+	return len(r.Headers)
+}
+
+// Return the name and value of the i'th header, in the order they
+// appeared in the request. Returns nil, nil if i is out of range.
+func (r *Request) HeaderAt(i int) (name, value []byte) {
+	if i < 0 || i >= len(r.Headers) {
+		return nil, nil
+	}
+
+	h := &r.Headers[i]
+	return h.Name, h.Value
+}
+
+// Return a value of a header matching name.
+func (r *Request) FindHeader(name []byte) []byte {
+	if class := lookupHeaderClass(name); class != hUnknown {
+		for _, h := range r.Headers {
+			if h.class == class {
+				return h.Value
+			}
+		}
+
+		return nil
+	}
+
+	for _, h := range r.Headers {
+		if bytes.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+
+	return nil
+}
+
+// Return all values of a header matching name.
+func (r *Request) FindAllHeaders(name []byte) [][]byte {
+	var headers [][]byte
+
+	for _, h := range r.Headers {
+		if bytes.EqualFold(h.Name, name) {
+			headers = append(headers, h.Value)
+		}
+	}
+
+	return headers
+}
@@ -0,0 +1,92 @@
+package wildcat
+
+import (
+	"context"
+	"math/rand"
+)
+
+// The longest incoming X-Request-Id that's used as is. Longer ones, like
+// ones with bytes other than visible ASCII, are replaced.
+const MaxRequestIDLength = 128
+
+var cXRequestID = []byte("X-Request-Id")
+
+type requestIDKey struct{}
+
+// Return the request ID stored in ctx by the server, or nil.
+func RequestIDFromContext(ctx context.Context) []byte {
+	id, _ := ctx.Value(requestIDKey{}).([]byte)
+	return id
+}
+
+// Append a new random request ID, 32 hex digits, to dst. It's meant to
+// tell requests apart in logs, not to be unguessable.
+func AppendNewRequestID(dst []byte) []byte {
+	var b [16]byte
+
+	hi, lo := rand.Uint64(), rand.Uint64()
+	for i := 0; i < 8; i++ {
+		b[i] = byte(hi >> (8 * i))
+		b[8+i] = byte(lo >> (8 * i))
+	}
+
+	return appendLowerHex(dst, b[:])
+}
+
+const lowerHex = "0123456789abcdef"
+
+func appendLowerHex(dst, b []byte) []byte {
+	for _, c := range b {
+		dst = append(dst, lowerHex[c>>4], lowerHex[c&15])
+	}
+
+	return dst
+}
+
+func validRequestID(id []byte) bool {
+	if len(id) == 0 || len(id) > MaxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7E {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Append the request's ID to dst: the X-Request-Id header if it's
+// present and sane, else the trace ID of a valid traceparent header, else
+// a new one from AppendNewRequestID.
+func (hp *HTTPParser) AppendRequestID(dst []byte) []byte {
+	if id := hp.findHeaderClass(hXRequestID); validRequestID(id) {
+		return append(dst, id...)
+	}
+
+	if tp, err := hp.TraceParent(); err == nil {
+		return appendLowerHex(dst, tp.TraceID[:])
+	}
+
+	return AppendNewRequestID(dst)
+}
+
+// Return the ID the server assigned to the request, when Server.RequestID
+// is set. Like the ID in the request's context, it's only valid until the
+// handler returns.
+func (hp *HTTPParser) RequestID() []byte {
+	return hp.requestID
+}
+
+// Give the request hp was parsed from an ID, and have responses written to
+// c carry it.
+func (c *serverConn) assignRequestID(hp *HTTPParser, buf []byte) []byte {
+	id := hp.AppendRequestID(buf[:0])
+
+	hp.requestID = id
+	hp.ctx = context.WithValue(hp.Context(), requestIDKey{}, id)
+	c.requestID = id
+
+	return id
+}
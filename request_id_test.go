@@ -0,0 +1,65 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendRequestID(t *testing.T) {
+	hp := parseRequest(t, "GET / HTTP/1.1\r\nX-Request-Id: abc-123\r\n\r\n")
+	assert.Equal(t, "abc-123", string(hp.AppendRequestID(nil)))
+
+	hp = parseRequest(t, "GET / HTTP/1.1\r\n"+
+		"traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\r\n\r\n")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", string(hp.AppendRequestID(nil)))
+
+	hp = parseRequest(t, "GET / HTTP/1.1\r\nX-Request-Id: has space\r\n\r\n")
+	id := hp.AppendRequestID([]byte("id="))
+	assert.Len(t, id, 3+32)
+	assert.NotEqual(t, string(id), string(hp.AppendRequestID([]byte("id="))))
+}
+
+func TestServerRequestID(t *testing.T) {
+	ids := make(chan string, 2)
+
+	s := &Server{
+		RequestID: true,
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			assert.Equal(t, string(hp.RequestID()), string(RequestIDFromContext(hp.Context())))
+			ids <- string(hp.RequestID())
+			helloHandler(hp, rest, c)
+		}),
+	}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nX-Request-Id: given\r\n\r\n"))
+	require.NoError(t, err)
+
+	head := readResponse(t, r)
+	assert.Contains(t, head, "X-Request-Id: given\r\n")
+	assert.Equal(t, "given", <-ids)
+
+	_, err = r.Discard(5)
+	require.NoError(t, err)
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	head = readResponse(t, r)
+	id := <-ids
+	assert.Len(t, id, 32)
+	assert.Contains(t, head, "X-Request-Id: "+id+"\r\n")
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"strconv"
 )
 
 type Response struct {
@@ -12,6 +14,9 @@ type Response struct {
 
 	headers    []header
 	numHeaders int
+	presets    []*HeaderPreset
+
+	wroteConnClose bool
 }
 
 func NewResponse(c net.Conn) *Response {
@@ -29,7 +34,7 @@ func (r *Response) AddHeader(key, val []byte) {
 		r.headers = newHeaders
 	}
 
-	r.headers[r.numHeaders] = header{key, val}
+	r.headers[r.numHeaders] = header{Name: key, Value: val}
 	r.numHeaders++
 }
 
@@ -37,9 +42,23 @@ func (r *Response) AddStringHeader(key, val string) {
 	r.AddHeader([]byte(key), []byte(val))
 }
 
+// Include the headers of p when WriteHeaders is called. The preset is
+// shared, not copied, so the same one can be used by every response.
+func (r *Response) AddPreset(p *HeaderPreset) {
+	r.presets = append(r.presets, p)
+}
+
 func (r *Response) WriteStatus(code int) {
-	status := fmt.Sprintf("HTTP/1.1 %d %s\r\n", code, statusText[code])
-	r.c.Write([]byte(status))
+	r.c.Write(appendStatusLine(nil, code))
+}
+
+// Append an HTTP/1.1 status line for code to dst.
+func appendStatusLine(dst []byte, code int) []byte {
+	dst = append(dst, "HTTP/1.1 "...)
+	dst = strconv.AppendInt(dst, int64(code), 10)
+	dst = append(dst, ' ')
+	dst = append(dst, statusText[code]...)
+	return append(dst, cCRLF...)
 }
 
 var (
@@ -48,6 +67,27 @@ var (
 	cConnClose = []byte("Connection: close\r\n")
 )
 
+var cLink = []byte("Link: ")
+
+// Send a 103 Early Hints interim response carrying a Link header for each
+// of links, such as `</style.css>; rel=preload; as=style`. It can be called
+// any number of times before WriteStatus. Interim responses can't be sent
+// to HTTP/1.0 clients, so only use this for HTTP/1.1 requests.
+func (r *Response) WriteEarlyHints(links ...[]byte) error {
+	buf := appendStatusLine(nil, StatusEarlyHints)
+
+	for _, link := range links {
+		buf = append(buf, cLink...)
+		buf = append(buf, link...)
+		buf = append(buf, cCRLF...)
+	}
+
+	buf = append(buf, cCRLF...)
+
+	_, err := r.c.Write(buf)
+	return err
+}
+
 func (r *Response) WriteHeaders() {
 	var buf bytes.Buffer
 
@@ -59,6 +99,22 @@ func (r *Response) WriteHeaders() {
 		buf.Write(cCRLF)
 	}
 
+	for _, p := range r.presets {
+		buf.Write(p.block)
+	}
+
+	if sc, ok := r.c.(*serverConn); ok && sc.requestID != nil {
+		buf.Write(cXRequestID)
+		buf.Write(cColon)
+		buf.Write(sc.requestID)
+		buf.Write(cCRLF)
+	}
+
+	if closingConn(r.c) {
+		buf.Write(cConnClose)
+		r.wroteConnClose = true
+	}
+
 	r.c.Write(buf.Bytes())
 }
 
@@ -77,6 +133,94 @@ func (r *Response) WriteBodySizedStream(size int, reader io.Reader) {
 }
 
 func (r *Response) WriteBodyStream(size int, reader io.Reader) {
-	r.c.Write(cConnClose)
+	if !r.wroteConnClose {
+		r.c.Write(cConnClose)
+	}
 	io.Copy(r.c, reader)
 }
+
+var (
+	cTrailer          = []byte("Trailer")
+	cTransferChunked  = []byte("Transfer-Encoding: chunked\r\n\r\n")
+	cTrailerListSplit = []byte(", ")
+)
+
+// Announce the trailer fields that will follow a chunked body, in a
+// Trailer header. Call it before WriteHeaders, and set the values with
+// the ChunkedWriter's SetTrailer.
+func (r *Response) DeclareTrailer(names ...[]byte) {
+	r.AddHeader(cTrailer, bytes.Join(names, cTrailerListSplit))
+}
+
+// End the headers and return a writer for a body sent with the chunked
+// transfer coding. The body ends, and any trailers are sent, when the
+// writer is closed.
+func (r *Response) WriteBodyChunked() *ChunkedWriter {
+	r.c.Write(cTransferChunked)
+	return NewChunkedWriter(r.c)
+}
+
+// Write a Content-Length of n and then n bytes of f starting at off as the
+// body. When the connection supports it (plain TCP on linux, for instance)
+// the data is sent with sendfile and never copied through userspace.
+func (r *Response) SendFile(f *os.File, off, n int64) error {
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+
+	hdr := append([]byte("Content-Length: "), strconv.FormatInt(n, 10)...)
+	hdr = append(hdr, cCRLF...)
+	hdr = append(hdr, cCRLF...)
+
+	if _, err := r.c.Write(hdr); err != nil {
+		return err
+	}
+
+	// io.Copy hands the LimitedReader to the connection's ReadFrom, which
+	// recognizes the *os.File inside and uses sendfile.
+	_, err := io.Copy(r.c, &io.LimitedReader{R: f, N: n})
+	return err
+}
+
+var (
+	cAcceptRanges = []byte("Accept-Ranges")
+	cBytes        = []byte("bytes")
+	cContentRange = []byte("Content-Range")
+)
+
+// Write a 416 for a representation of size bytes.
+func (r *Response) writeUnsatisfiedRange(size int64) {
+	r.AddHeader(cContentRange, appendUnsatisfiedRange(nil, size))
+	r.WriteStatus(StatusRequestedRangeNotSatisfiable)
+	r.WriteHeaders()
+	r.WriteBodyBytes(nil)
+}
+
+// Write a complete response for f, which is size bytes long, honoring the
+// request's Range header: a single satisfiable range is sent as a 206 with
+// Content-Range, several as a multipart/byteranges 206 (see SendFileRanges),
+// an unsatisfiable one gets a 416, and otherwise the whole file is sent with
+// a 200. Headers added beforehand are included.
+func (r *Response) SendFileRange(hp *HTTPParser, f *os.File, size int64) error {
+	r.AddHeader(cAcceptRanges, cBytes)
+
+	ranges, err := hp.Range(size)
+
+	switch {
+	case err == ErrRangeNotSatisfiable:
+		r.writeUnsatisfiedRange(size)
+		return nil
+	case err == nil && len(ranges) == 1:
+		rng := ranges[0]
+		r.AddHeader(cContentRange, rng.AppendContentRange(nil, size))
+		r.WriteStatus(StatusPartialContent)
+		r.WriteHeaders()
+		return r.SendFile(f, rng.Start, rng.Length)
+	case err == nil && len(ranges) > 1:
+		return r.SendFileRanges(f, ranges, size)
+	}
+
+	r.WriteStatus(StatusOK)
+	r.WriteHeaders()
+	return r.SendFile(f, 0, size)
+}
@@ -0,0 +1,125 @@
+package wildcat
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vektra/errors"
+)
+
+// Parses HTTP responses, for clients and proxies reading from an
+// upstream. Headers are handled by the embedded HTTPParser, so
+// SubscribeHeader, SubscribeAllHeader, StopAtHeader, SetCaptureHeaders and
+// the lookups work just as they do for requests. Method and Path are left
+// unset.
+type ResponseParser struct {
+	*HTTPParser
+
+	StatusCode int
+	Reason     []byte
+}
+
+// Create a new response parser.
+func NewResponseParser() *ResponseParser {
+	return &ResponseParser{HTTPParser: NewHTTPParser()}
+}
+
+// Parse the buffer as an HTTP response head, returning the number of bytes
+// used, as HTTPParser.Parse does. A malformed status line gives
+// ErrBadResponse.
+func (rp *ResponseParser) Parse(input []byte) (int, error) {
+	n, err := rp.parse(input)
+	if err != nil && err != ErrMissingData && err != ErrStoppedAtHeader && rp.parseErrorHook != nil {
+		rp.reportParseError(input, err)
+	}
+
+	return n, err
+}
+
+// Continue after Parse returned ErrStoppedAtHeader or ErrMissingData, or
+// parse input from the start if neither happened.
+func (rp *ResponseParser) Resume(input []byte) (int, error) {
+	if !rp.resuming {
+		return rp.Parse(input)
+	}
+
+	return rp.HTTPParser.Resume(input)
+}
+
+var cHTTP1 = []byte("HTTP/1.")
+
+func (rp *ResponseParser) parse(input []byte) (int, error) {
+	total := len(input)
+
+	rp.resetParse()
+	rp.Method, rp.Path = nil, nil
+
+	eol := -1
+
+	for i := 0; i < total; i++ {
+		if input[i] == '\n' {
+			eol = i
+			break
+		}
+	}
+
+	if eol == -1 {
+		return 0, rp.missingRequestLine(total)
+	}
+
+	line := input[:eol]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	// "HTTP/1.1 200", then an optional reason.
+	if len(line) < 12 || !bytes.HasPrefix(line, cHTTP1) || line[8] != ' ' || !isDigit(line[9]) || !isDigit(line[10]) || !isDigit(line[11]) {
+		rp.errOffset = 0
+		return 0, ErrBadResponse
+	}
+
+	if len(line) > 12 && line[12] != ' ' {
+		rp.errOffset = 12
+		return 0, errors.Context(ErrBadResponse, "bad status code")
+	}
+
+	rp.Version = line[:8]
+	rp.StatusCode = int(line[9]-'0')*100 + int(line[10]-'0')*10 + int(line[11]-'0')
+	rp.Reason = nil
+
+	if len(line) > 13 {
+		rp.Reason = line[13:]
+	}
+
+	return rp.parseHeaders(input, parseResume{at: eol + 1, slot: -1, state: eNextHeader})
+}
+
+// Return every Set-Cookie value, whether or not the header was subscribed
+// to, since they can't be joined into one as other headers can.
+func (rp *ResponseParser) SetCookies() [][]byte {
+	return rp.setCookies
+}
+
+// Report whether the response can have a body. 1xx, 204 and 304 responses
+// never do, and neither do responses to HEAD.
+func (rp *ResponseParser) HasBody(head bool) bool {
+	switch {
+	case head:
+		return false
+	case rp.StatusCode < 200, rp.StatusCode == StatusNoContent, rp.StatusCode == StatusNotModified:
+		return false
+	}
+
+	return true
+}
+
+// Return a reader for the response body, or nil if it has none. head is
+// set when the request was a HEAD. A body with neither Content-Length nor
+// chunked encoding runs until in is closed.
+func (rp *ResponseParser) BodyReader(rest []byte, in io.ReadCloser, head bool) io.ReadCloser {
+	if !rp.HasBody(head) {
+		return nil
+	}
+
+	return rp.HTTPParser.BodyReader(rest, in)
+}
@@ -0,0 +1,142 @@
+package wildcat
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+var simpleResponse = []byte("HTTP/1.1 200 OK\r\nContent-Length: 5\r\nConnection: keep-alive\r\n" +
+	"Set-Cookie: a=1\r\nServer: test\r\nSet-Cookie: b=2; Path=/\r\n\r\nhello")
+
+func TestResponseParserParse(t *testing.T) {
+	rp := NewResponseParser()
+
+	n, err := rp.Parse(simpleResponse)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", string(simpleResponse[n:]))
+	assert.Equal(t, "HTTP/1.1", string(rp.Version))
+	assert.Equal(t, 200, rp.StatusCode)
+	assert.Equal(t, "OK", string(rp.Reason))
+	assert.Nil(t, rp.Method)
+
+	assert.Equal(t, 5, rp.HeaderCount())
+	assert.Equal(t, "test", string(rp.FindHeader([]byte("server"))))
+	assert.Equal(t, int64(5), rp.ContentLength())
+	assert.Equal(t, "keep-alive", string(rp.Connection()))
+
+	cookies := rp.SetCookies()
+	require.Len(t, cookies, 2)
+	assert.Equal(t, "a=1", string(cookies[0]))
+	assert.Equal(t, "b=2; Path=/", string(cookies[1]))
+}
+
+func TestResponseParserSubscribe(t *testing.T) {
+	rp := NewResponseParser()
+	rp.SubscribeAllHeader(false)
+	rp.SubscribeHeader([]byte("Server"))
+
+	_, err := rp.Parse(simpleResponse)
+	require.NoError(t, err)
+
+	// Content-Length is always kept, as it is for requests.
+	assert.Equal(t, 2, rp.HeaderCount())
+	assert.Equal(t, "test", string(rp.FindHeader([]byte("Server"))))
+	assert.Nil(t, rp.FindHeader([]byte("Set-Cookie")))
+
+	// The fast-path headers are still seen.
+	assert.Equal(t, int64(5), rp.ContentLength())
+	assert.Equal(t, "keep-alive", string(rp.Connection()))
+	assert.Len(t, rp.SetCookies(), 2)
+
+	_, err = rp.Parse([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Len(t, rp.SetCookies(), 0)
+	assert.Nil(t, rp.Connection())
+}
+
+func TestResponseParserStatusLine(t *testing.T) {
+	rp := NewResponseParser()
+
+	_, err := rp.Parse([]byte("HTTP/1.1 404\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 404, rp.StatusCode)
+	assert.Nil(t, rp.Reason)
+
+	_, err = rp.Parse([]byte("HTTP/1.1 200 OK"))
+	assert.Equal(t, ErrMissingData, err)
+
+	_, err = rp.Parse([]byte("HTTP/1.1 20x OK\r\n\r\n"))
+	assert.Equal(t, ErrBadResponse, err)
+
+	_, err = rp.Parse([]byte("HTTP/1.1 2000 OK\r\n\r\n"))
+	assert.Equal(t, ErrBadResponse, errors.Cause(err))
+
+	for _, bad := range []string{"GARBAGE! 200 OK\r\n\r\n", "HTTP/2.0 200 OK\r\n\r\n"} {
+		_, err = rp.Parse([]byte(bad))
+		assert.Equal(t, ErrBadResponse, err, bad)
+	}
+}
+
+func TestResponseParserResume(t *testing.T) {
+	rp := NewResponseParser()
+
+	_, err := rp.Parse(simpleResponse[:30])
+	assert.Equal(t, ErrMissingData, err)
+
+	n, err := rp.Resume(simpleResponse)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", string(simpleResponse[n:]))
+	assert.Equal(t, 200, rp.StatusCode)
+	assert.Len(t, rp.SetCookies(), 2)
+}
+
+func TestResponseParserBodyReader(t *testing.T) {
+	rp := NewResponseParser()
+
+	n, err := rp.Parse(simpleResponse)
+	require.NoError(t, err)
+
+	body := rp.BodyReader(simpleResponse[n:], io.NopCloser(bytes.NewReader(nil)), false)
+	require.NotNil(t, body)
+
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	assert.Nil(t, rp.BodyReader(simpleResponse[n:], nil, true))
+
+	chunked := []byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n")
+
+	n, err = rp.Parse(chunked)
+	require.NoError(t, err)
+
+	data, err = io.ReadAll(rp.BodyReader(chunked[n:], io.NopCloser(bytes.NewReader(nil)), false))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = rp.Parse([]byte("HTTP/1.1 304 Not Modified\r\nContent-Length: 5\r\n\r\n"))
+	require.NoError(t, err)
+
+	assert.Nil(t, rp.BodyReader(nil, nil, false))
+
+	// Without a length the body runs to the end of the connection.
+	unsized := []byte("HTTP/1.0 200 OK\r\n\r\nall of it")
+
+	n, err = rp.Parse(unsized)
+	require.NoError(t, err)
+
+	body = rp.BodyReader(unsized[n:], io.NopCloser(bytes.NewReader([]byte(" and more"))), false)
+
+	data, err = io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "all of it and more", string(data))
+}
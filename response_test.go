@@ -0,0 +1,88 @@
+package wildcat
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEarlyHints(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	go func() {
+		resp := NewResponse(server)
+		resp.WriteEarlyHints([]byte("</a.css>; rel=preload; as=style"), []byte("</b.js>; rel=preload; as=script"))
+		resp.WriteStatus(200)
+		resp.WriteHeaders()
+		resp.WriteBodyString("ok")
+		server.Close()
+	}()
+
+	out, err := io.ReadAll(client)
+	require.NoError(t, err)
+
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\n"+
+		"Link: </a.css>; rel=preload; as=style\r\n"+
+		"Link: </b.js>; rel=preload; as=script\r\n\r\n"+
+		"HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok", string(out))
+}
+
+func TestAddPreset(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	sh := DefaultSecurityHeaders
+	sh.ContentSecurityPolicy = ""
+	preset := sh.Preset()
+
+	go func() {
+		resp := NewResponse(server)
+		resp.AddStringHeader("X-Runtime", "1")
+		resp.AddPreset(preset)
+		resp.WriteStatus(200)
+		resp.WriteHeaders()
+		resp.WriteBodyString("ok")
+		server.Close()
+	}()
+
+	out, err := io.ReadAll(client)
+	require.NoError(t, err)
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"X-Runtime: 1\r\n"+
+		"Strict-Transport-Security: max-age=63072000; includeSubDomains\r\n"+
+		"X-Content-Type-Options: nosniff\r\n"+
+		"X-Frame-Options: DENY\r\n"+
+		"Referrer-Policy: strict-origin-when-cross-origin\r\n"+
+		"Content-Length: 2\r\n\r\nok", string(out))
+}
+
+func TestResponseTrailers(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	go func() {
+		resp := NewResponse(server)
+		resp.DeclareTrailer([]byte("Grpc-Status"), []byte("Grpc-Message"))
+		resp.WriteStatus(200)
+		resp.WriteHeaders()
+
+		cw := resp.WriteBodyChunked()
+		cw.Write([]byte("data"))
+		cw.SetTrailer([]byte("Grpc-Status"), []byte("0"))
+		cw.SetTrailer([]byte("Grpc-Message"), []byte("OK"))
+		cw.Close()
+		server.Close()
+	}()
+
+	out, err := io.ReadAll(client)
+	require.NoError(t, err)
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Trailer: Grpc-Status, Grpc-Message\r\n"+
+		"Transfer-Encoding: chunked\r\n\r\n"+
+		"4\r\ndata\r\n0\r\nGrpc-Status: 0\r\nGrpc-Message: OK\r\n\r\n", string(out))
+}
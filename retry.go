@@ -0,0 +1,193 @@
+package wildcat
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timeouts for one request made by a client. Zero means no limit.
+type Timeouts struct {
+	// Connecting to the server.
+	Dial time.Duration
+
+	// From sending the request to having the response header.
+	Header time.Duration
+
+	// The whole request, including dialing and reading the body.
+	Total time.Duration
+}
+
+// Dial addr, limited by the Dial timeout. The connection's deadline is set
+// so that it can't outlive the Total timeout, counted from start.
+func (t Timeouts) DialContext(ctx context.Context, start time.Time, network, addr string) (net.Conn, error) {
+	if t.Dial > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Dial)
+		defer cancel()
+	}
+
+	var d net.Dialer
+
+	c, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Total > 0 {
+		c.SetDeadline(start.Add(t.Total))
+	}
+
+	return c, nil
+}
+
+// Return the read deadline to use while waiting for the response header to
+// a request sent at sent, for a request started at start. The zero time
+// means no deadline.
+func (t Timeouts) HeaderDeadline(start, sent time.Time) time.Time {
+	var deadline time.Time
+
+	if t.Header > 0 {
+		deadline = sent.Add(t.Header)
+	}
+
+	if t.Total > 0 {
+		if total := start.Add(t.Total); deadline.IsZero() || total.Before(deadline) {
+			deadline = total
+		}
+	}
+
+	return deadline
+}
+
+// The defaults used by RetryPolicy for zero fields.
+const (
+	DefaultMaxRetries = 2
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 2 * time.Second
+)
+
+// RetryPolicy decides whether a failed request is tried again, and when.
+// By default only idempotent requests are retried.
+type RetryPolicy struct {
+	// The number of retries after the first attempt. Negative disables
+	// retries.
+	MaxRetries int
+
+	// The delay before the first retry, doubled for each one after it, up
+	// to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retry requests using methods that aren't idempotent too. Only set
+	// this when the server is known to deduplicate them.
+	RetryNonIdempotent bool
+
+	// Optional limit on retries across requests.
+	Budget *RetryBudget
+}
+
+// Report whether to retry a request using method after attempt (1 for the
+// first) failed, and how long to wait first.
+func (p *RetryPolicy) Retry(method []byte, attempt int) (time.Duration, bool) {
+	max := p.MaxRetries
+	if max == 0 {
+		max = DefaultMaxRetries
+	}
+
+	if attempt > max || (!p.RetryNonIdempotent && !IsIdempotent(method)) {
+		return 0, false
+	}
+
+	if p.Budget != nil && !p.Budget.Withdraw() {
+		return 0, false
+	}
+
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff, true
+}
+
+// The default Window of a RetryBudget.
+const DefaultRetryWindow = 100
+
+// RetryBudget caps retries to a fraction of requests, so that retries can't
+// multiply the load on a server that is already failing.
+type RetryBudget struct {
+	// Retries allowed per request made, such as 0.2.
+	Ratio float64
+
+	// Retries allowed before any requests have been recorded. This is a
+	// one-time starting allowance: once used, it isn't topped up.
+	MinRetries int
+
+	// How many requests' worth of retries can be saved up: the budget never
+	// holds more than MinRetries + Ratio*Window, so a long run of successes
+	// can't bank a burst of retries. Defaults to DefaultRetryWindow.
+	Window int
+
+	mu      sync.Mutex
+	tokens  float64
+	started bool
+}
+
+// Record a request, adding to the budget.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.init()
+	b.tokens += b.Ratio
+
+	if max := b.maxTokens(); b.tokens > max {
+		b.tokens = max
+	}
+}
+
+func (b *RetryBudget) maxTokens() float64 {
+	window := b.Window
+	if window <= 0 {
+		window = DefaultRetryWindow
+	}
+
+	return float64(b.MinRetries) + b.Ratio*float64(window)
+}
+
+// Take a retry out of the budget, reporting whether one was available.
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.init()
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+func (b *RetryBudget) init() {
+	if !b.started {
+		b.tokens = float64(b.MinRetries)
+		b.started = true
+	}
+}
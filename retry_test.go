@@ -0,0 +1,84 @@
+package wildcat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	assert.True(t, IsIdempotent([]byte("GET")))
+	assert.True(t, IsIdempotent([]byte("DELETE")))
+	assert.False(t, IsIdempotent([]byte("POST")))
+	assert.False(t, IsIdempotent([]byte("PATCH")))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := &RetryPolicy{MaxRetries: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond}
+
+	var delays []time.Duration
+
+	for attempt := 1; ; attempt++ {
+		d, ok := p.Retry(cGet, attempt)
+		if !ok {
+			break
+		}
+
+		delays = append(delays, d)
+	}
+
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}, delays)
+
+	_, ok := p.Retry(cPost, 1)
+	assert.False(t, ok)
+
+	p.RetryNonIdempotent = true
+	_, ok = p.Retry(cPost, 1)
+	assert.True(t, ok)
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := &RetryBudget{Ratio: 0.5, MinRetries: 1}
+	p := &RetryPolicy{Budget: b}
+
+	_, ok := p.Retry(cGet, 1)
+	assert.True(t, ok)
+
+	_, ok = p.Retry(cGet, 1)
+	assert.False(t, ok)
+
+	b.Deposit()
+	b.Deposit()
+
+	_, ok = p.Retry(cGet, 1)
+	assert.True(t, ok)
+}
+
+func TestRetryBudgetWindow(t *testing.T) {
+	b := &RetryBudget{Ratio: 0.5, MinRetries: 1, Window: 10}
+
+	for i := 0; i < 10000; i++ {
+		b.Deposit()
+	}
+
+	retries := 0
+	for b.Withdraw() {
+		retries++
+	}
+
+	assert.Equal(t, 6, retries)
+}
+
+func TestTimeoutsHeaderDeadline(t *testing.T) {
+	start := time.Unix(1000, 0)
+	sent := start.Add(time.Second)
+
+	to := Timeouts{Header: 5 * time.Second, Total: 3 * time.Second}
+	assert.Equal(t, start.Add(3*time.Second), to.HeaderDeadline(start, sent))
+
+	to.Total = 0
+	assert.Equal(t, sent.Add(5*time.Second), to.HeaderDeadline(start, sent))
+
+	assert.True(t, Timeouts{}.HeaderDeadline(start, sent).IsZero())
+}
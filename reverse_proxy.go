@@ -12,10 +12,17 @@ type Redirector interface {
 
 type ReverseProxy struct {
 	dir Redirector
+
+	// Don't add an element for the client to the Forwarded header of
+	// upstream requests.
+	OmitForwarded bool
+
+	// Optional shadow upstream to copy some of the requests to.
+	Mirror *Mirror
 }
 
 func NewReverseProxy(dir Redirector) *ReverseProxy {
-	return &ReverseProxy{dir}
+	return &ReverseProxy{dir: dir}
 }
 
 func (r *ReverseProxy) HandleConnection(hp *HTTPParser, rest []byte, c net.Conn) {
@@ -32,13 +39,27 @@ func (r *ReverseProxy) HandleConnection(hp *HTTPParser, rest []byte, c net.Conn)
 		return
 	}
 
-	err = r.writeHeader(hp, out)
+	head := r.appendHeader(nil, hp, c)
+
+	if m := r.Mirror; m != nil && m.sample() && m.acquire() {
+		var body []byte
+		var ok bool
+
+		rest, body, ok = m.readBody(hp, rest, c)
+		if ok {
+			go m.send(head[:len(head):len(head)], body)
+		} else {
+			m.release()
+		}
+	}
+
+	_, err = out.Write(head)
 	if err != nil {
 		r.writeError(c, err)
 		return
 	}
 
-	_, err = c.Write(rest)
+	_, err = out.Write(rest)
 	if err != nil {
 		return
 	}
@@ -58,8 +79,11 @@ func (r *ReverseProxy) writeError(c net.Conn, err error) {
 	c.Write(cError)
 }
 
-func (r *ReverseProxy) writeHeader(hp *HTTPParser, c net.Conn) error {
-	var buf bytes.Buffer
+var cForwarded = []byte("Forwarded")
+
+// Append the head of the request to send upstream for hp, received on in.
+func (r *ReverseProxy) appendHeader(dst []byte, hp *HTTPParser, in net.Conn) []byte {
+	buf := bytes.NewBuffer(dst)
 
 	buf.Write(hp.Method)
 	buf.Write(cSP)
@@ -68,20 +92,37 @@ func (r *ReverseProxy) writeHeader(hp *HTTPParser, c net.Conn) error {
 	buf.Write(cHTTP11)
 	buf.Write(cCRLF)
 
-	for _, h := range hp.Headers {
-		if h.Name == nil {
+	var prior [][]byte
+
+	for i := 0; i < hp.HeaderCount(); i++ {
+		name, value := hp.HeaderAt(i)
+
+		if !r.OmitForwarded && hp.Headers[i].class == hForwarded {
+			prior = append(prior, value)
 			continue
 		}
 
-		buf.Write(h.Name)
+		buf.Write(name)
 		buf.Write(cColon)
-		buf.Write(h.Value)
+		buf.Write(value)
 		buf.Write(cCRLF)
 	}
 
-	buf.Write(cCRLF)
+	if !r.OmitForwarded {
+		// Elements from earlier proxies go first, in one header.
+		buf.Write(cForwarded)
+		buf.Write(cColon)
+
+		for _, value := range prior {
+			buf.Write(value)
+			buf.WriteString(", ")
+		}
 
-	_, err := c.Write(buf.Bytes())
+		buf.Write(forwardedElementFor(hp, in).Append(nil))
+		buf.Write(cCRLF)
+	}
+
+	buf.Write(cCRLF)
 
-	return err
+	return buf.Bytes()
 }
@@ -22,12 +22,10 @@ func AdaptServeHTTP(h http.Handler) Handler {
 func (a *adaptServeHTTP) convertHeader(hp *HTTPParser) http.Header {
 	header := make(http.Header)
 
-	for _, h := range hp.Headers {
-		if h.Name == nil {
-			continue
-		}
+	for i := 0; i < hp.HeaderCount(); i++ {
+		name, value := hp.HeaderAt(i)
 
-		header[string(h.Name)] = append(header[string(h.Name)], string(h.Value))
+		header[string(name)] = append(header[string(name)], string(value))
 	}
 
 	return header
@@ -1,9 +1,15 @@
 package wildcat
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/vektra/errors"
 )
 
 type Handler interface {
@@ -12,10 +18,156 @@ type Handler interface {
 
 type Server struct {
 	Handler Handler
+
+	// Optional TLS configuration used by ServeTLS and ListenAndServeTLS.
+	TLSConfig *tls.Config
+
+	// Handlers for connections that negotiate an ALPN protocol other than
+	// http/1.1, keyed by protocol name (such as "h2"). The handler owns the
+	// connection and should close it when done. Protocols listed here are
+	// advertised during the handshake, before http/1.1.
+	TLSNextProto map[string]func(s *Server, c *tls.Conn)
+
+	// How long a client gets to complete the TLS handshake. 0 means
+	// DefaultTLSHandshakeTimeout.
+	TLSHandshakeTimeout time.Duration
+
+	// Optional per-client rate limiting, applied to each request before it
+	// is passed to the Handler.
+	RateLimiter *RateLimiter
+
+	// Optional set of accepted methods, and limit on a method's length.
+	// Requests using other methods get a 405 or 501 and the connection is
+	// closed. See HTTPParser.SetAllowedMethods.
+	AllowedMethods  []string
+	MaxMethodLength int
+
+	// Optional limit on how long the Handler may take with a request. Once
+	// it passes, HTTPParser.Context is done, body reads fail and, if the
+	// handler hasn't written anything, the client gets TimeoutStatus (503
+	// when 0). The connection is closed after the handler returns.
+	RequestTimeout time.Duration
+	TimeoutStatus  int
+
+	// Give each request an ID (see HTTPParser.AppendRequestID), available
+	// from HTTPParser.RequestID and the request's context, and sent back in
+	// an X-Request-Id header by Response.
+	RequestID bool
+
+	// Optional callback run whenever a connection changes state. See
+	// ConnState.
+	ConnState func(net.Conn, ConnState)
+
+	// Optional limit on open connections. Connections accepted beyond it
+	// get a 503 and are closed. Hijacked connections don't count.
+	MaxConns int
+
+	// Optional limit on requests served over one connection. The last one
+	// is answered with "Connection: close".
+	MaxRequestsPerConn int
+
+	inShutdown int32
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*serverConn]struct{}
+}
+
+// The default TLSHandshakeTimeout of a Server.
+const DefaultTLSHandshakeTimeout = 10 * time.Second
+
+// Returned by Serve after Shutdown has been called.
+var ErrServerClosed = errors.New("server closed")
+
+// serverConn is the net.Conn passed to the Handler. It lets the server track
+// what the connection is doing and lets Response see that the server is
+// shutting down.
+type serverConn struct {
+	net.Conn
+
+	srv   *Server
+	state int32
+
+	// Guard the request timeout: whether the handler has written anything
+	// for the current request, and whether it ran out of time. Also whether
+	// the handler took over the connection, and what it gets with it.
+	mu       sync.Mutex
+	wrote    bool
+	timedOut bool
+	hijacked bool
+	buffered []byte
+
+	// The ID of the current request, for Response to send.
+	requestID []byte
+
+	// Set while serving the connection's last request.
+	last int32
+
+	// Set once a body reader has put a read deadline on the connection.
+	readDeadline int32
+}
+
+func (s *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&s.inShutdown) != 0
+}
+
+// Report whether c belongs to a server that is shutting down, in which case
+// responses should not offer keep-alive.
+func closingConn(c net.Conn) bool {
+	sc, ok := c.(*serverConn)
+	return ok && (sc.srv.shuttingDown() || atomic.LoadInt32(&sc.last) != 0)
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+
+	return true
+}
+
+// Add or remove c from the open connections. Returns false, not adding c,
+// when MaxConns are already open.
+func (s *Server) trackConn(c *serverConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if add {
+		if s.MaxConns > 0 && len(s.conns) >= s.MaxConns {
+			return false
+		}
+
+		if s.conns == nil {
+			s.conns = make(map[*serverConn]struct{})
+		}
+
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+
+	return true
 }
 
 func (s *Server) ListenAndServe(addr string) error {
-	l, err := net.Listen("tcp", ":9594")
+	if addr == "" {
+		addr = ":http"
+	}
+
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
@@ -24,35 +176,177 @@ func (s *Server) ListenAndServe(addr string) error {
 }
 
 func ListenAndServe(addr string, handler Handler) error {
-	s := &Server{handler}
+	s := &Server{Handler: handler}
 
 	return s.ListenAndServe(addr)
 }
 
 func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+
+	defer s.trackListener(l, false)
+
 	for {
 		conn, err := l.Accept()
 		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+
 			return err
 		}
 
-		go s.handle(conn)
+		sc := &serverConn{Conn: conn, srv: s}
+		if !s.trackConn(sc, true) {
+			go rejectConn(conn)
+			continue
+		}
+
+		sc.setState(StateNew)
+
+		go s.handle(sc)
+	}
+}
+
+// How often Shutdown checks whether connections have gone idle.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown gracefully stops the server. It closes the listeners so no new
+// connections are accepted, closes connections that are idle, and waits for
+// in-flight requests to finish. Responses written while shutting down
+// include "Connection: close" and connections are closed once their current
+// request is done.
+//
+// If ctx expires first, the remaining connections are closed and ctx's error
+// is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+
+	s.mu.Lock()
+	for l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.closeIdleConns() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			for c := range s.conns {
+				c.Close()
+			}
+			s.mu.Unlock()
+
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close connections waiting for a new request and report whether there
+// are no connections left.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		if c.closeIfIdle() {
+			delete(s.conns, c)
+		}
 	}
+
+	return len(s.conns) == 0
 }
 
-func (s *Server) handle(c net.Conn) {
+func (s *Server) handle(c *serverConn) {
+	defer func() {
+		if !c.isHijacked() {
+			s.trackConn(c, false)
+			c.Close()
+			c.setState(StateClosed)
+		}
+	}()
+
+	if tc, ok := c.Conn.(*tls.Conn); ok {
+		timeout := s.TLSHandshakeTimeout
+		if timeout <= 0 {
+			timeout = DefaultTLSHandshakeTimeout
+		}
+
+		// Don't let a client that stalls the handshake hold on to the
+		// connection.
+		tc.SetDeadline(time.Now().Add(timeout))
+
+		if err := tc.Handshake(); err != nil {
+			return
+		}
+
+		tc.SetDeadline(time.Time{})
+
+		proto := tc.ConnectionState().NegotiatedProtocol
+		if fn, ok := s.TLSNextProto[proto]; ok {
+			c.setState(StateActive)
+			fn(s, tc)
+			return
+		}
+	}
+
 	buf := make([]byte, OptimalBufferSize)
 
 	hp := NewHTTPParser()
 
+	var allowed [][]byte
+	for _, m := range s.AllowedMethods {
+		allowed = append(allowed, []byte(m))
+	}
+
+	hp.SetAllowedMethods(allowed...)
+	hp.SetMaxMethodLength(s.MaxMethodLength)
+
+	// Bytes of the next request already in buf, read along with the
+	// previous one.
+	var n int
+
+	var idBuf []byte
+
+	requests := 0
+
 	for {
-		n, err := c.Read(buf)
-		if err != nil {
+		if !c.setState(StateIdle) || s.shuttingDown() {
+			return
+		}
+
+		if n == 0 {
+			m, err := c.Read(buf)
+			if err != nil {
+				return
+			}
+
+			n = m
+		}
+
+		// Shutdown may have closed the connection while it was idle.
+		if !c.setState(StateActive) {
 			return
 		}
 
 		res, err := hp.Parse(buf[:n])
 		for err == ErrMissingData {
+			if n == len(buf) {
+				err = hp.overflowError()
+				break
+			}
+
 			var m int
 
 			m, err = c.Read(buf[n:])
@@ -65,20 +359,86 @@ func (s *Server) handle(c net.Conn) {
 			res, err = hp.Parse(buf[:n])
 		}
 
+		if me, ok := err.(*MethodError); ok {
+			c.Write(appendMethodErrorResponse(nil, me, allowed))
+			return
+		}
+
+		if err != nil {
+			c.Write(ErrorResponse(err))
+			return
+		}
+
+		if rl := s.RateLimiter; rl != nil {
+			ok, retryAfter := rl.Allow(hp.ClientIP(c.RemoteAddr(), rl.TrustedProxies))
+			if !ok {
+				c.Write(appendTooManyRequests(nil, retryAfter))
+				return
+			}
+		}
+
+		rest := buf[res:n]
+
+		requests++
+		if s.MaxRequestsPerConn > 0 && requests >= s.MaxRequestsPerConn {
+			atomic.StoreInt32(&c.last, 1)
+		}
+
+		if s.RequestID {
+			idBuf = c.assignRequestID(hp, idBuf)
+		}
+
+		var finish func() bool
+		if s.RequestTimeout > 0 {
+			finish = s.startRequestTimeout(c, hp)
+		}
+
+		c.mu.Lock()
+		c.buffered = rest
+		c.mu.Unlock()
+
+		s.Handler.HandleConnection(hp, rest, c)
+
+		if finish != nil && !finish() {
+			return
+		}
+
+		hp.ctx, hp.requestID, c.requestID = nil, nil, nil
+
+		if c.isHijacked() {
+			return
+		}
+
+		// Don't let a deadline meant for the body cut off the next request.
+		if atomic.SwapInt32(&c.readDeadline, 0) != 0 {
+			c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		if s.shuttingDown() || atomic.LoadInt32(&c.last) != 0 {
+			return
+		}
+
+		next, ok := hp.bodyLeftover(rest)
+		if !ok {
+			return
+		}
+
+		next, err = skipToRequestLine(next)
 		if err != nil {
-			panic(err)
+			return
 		}
 
-		s.Handler.HandleConnection(hp, buf[res:n], c)
+		n = copy(buf, next)
 	}
 }
 
-// ListenAndServeTLS listens on the TCP network address srv.Addr and
-// then calls Serve to handle requests on incoming TLS connections.
+// ListenAndServeTLS listens on the TCP network address addr and
+// then calls ServeTLS to handle requests on incoming TLS connections.
 //
 // Filenames containing a certificate and matching private key for
-// the server must be provided. If the certificate is signed by a
-// certificate authority, the certFile should be the concatenation
+// the server must be provided if neither the Server's TLSConfig.Certificates
+// nor TLSConfig.GetCertificate are populated. If the certificate is signed
+// by a certificate authority, the certFile should be the concatenation
 // of the server's certificate followed by the CA's certificate.
 //
 // If addr is blank, ":https" is used.
@@ -87,23 +447,47 @@ func (srv *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
 		addr = ":https"
 	}
 
-	config := &tls.Config{}
-	config.NextProtos = []string{"http/1.1"}
-
-	var err error
-	config.Certificates = make([]tls.Certificate, 1)
-	config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
+	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		return err
+	return srv.ServeTLS(tcpKeepAliveListener{ln.(*net.TCPListener)}, certFile, keyFile)
+}
+
+// ServeTLS accepts connections on l, performs the TLS handshake and serves
+// them. Connections negotiating a protocol in TLSNextProto are handed to
+// that handler, everything else is served as HTTP/1.1.
+//
+// certFile and keyFile are loaded as in ListenAndServeTLS; they may be
+// blank if TLSConfig already provides certificates.
+func (srv *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	var config *tls.Config
+	if srv.TLSConfig != nil {
+		config = srv.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if len(config.NextProtos) == 0 {
+		for proto := range srv.TLSNextProto {
+			config.NextProtos = append(config.NextProtos, proto)
+		}
+		sort.Strings(config.NextProtos)
+		config.NextProtos = append(config.NextProtos, "http/1.1")
+	}
+
+	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil
+	if !configHasCert || certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
 	}
 
-	tlsListener := tls.NewListener(tcpKeepAliveListener{ln.(*net.TCPListener)}, config)
-	return srv.Serve(tlsListener)
+	return srv.Serve(tls.NewListener(l, config))
 }
 
 func ListenAndServeTLS(addr string, certFile string, keyFile string, handler Handler) error {
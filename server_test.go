@@ -0,0 +1,330 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handlerFunc func(hp *HTTPParser, rest []byte, c net.Conn)
+
+func (f handlerFunc) HandleConnection(hp *HTTPParser, rest []byte, c net.Conn) {
+	f(hp, rest, c)
+}
+
+func helloHandler(hp *HTTPParser, rest []byte, c net.Conn) {
+	resp := NewResponse(c)
+	resp.WriteStatus(200)
+	resp.WriteHeaders()
+	resp.WriteBodyString("hello")
+}
+
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+
+	server, err := l.Accept()
+	require.NoError(t, err)
+
+	return client, server
+}
+
+func startServer(t *testing.T, s *Server) (string, chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(l)
+	}()
+
+	return l.Addr().String(), done
+}
+
+// Read a response with a Content-Length body and return the header block.
+func readResponse(t *testing.T, r *bufio.Reader) string {
+	var head strings.Builder
+
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		if line == "\r\n" {
+			break
+		}
+
+		head.WriteString(line)
+	}
+
+	return head.String()
+}
+
+func TestServerShutdownFinishesInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := &Server{Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+		close(started)
+		<-release
+		helloHandler(hp, rest, c)
+	})}
+
+	addr, done := startServer(t, s)
+
+	active, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer active.Close()
+
+	idle, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer idle.Close()
+
+	_, err = active.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.Shutdown(context.Background())
+	}()
+
+	assert.Equal(t, ErrServerClosed, <-done)
+
+	// The idle connection is closed without a response.
+	idle.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = idle.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	close(release)
+
+	head := readResponse(t, bufio.NewReader(active))
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, head, "Connection: close\r\n")
+
+	assert.NoError(t, <-shutdown)
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+
+	s := &Server{Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+		close(started)
+		c.Read(make([]byte, 1))
+	})}
+
+	addr, _ := startServer(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServerServeTLS(t *testing.T) {
+	s := &Server{
+		Handler:   handlerFunc(helloHandler),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}},
+		TLSNextProto: map[string]func(*Server, *tls.Conn){
+			"h2": func(s *Server, c *tls.Conn) {
+				c.Write([]byte("h2 path"))
+				c.Close()
+			},
+		},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(c)
+	require.NoError(t, err)
+	assert.Equal(t, "h2 path", string(out))
+	c.Close()
+
+	c, err = tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"http/1.1"}})
+	require.NoError(t, err)
+	defer c.Close()
+
+	assert.Equal(t, "http/1.1", c.ConnectionState().NegotiatedProtocol)
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	head := readResponse(t, bufio.NewReader(c))
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+}
+
+func TestServerTLSHandshakeTimeout(t *testing.T) {
+	s := &Server{
+		Handler:             handlerFunc(helloHandler),
+		TLSConfig:           &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}},
+		TLSHandshakeTimeout: 50 * time.Millisecond,
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	// Connect but never start the handshake.
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, err = c.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wildcat.sock")
+
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := ListenUnix(path, 0600)
+	require.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	s := &Server{Handler: handlerFunc(helloHandler)}
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	require.NoError(t, err)
+
+	head := readResponse(t, bufio.NewReader(c))
+	assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+}
+
+func TestListenUnixRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+
+	_, err := ListenUnix(path, 0600)
+	assert.Error(t, err)
+}
+
+func TestServerPipelinedBody(t *testing.T) {
+	s := &Server{Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+		body, err := io.ReadAll(hp.BodyReader(rest, c))
+		require.NoError(t, err)
+
+		resp := NewResponse(c)
+		resp.WriteStatus(200)
+		resp.WriteHeaders()
+		resp.WriteBodyBytes(body)
+	})}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("POST / HTTP/1.1\r\nContent-Length: 3\r\n\r\nabcPOST / HTTP/1.1\r\nContent-Length: 3\r\n\r\ndef"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(c)
+
+	for _, want := range []string{"abc", "def"} {
+		readResponse(t, r)
+
+		body := make([]byte, 3)
+		_, err = io.ReadFull(r, body)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(body))
+	}
+}
+
+func TestServerClosesOnBodyLengthMismatch(t *testing.T) {
+	var calls int32
+
+	s := &Server{Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+		atomic.AddInt32(&calls, 1)
+		io.ReadAll(hp.BodyReader(rest, c))
+		helloHandler(hp, rest, c)
+	})}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("POST / HTTP/1.1\r\nContent-Length: 3\r\n\r\nabcdef\r\nGET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	c.SetReadDeadline(time.Now().Add(time.Second))
+
+	data, err := io.ReadAll(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, strings.Count(string(data), "HTTP/1.1 200 OK"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
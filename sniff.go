@@ -0,0 +1,340 @@
+package wildcat
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// What follows is a port of net/http's DetectContentType, which implements
+// https://mimesniff.spec.whatwg.org/, returning shared byte slices rather
+// than strings.
+//
+// Copyright 2011 The Go Authors. All rights reserved. Use of this source
+// code is governed by a BSD-style license that can be found in Go's LICENSE
+// file.
+
+// The most bytes DetectContentType looks at.
+const SniffLen = 512
+
+var (
+	ctTextXmlUTF8                = []byte("text/xml; charset=utf-8")
+	ctApplicationPdf             = []byte("application/pdf")
+	ctApplicationPostscript      = []byte("application/postscript")
+	ctTextPlainUTF16BE           = []byte("text/plain; charset=utf-16be")
+	ctTextPlainUTF16LE           = []byte("text/plain; charset=utf-16le")
+	ctTextPlainUTF8              = []byte("text/plain; charset=utf-8")
+	ctImageXIcon                 = []byte("image/x-icon")
+	ctImageBmp                   = []byte("image/bmp")
+	ctImageGif                   = []byte("image/gif")
+	ctImageWebp                  = []byte("image/webp")
+	ctImagePng                   = []byte("image/png")
+	ctImageJpeg                  = []byte("image/jpeg")
+	ctAudioAiff                  = []byte("audio/aiff")
+	ctAudioMpeg                  = []byte("audio/mpeg")
+	ctApplicationOgg             = []byte("application/ogg")
+	ctAudioMidi                  = []byte("audio/midi")
+	ctVideoAvi                   = []byte("video/avi")
+	ctAudioWave                  = []byte("audio/wave")
+	ctVideoWebm                  = []byte("video/webm")
+	ctApplicationVndMsFontobject = []byte("application/vnd.ms-fontobject")
+	ctFontTtf                    = []byte("font/ttf")
+	ctFontOtf                    = []byte("font/otf")
+	ctFontCollection             = []byte("font/collection")
+	ctFontWoff                   = []byte("font/woff")
+	ctFontWoff2                  = []byte("font/woff2")
+	ctApplicationXGzip           = []byte("application/x-gzip")
+	ctApplicationZip             = []byte("application/zip")
+	ctApplicationXRarCompressed  = []byte("application/x-rar-compressed")
+	ctApplicationWasm            = []byte("application/wasm")
+	ctTextHtmlUTF8               = []byte("text/html; charset=utf-8")
+	ctVideoMp4                   = []byte("video/mp4")
+)
+
+// Return the MIME type of data, looking at up to SniffLen bytes, by the
+// algorithm http.DetectContentType uses. It never returns nil: if nothing
+// more specific matches, the result is "application/octet-stream". The
+// result is shared and must not be modified.
+func DetectContentType(data []byte) []byte {
+	if len(data) > SniffLen {
+		data = data[:SniffLen]
+	}
+
+	// Index of the first non-whitespace byte in data.
+	firstNonWS := 0
+	for ; firstNonWS < len(data) && isSniffWS(data[firstNonWS]); firstNonWS++ {
+	}
+
+	for _, sig := range sniffSignatures {
+		if ct := sig.match(data, firstNonWS); ct != nil {
+			return ct
+		}
+	}
+
+	return cOctetStream
+}
+
+// Whitespace as defined in https://mimesniff.spec.whatwg.org/#terminology.
+func isSniffWS(b byte) bool {
+	switch b {
+	case '\t', '\n', '\x0c', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+// Tag-terminating bytes, as defined in the same section.
+func isSniffTT(b byte) bool {
+	switch b {
+	case ' ', '>':
+		return true
+	}
+	return false
+}
+
+type sniffSig interface {
+	// Return the MIME type of the data, or nil if unknown.
+	match(data []byte, firstNonWS int) []byte
+}
+
+// Data matching the table in section 6.
+var sniffSignatures = []sniffSig{
+	htmlSig("<!DOCTYPE HTML"),
+	htmlSig("<HTML"),
+	htmlSig("<HEAD"),
+	htmlSig("<SCRIPT"),
+	htmlSig("<IFRAME"),
+	htmlSig("<H1"),
+	htmlSig("<DIV"),
+	htmlSig("<FONT"),
+	htmlSig("<TABLE"),
+	htmlSig("<A"),
+	htmlSig("<STYLE"),
+	htmlSig("<TITLE"),
+	htmlSig("<B"),
+	htmlSig("<BODY"),
+	htmlSig("<BR"),
+	htmlSig("<P"),
+	htmlSig("<!--"),
+	&maskedSig{
+		mask:   []byte("\xFF\xFF\xFF\xFF\xFF"),
+		pat:    []byte("<?xml"),
+		skipWS: true,
+		ct:     ctTextXmlUTF8},
+	&exactSig{[]byte("%PDF-"), ctApplicationPdf},
+	&exactSig{[]byte("%!PS-Adobe-"), ctApplicationPostscript},
+
+	// UTF BOMs.
+	&maskedSig{
+		mask: []byte("\xFF\xFF\x00\x00"),
+		pat:  []byte("\xFE\xFF\x00\x00"),
+		ct:   ctTextPlainUTF16BE,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\x00\x00"),
+		pat:  []byte("\xFF\xFE\x00\x00"),
+		ct:   ctTextPlainUTF16LE,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\x00"),
+		pat:  []byte("\xEF\xBB\xBF\x00"),
+		ct:   ctTextPlainUTF8,
+	},
+
+	// Image types
+	// For posterity, we originally returned ctImageVndMicrosoftIcon from
+	// https://tools.ietf.org/html/draft-ietf-websec-mime-sniff-03#section-7
+	// https://codereview.appspot.com/4746042
+	// but that has since been replaced with ctImageXIcon in Section 6.2
+	// of https://mimesniff.spec.whatwg.org/#matching-an-image-type-pattern
+	&exactSig{[]byte("\x00\x00\x01\x00"), ctImageXIcon},
+	&exactSig{[]byte("\x00\x00\x02\x00"), ctImageXIcon},
+	&exactSig{[]byte("BM"), ctImageBmp},
+	&exactSig{[]byte("GIF87a"), ctImageGif},
+	&exactSig{[]byte("GIF89a"), ctImageGif},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF\xFF\xFF"),
+		pat:  []byte("RIFF\x00\x00\x00\x00WEBPVP"),
+		ct:   ctImageWebp,
+	},
+	&exactSig{[]byte("\x89PNG\x0D\x0A\x1A\x0A"), ctImagePng},
+	&exactSig{[]byte("\xFF\xD8\xFF"), ctImageJpeg},
+
+	// Audio and Video types
+	// Enforce the pattern match ordering as prescribed in
+	// https://mimesniff.spec.whatwg.org/#matching-an-audio-or-video-type-pattern
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		pat:  []byte("FORM\x00\x00\x00\x00AIFF"),
+		ct:   ctAudioAiff,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF"),
+		pat:  []byte("ID3"),
+		ct:   ctAudioMpeg,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\xFF\xFF"),
+		pat:  []byte("OggS\x00"),
+		ct:   ctApplicationOgg,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\xFF\xFF\xFF\xFF\xFF"),
+		pat:  []byte("MThd\x00\x00\x00\x06"),
+		ct:   ctAudioMidi,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		pat:  []byte("RIFF\x00\x00\x00\x00AVI "),
+		ct:   ctVideoAvi,
+	},
+	&maskedSig{
+		mask: []byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		pat:  []byte("RIFF\x00\x00\x00\x00WAVE"),
+		ct:   ctAudioWave,
+	},
+	// 6.2.0.2. video/mp4
+	mp4Sig{},
+	// 6.2.0.3. video/webm
+	&exactSig{[]byte("\x1A\x45\xDF\xA3"), ctVideoWebm},
+
+	// Font types
+	&maskedSig{
+		// 34 NULL bytes followed by the string "LP"
+		pat: []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00LP"),
+		// 34 NULL bytes followed by \xF\xF
+		mask: []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xFF\xFF"),
+		ct:   ctApplicationVndMsFontobject,
+	},
+	&exactSig{[]byte("\x00\x01\x00\x00"), ctFontTtf},
+	&exactSig{[]byte("OTTO"), ctFontOtf},
+	&exactSig{[]byte("ttcf"), ctFontCollection},
+	&exactSig{[]byte("wOFF"), ctFontWoff},
+	&exactSig{[]byte("wOF2"), ctFontWoff2},
+
+	// Archive types
+	&exactSig{[]byte("\x1F\x8B\x08"), ctApplicationXGzip},
+	&exactSig{[]byte("PK\x03\x04"), ctApplicationZip},
+	// RAR's signatures are incorrectly defined by the MIME spec as per
+	//    https://github.com/whatwg/mimesniff/issues/63
+	// However, RAR Labs correctly defines it at:
+	//    https://www.rarlab.com/technote.htm#rarsign
+	// so we use the definition from RAR Labs.
+	// TODO: do whatever the spec ends up doing.
+	&exactSig{[]byte("Rar!\x1A\x07\x00"), ctApplicationXRarCompressed},     // RAR v1.5-v4.0
+	&exactSig{[]byte("Rar!\x1A\x07\x01\x00"), ctApplicationXRarCompressed}, // RAR v5+
+
+	&exactSig{[]byte("\x00\x61\x73\x6D"), ctApplicationWasm},
+
+	textSig{}, // should be last
+}
+
+type exactSig struct {
+	sig []byte
+	ct  []byte
+}
+
+func (e *exactSig) match(data []byte, firstNonWS int) []byte {
+	if bytes.HasPrefix(data, e.sig) {
+		return e.ct
+	}
+	return nil
+}
+
+type maskedSig struct {
+	mask, pat []byte
+	skipWS    bool
+	ct        []byte
+}
+
+func (m *maskedSig) match(data []byte, firstNonWS int) []byte {
+	// pattern matching algorithm section 6
+	// https://mimesniff.spec.whatwg.org/#pattern-matching-algorithm
+
+	if m.skipWS {
+		data = data[firstNonWS:]
+	}
+	if len(m.pat) != len(m.mask) {
+		return nil
+	}
+	if len(data) < len(m.pat) {
+		return nil
+	}
+	for i, pb := range m.pat {
+		maskedData := data[i] & m.mask[i]
+		if maskedData != pb {
+			return nil
+		}
+	}
+	return m.ct
+}
+
+type htmlSig []byte
+
+func (h htmlSig) match(data []byte, firstNonWS int) []byte {
+	data = data[firstNonWS:]
+	if len(data) < len(h)+1 {
+		return nil
+	}
+	for i, b := range h {
+		db := data[i]
+		if 'A' <= b && b <= 'Z' {
+			db &= 0xDF
+		}
+		if b != db {
+			return nil
+		}
+	}
+	// Next byte must be a tag-terminating byte(0xTT).
+	if !isSniffTT(data[len(h)]) {
+		return nil
+	}
+	return ctTextHtmlUTF8
+}
+
+var (
+	mp4ftype = []byte("ftyp")
+	mp4      = []byte("mp4")
+)
+
+type mp4Sig struct{}
+
+func (mp4Sig) match(data []byte, firstNonWS int) []byte {
+	// https://mimesniff.spec.whatwg.org/#signature-for-mp4
+	// c.f. section 6.2.1
+	if len(data) < 12 {
+		return nil
+	}
+	boxSize := int(binary.BigEndian.Uint32(data[:4]))
+	if len(data) < boxSize || boxSize%4 != 0 {
+		return nil
+	}
+	if !bytes.Equal(data[4:8], mp4ftype) {
+		return nil
+	}
+	for st := 8; st < boxSize; st += 4 {
+		if st == 12 {
+			// Ignores the four bytes that correspond to the version number of the "major brand".
+			continue
+		}
+		if bytes.Equal(data[st:st+3], mp4) {
+			return ctVideoMp4
+		}
+	}
+	return nil
+}
+
+type textSig struct{}
+
+func (textSig) match(data []byte, firstNonWS int) []byte {
+	// c.f. section 5, step 4.
+	for _, b := range data[firstNonWS:] {
+		switch {
+		case b <= 0x08,
+			b == 0x0B,
+			0x0E <= b && b <= 0x1A,
+			0x1C <= b && b <= 0x1F:
+			return nil
+		}
+	}
+	return ctTextPlainUTF8
+}
@@ -0,0 +1,46 @@
+package wildcat
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sniffTests = []string{
+	"",
+	"\x00\x00\x00\x00",
+	"  <!DOCTYPE html><html>",
+	"<HtMl><bOdY>blah blah blah</body></html>",
+	"<?xml version='1.0'?><x/>",
+	"%PDF-1.4",
+	"\xEF\xBB\xBFhello",
+	"GIF89a...",
+	"\x89PNG\x0D\x0A\x1A\x0A",
+	"\xFF\xD8\xFF\xE0",
+	"RIFF\x00\x00\x00\x00WEBPVP8 ",
+	"\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom<\x06t\xbfmdat",
+	"\x1F\x8B\x08\x00",
+	"PK\x03\x04",
+	"wOF2",
+	"\x00\x61\x73\x6D\x01",
+	"plain text, nothing more",
+	"{\"json\": true}",
+	"\x01\x02 binary",
+}
+
+func TestDetectContentType(t *testing.T) {
+	for _, data := range sniffTests {
+		assert.Equal(t, http.DetectContentType([]byte(data)), string(DetectContentType([]byte(data))), "%q", data)
+	}
+}
+
+func TestDetectContentTypeAllocs(t *testing.T) {
+	data := []byte("<html><body>hello</body></html>")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		DetectContentType(data)
+	})
+
+	assert.Equal(t, float64(0), allocs)
+}
@@ -0,0 +1,77 @@
+package wildcat
+
+import (
+	"bytes"
+
+	"github.com/vektra/errors"
+)
+
+// Returned by Parse and Resume when they stop after a header given to
+// StopAtHeader. The headers seen so far, including that one, can be looked
+// up; call Resume to parse the rest.
+var ErrStoppedAtHeader = errors.New("stopped at header")
+
+// Where parsing stopped, for Resume.
+type parseResume struct {
+	at, h int
+	kept  bool
+	slot  int
+	state int
+	stop  bool
+}
+
+// Have Parse stop once the header line for name has been read, so that
+// for example a load balancer can pick a backend from Host without waiting
+// for the whole header block. The header must be stored, so it's
+// subscribed to as well.
+func (hp *HTTPParser) StopAtHeader(name []byte) {
+	hp.stopHeaders = append(hp.stopHeaders, header{Name: name, class: lookupHeaderClass(name)})
+
+	if !hp.subscribeAllHeader {
+		hp.SubscribeHeader(name)
+	}
+}
+
+func (hp *HTTPParser) stopAt(class headerClass, name []byte) bool {
+	for _, sh := range hp.stopHeaders {
+		if class != hUnknown {
+			if sh.class == class {
+				return true
+			}
+		} else if sh.class == hUnknown && bytes.EqualFold(sh.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (hp *HTTPParser) stopAfter(r parseResume) (int, error) {
+	hp.numHeaders = r.h
+	hp.resume = r
+	hp.resuming = true
+
+	return r.at, ErrStoppedAtHeader
+}
+
+// Continue parsing after Parse or Resume returned ErrStoppedAtHeader, or
+// returned ErrMissingData partway through the headers. input must start
+// with the same bytes as before, and may have more read onto the end.
+// Returns what Parse would have, without scanning the earlier input again.
+// Otherwise this is the same as Parse.
+func (hp *HTTPParser) Resume(input []byte) (int, error) {
+	if !hp.resuming {
+		return hp.Parse(input)
+	}
+
+	n, err := hp.parseHeaders(input, hp.resume)
+	if err != ErrMissingData && err != ErrStoppedAtHeader {
+		hp.resuming = false
+
+		if err != nil && hp.parseErrorHook != nil {
+			hp.reportParseError(input, err)
+		}
+	}
+
+	return n, err
+}
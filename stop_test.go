@@ -0,0 +1,73 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserStopAtHeader(t *testing.T) {
+	req := []byte("GET / HTTP/1.1\r\nHost: backend.example\r\nAccept: */*\r\nX-Big: 1\r\n\r\nbody")
+
+	hp := NewHTTPParser()
+	hp.StopAtHeader([]byte("Host"))
+
+	// Only the first header line has arrived.
+	n, err := hp.Parse(req[:39])
+	require.Equal(t, ErrStoppedAtHeader, err)
+	assert.Equal(t, 39, n)
+	assert.Equal(t, "backend.example", string(hp.Host()))
+	assert.Equal(t, 1, hp.HeaderCount())
+
+	_, err = hp.Resume(req[:50])
+	require.Equal(t, ErrMissingData, err)
+
+	n, err = hp.Resume(req)
+	require.NoError(t, err)
+	assert.Equal(t, len(req)-4, n)
+	assert.Equal(t, 3, hp.HeaderCount())
+	assert.Equal(t, "*/*", string(hp.FindHeader([]byte("Accept"))))
+	assert.Equal(t, "backend.example", string(hp.Host()))
+
+	// Without a stop, Resume is just Parse.
+	n, err = hp.Resume([]byte("GET / HTTP/1.1\r\nAccept: */*\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, hp.HeaderCount())
+}
+
+func TestParserStopAtHeaderCRLFSplit(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.StopAtHeader([]byte("x-tenant"))
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\nX-Tenant: a\r"))
+	assert.Equal(t, ErrMissingData, err)
+
+	req := []byte("GET / HTTP/1.1\r\nX-Tenant: a\r\n b\r\n\r\n")
+
+	n, err := hp.Parse(req)
+	require.Equal(t, ErrStoppedAtHeader, err)
+	assert.Equal(t, 29, n)
+	assert.Equal(t, "a", string(hp.FindHeader([]byte("X-Tenant"))))
+
+	// The folded line is added to the header on resume.
+	_, err = hp.Resume(req)
+	require.NoError(t, err)
+	assert.Equal(t, "a b", string(hp.FindHeader([]byte("X-Tenant"))))
+}
+
+func TestParserStopAtHeaderUnsubscribed(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SubscribeAllHeader(false)
+	hp.StopAtHeader([]byte("X-Tenant"))
+
+	req := []byte("GET / HTTP/1.1\r\nAccept: */*\r\nX-Tenant: a\r\n\r\n")
+
+	_, err := hp.Parse(req)
+	require.Equal(t, ErrStoppedAtHeader, err)
+	assert.Equal(t, "a", string(hp.FindHeader([]byte("X-Tenant"))))
+
+	_, err = hp.Resume(req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, hp.HeaderCount())
+}
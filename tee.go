@@ -0,0 +1,73 @@
+package wildcat
+
+import "io"
+
+// TeeBodyReader passes a body through while keeping a copy of its first
+// bytes, so they can be inspected (by a WAF, a sniffer or a signature
+// check) without reading the body twice.
+type TeeBodyReader struct {
+	r io.ReadCloser
+
+	// The bytes kept so far, up to cap(buf), and how many of them have
+	// been returned by Read.
+	buf []byte
+	off int
+
+	err error
+}
+
+// Wrap r, keeping up to len(buf) of the first bytes read in buf.
+func NewTeeBodyReader(r io.ReadCloser, buf []byte) *TeeBodyReader {
+	return &TeeBodyReader{r: r, buf: buf[:0:len(buf)]}
+}
+
+func (t *TeeBodyReader) Read(p []byte) (int, error) {
+	if t.off < len(t.buf) {
+		n := copy(p, t.buf[t.off:])
+		t.off += n
+		return n, nil
+	}
+
+	if t.err != nil {
+		return 0, t.err
+	}
+
+	n, err := t.r.Read(p)
+
+	if room := cap(t.buf) - len(t.buf); room > 0 && n > 0 {
+		if room > n {
+			room = n
+		}
+
+		t.buf = append(t.buf, p[:room]...)
+		t.off = len(t.buf)
+	}
+
+	return n, err
+}
+
+// Read ahead until the buffer is full or the body ends, and return the
+// bytes kept. They're still returned by later Reads, so this lets the
+// start of the body be inspected before the handler sees any of it.
+func (t *TeeBodyReader) Fill() ([]byte, error) {
+	for len(t.buf) < cap(t.buf) && t.err == nil {
+		n, err := t.r.Read(t.buf[len(t.buf):cap(t.buf)])
+		t.buf = t.buf[:len(t.buf)+n]
+		t.err = err
+	}
+
+	if t.err == io.EOF {
+		return t.buf, nil
+	}
+
+	return t.buf, t.err
+}
+
+// Return the bytes kept so far.
+func (t *TeeBodyReader) Captured() []byte {
+	return t.buf
+}
+
+func (t *TeeBodyReader) Close() error {
+	return t.r.Close()
+}
@@ -0,0 +1,50 @@
+package wildcat
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeeBodyReader(t *testing.T) {
+	body := io.NopCloser(iotest.OneByteReader(strings.NewReader("hello world")))
+
+	tr := NewTeeBodyReader(body, make([]byte, 5))
+
+	out, err := io.ReadAll(tr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello world", string(out))
+	assert.Equal(t, "hello", string(tr.Captured()))
+}
+
+func TestTeeBodyReaderFill(t *testing.T) {
+	hp := parseRequest(t, "POST / HTTP/1.1\r\nContent-Length: 11\r\n\r\n")
+
+	body := hp.BodyReader([]byte("hel"), io.NopCloser(strings.NewReader("lo world")))
+	tr := NewTeeBodyReader(body, make([]byte, 8))
+
+	head, err := tr.Fill()
+	require.NoError(t, err)
+	assert.Equal(t, "hello wo", string(head))
+
+	out, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(out))
+}
+
+func TestTeeBodyReaderFillShortBody(t *testing.T) {
+	tr := NewTeeBodyReader(io.NopCloser(strings.NewReader("hi")), make([]byte, 8))
+
+	head, err := tr.Fill()
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(head))
+
+	out, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(out))
+}
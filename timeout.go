@@ -0,0 +1,145 @@
+package wildcat
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/vektra/errors"
+)
+
+// Returned by writes to the connection after the request timeout passed.
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// Return the context of the request being handled. When the server has a
+// RequestTimeout it's done once the timeout passes; otherwise it's
+// context.Background.
+func (hp *HTTPParser) Context() context.Context {
+	if hp.ctx != nil {
+		return hp.ctx
+	}
+
+	return context.Background()
+}
+
+// Start timing the request hp was parsed from. The returned function must be
+// called when the handler returns, and reports whether it finished in time.
+func (s *Server) startRequestTimeout(c *serverConn, hp *HTTPParser) func() bool {
+	ctx, cancel := context.WithCancel(hp.Context())
+	hp.ctx = &timeoutContext{Context: ctx, deadline: time.Now().Add(s.RequestTimeout)}
+
+	c.mu.Lock()
+	c.wrote, c.timedOut = false, false
+	c.mu.Unlock()
+
+	status := s.TimeoutStatus
+	if status == 0 {
+		status = StatusServiceUnavailable
+	}
+
+	expired := make(chan struct{})
+
+	// The context is only done once the request has expired, so a handler
+	// woken by it can't write before the timeout response goes out.
+	t := time.AfterFunc(s.RequestTimeout, func() {
+		c.expire(status)
+		cancel()
+		close(expired)
+	})
+
+	return func() bool {
+		// If the timer already fired, expire may still be on its way. Wait
+		// for it, so that it can't hit the connection's next request.
+		if !t.Stop() {
+			<-expired
+			return false
+		}
+
+		cancel()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return !c.timedOut
+	}
+}
+
+// A request's context: done when the request expires, with the timeout as
+// its deadline.
+type timeoutContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (tc *timeoutContext) Deadline() (time.Time, bool) {
+	return tc.deadline, true
+}
+
+func (tc *timeoutContext) Err() error {
+	err := tc.Context.Err()
+	if err == context.Canceled && !time.Now().Before(tc.deadline) {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
+
+// Fail the current request: answer with status unless the handler has
+// written something, make further writes fail and wake up blocked reads.
+func (c *serverConn) expire(status int) {
+	c.mu.Lock()
+
+	if c.hijacked {
+		c.mu.Unlock()
+		return
+	}
+
+	c.timedOut = true
+
+	if !c.wrote {
+		c.Conn.Write(appendErrorResponse(nil, status))
+	}
+
+	c.mu.Unlock()
+
+	c.Conn.SetReadDeadline(time.Now())
+}
+
+// Note that the handler is writing, or fail if the request timed out.
+func (c *serverConn) startWrite() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.hijacked {
+		return ErrHijacked
+	}
+
+	if c.timedOut {
+		return ErrHandlerTimeout
+	}
+
+	c.wrote = true
+	return nil
+}
+
+func (c *serverConn) Write(b []byte) (int, error) {
+	if err := c.startWrite(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(b)
+}
+
+// Pass ReadFrom through so that copying a file to the connection can still
+// use sendfile.
+func (c *serverConn) ReadFrom(r io.Reader) (int64, error) {
+	if err := c.startWrite(); err != nil {
+		return 0, err
+	}
+
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+
+	return io.Copy(struct{ io.Writer }{c.Conn}, r)
+}
@@ -0,0 +1,160 @@
+package wildcat
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerRequestTimeout(t *testing.T) {
+	done := make(chan error, 1)
+
+	s := &Server{
+		RequestTimeout: 50 * time.Millisecond,
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			<-hp.Context().Done()
+
+			_, err := c.Write([]byte("late"))
+			done <- err
+		}),
+	}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(c)
+
+	head := readResponse(t, r)
+	assert.Contains(t, head, "HTTP/1.1 503 Service Unavailable\r\n")
+
+	assert.Equal(t, ErrHandlerTimeout, <-done)
+
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestServerRequestTimeoutStatus(t *testing.T) {
+	s := &Server{
+		RequestTimeout: 50 * time.Millisecond,
+		TimeoutStatus:  StatusGatewayTimeout,
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			body := hp.BodyReader(rest, c)
+			_, err := io.ReadAll(body)
+			assert.Error(t, err)
+		}),
+	}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// The body never arrives, so the handler is stuck reading it.
+	_, err = c.Write([]byte("POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nabc"))
+	require.NoError(t, err)
+
+	head := readResponse(t, bufio.NewReader(c))
+	assert.Contains(t, head, "HTTP/1.1 504 Gateway Timeout\r\n")
+}
+
+func TestServerRequestTimeoutAfterHeaders(t *testing.T) {
+	s := &Server{
+		RequestTimeout: 50 * time.Millisecond,
+		Handler: handlerFunc(func(hp *HTTPParser, rest []byte, c net.Conn) {
+			resp := NewResponse(c)
+			resp.WriteStatus(200)
+			resp.WriteHeaders()
+
+			<-hp.Context().Done()
+		}),
+	}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(c)
+
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", line)
+
+	rest, _ := io.ReadAll(r)
+	assert.False(t, strings.Contains(string(rest), "503"))
+}
+
+func TestServerRequestTimeoutInTime(t *testing.T) {
+	s := &Server{RequestTimeout: time.Second, Handler: handlerFunc(helloHandler)}
+
+	addr, _ := startServer(t, s)
+	defer s.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		require.NoError(t, err)
+
+		head := readResponse(t, r)
+		assert.Contains(t, head, "HTTP/1.1 200 OK\r\n")
+
+		body := make([]byte, 5)
+		_, err = io.ReadFull(r, body)
+		require.NoError(t, err)
+	}
+}
+
+func TestRequestTimeoutFinishWaitsForExpire(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	s := &Server{RequestTimeout: time.Millisecond}
+	c := &serverConn{Conn: server, srv: s}
+
+	finish := s.startRequestTimeout(c, NewHTTPParser())
+
+	// Hold up expire after the timer has fired.
+	c.mu.Lock()
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan bool, 1)
+	go func() { done <- finish() }()
+
+	select {
+	case <-done:
+		t.Fatal("finish returned before expire ran")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	c.mu.Unlock()
+
+	assert.False(t, <-done)
+	assert.True(t, c.timedOut)
+}
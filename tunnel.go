@@ -0,0 +1,189 @@
+package wildcat
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/vektra/errors"
+)
+
+var (
+	ErrTunnelRefused = errors.New("proxy refused CONNECT")
+	ErrBadResponse   = errors.New("malformed response")
+)
+
+// The largest proxy response header DialTunnel accepts.
+const maxTunnelResponseBytes = 8192
+
+var (
+	cConnect            = []byte("CONNECT ")
+	cHostLine           = []byte("Host: ")
+	cProxyAuthorization = []byte("Proxy-Authorization: ")
+	cHeaderEnd          = []byte("\r\n\r\n")
+)
+
+// Return a Proxy-Authorization value for basic authentication.
+func BasicProxyAuth(user, password string) []byte {
+	enc := base64.StdEncoding
+
+	auth := make([]byte, len("Basic ")+enc.EncodedLen(len(user)+1+len(password)))
+	copy(auth, "Basic ")
+	enc.Encode(auth[len("Basic "):], []byte(user+":"+password))
+
+	return auth
+}
+
+// tunnelConn returns bytes the proxy sent after its response before reading
+// from the connection.
+type tunnelConn struct {
+	net.Conn
+
+	pending []byte
+}
+
+func (c *tunnelConn) Read(b []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
+	return c.Conn.Read(b)
+}
+
+// Connect to the HTTP proxy at proxyAddr and ask it, with CONNECT, for a
+// tunnel to target (a host:port). proxyAuth, if not nil, is sent as
+// Proxy-Authorization (see BasicProxyAuth). The returned connection is the
+// raw tunnel, ready for TLS or any other protocol.
+//
+// A response other than 2xx fails with ErrTunnelRefused, with the status
+// line as context.
+func DialTunnel(ctx context.Context, proxyAddr, target string, proxyAuth []byte) (net.Conn, error) {
+	var d net.Dialer
+
+	c, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		c.SetDeadline(time.Unix(1, 0))
+	})
+
+	pending, err := connectTunnel(c, target, proxyAuth)
+
+	if !stop() {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	c.SetDeadline(time.Time{})
+
+	if len(pending) == 0 {
+		return c, nil
+	}
+
+	return &tunnelConn{Conn: c, pending: pending}, nil
+}
+
+func connectTunnel(c net.Conn, target string, proxyAuth []byte) ([]byte, error) {
+	req := append([]byte(nil), cConnect...)
+	req = append(req, target...)
+	req = append(req, cSP...)
+	req = append(req, cHTTP11...)
+	req = append(req, cCRLF...)
+	req = append(req, cHostLine...)
+	req = append(req, target...)
+	req = append(req, cCRLF...)
+
+	if proxyAuth != nil {
+		req = append(req, cProxyAuthorization...)
+		req = append(req, proxyAuth...)
+		req = append(req, cCRLF...)
+	}
+
+	req = append(req, cCRLF...)
+
+	if _, err := c.Write(req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxTunnelResponseBytes)
+
+	var (
+		n       int
+		readErr error
+	)
+
+	for {
+		if end := bytes.Index(buf[:n], cHeaderEnd); end != -1 {
+			interim, err := checkTunnelStatus(buf[:end])
+			if err != nil {
+				return nil, err
+			}
+
+			rest := buf[end+len(cHeaderEnd) : n]
+
+			// Skip interim responses, such as a 103, until the final one.
+			if interim {
+				n = copy(buf, rest)
+				continue
+			}
+
+			return rest, nil
+		}
+
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		if n == len(buf) {
+			return nil, ErrHeaderTooLarge
+		}
+
+		var m int
+		m, readErr = c.Read(buf[n:])
+		n += m
+	}
+}
+
+// Check the status line of a response to CONNECT, reporting whether it's
+// an interim 1xx response to skip.
+func checkTunnelStatus(head []byte) (bool, error) {
+	line := head
+	if i := bytes.IndexByte(head, '\r'); i != -1 {
+		line = head[:i]
+	}
+
+	if len(line) < 12 || !bytes.HasPrefix(line, cHTTP1) || line[8] != ' ' {
+		return false, ErrBadResponse
+	}
+
+	code, err := strconv.Atoi(string(line[9:12]))
+	if err != nil {
+		return false, ErrBadResponse
+	}
+
+	// A 101 switches protocols rather than preceding a final response.
+	if code >= 100 && code <= 199 && code != 101 {
+		return true, nil
+	}
+
+	if code < 200 || code > 299 {
+		return false, errors.Context(ErrTunnelRefused, string(line))
+	}
+
+	return false, nil
+}
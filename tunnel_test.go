@@ -0,0 +1,91 @@
+package wildcat
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektra/errors"
+)
+
+// Start a proxy that answers one CONNECT with resp and then echoes.
+func startConnectProxy(t *testing.T, resp string) (string, chan *HTTPParser) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	reqs := make(chan *HTTPParser, 1)
+
+	go func() {
+		defer l.Close()
+
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		hp := NewHTTPParser()
+		if _, _, err := ReadRequest(hp, c, make([]byte, 1024)); err != nil {
+			return
+		}
+
+		reqs <- hp
+
+		c.Write([]byte(resp))
+		io.Copy(c, c)
+	}()
+
+	return l.Addr().String(), reqs
+}
+
+func TestDialTunnel(t *testing.T) {
+	addr, reqs := startConnectProxy(t, "HTTP/1.1 200 Connection established\r\n\r\nhi")
+
+	c, err := DialTunnel(context.Background(), addr, "example.com:443", BasicProxyAuth("user", "pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	hp := <-reqs
+	assert.Equal(t, "CONNECT", string(hp.Method))
+	assert.Equal(t, "example.com:443", string(hp.Path))
+	assert.Equal(t, "example.com:443", string(hp.Host()))
+	assert.Equal(t, "Basic dXNlcjpwYXNz", string(hp.FindHeader([]byte("Proxy-Authorization"))))
+
+	// Bytes sent right after the response are not lost.
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(c, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(buf))
+
+	_, err = c.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf = make([]byte, 4)
+	_, err = io.ReadFull(c, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestDialTunnelRefused(t *testing.T) {
+	addr, _ := startConnectProxy(t, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+
+	_, err := DialTunnel(context.Background(), addr, "example.com:443", nil)
+	assert.Equal(t, ErrTunnelRefused, errors.Cause(err))
+}
+
+func TestDialTunnelInterimResponse(t *testing.T) {
+	addr, _ := startConnectProxy(t, "HTTP/1.1 103 Early Hints\r\nLink: </a>; rel=preload\r\n\r\n"+
+		"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\n\r\nhi")
+
+	c, err := DialTunnel(context.Background(), addr, "example.com:443", nil)
+	require.NoError(t, err)
+	defer c.Close()
+
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(c, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(buf))
+}
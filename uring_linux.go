@@ -0,0 +1,547 @@
+//go:build linux
+
+package wildcat
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/vektra/errors"
+)
+
+// Constants from linux/io_uring.h.
+const (
+	sysIOUringSetup = 425
+	sysIOUringEnter = 426
+
+	ioringOffSQRing = 0
+	ioringOffCQRing = 0x8000000
+	ioringOffSQEs   = 0x10000000
+
+	ioringEnterGetEvents = 1 << 0
+
+	ioringFeatSingleMmap = 1 << 0
+
+	ioringOpNop         = 0
+	ioringOpAsyncCancel = 14
+	ioringOpRecv        = 27
+)
+
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  uringSQOffsets
+	cqOff                                                                  uringCQOffsets
+}
+
+type uringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// user_data values that don't belong to a read.
+const (
+	uringWakeID uint64 = 1<<63 + iota
+	uringCancelID
+)
+
+var (
+	ErrRingClosed = errors.New("io_uring closed")
+	ErrRingBusy   = errors.New("io_uring read already in progress")
+)
+
+// URing is an io_uring instance shared by many connections. Reads issued
+// through the URingConns it creates are queued and submitted to the kernel
+// in batches by a single goroutine, and completions are reaped by another,
+// so a gateway with many connections makes far fewer syscalls than with
+// one read(2) per connection.
+type URing struct {
+	fd int
+
+	ringMem []byte
+	cqMem   []byte
+	sqeMem  []byte
+
+	sqHead, sqTail *uint32
+	sqMask         uint32
+	sqEntries      uint32
+	sqArray        []uint32
+	sqes           []uringSQE
+
+	cqHead, cqTail *uint32
+	cqMask         uint32
+	cqes           []uringCQE
+
+	mu      sync.Mutex
+	pending uint32
+	ops     map[uint64]*uringOp
+	nextID  uint64
+	closed  bool
+
+	kick chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type uringOp struct {
+	// Held so the buffer stays on the heap and alive while the kernel
+	// writes into it.
+	buf []byte
+	res chan int32
+}
+
+// Create a ring with room for entries submissions at a time.
+func NewURing(entries uint32) (*URing, error) {
+	var p uringParams
+
+	fd, _, errno := syscall.Syscall(sysIOUringSetup, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	r := &URing{
+		fd:   int(fd),
+		ops:  make(map[uint64]*uringOp),
+		kick: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	if err := r.mmap(&p); err != nil {
+		r.unmap()
+		syscall.Close(r.fd)
+		return nil, err
+	}
+
+	r.wg.Add(2)
+	go r.submitLoop()
+	go r.reapLoop()
+
+	return r, nil
+}
+
+func (r *URing) mmap(p *uringParams) error {
+	sqSize := int(p.sqOff.array + p.sqEntries*4)
+	cqSize := int(p.cqOff.cqes + p.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+
+	single := p.features&ioringFeatSingleMmap != 0
+	if single && cqSize > sqSize {
+		sqSize = cqSize
+	}
+
+	var err error
+
+	prot := syscall.PROT_READ | syscall.PROT_WRITE
+	flags := syscall.MAP_SHARED | syscall.MAP_POPULATE
+
+	r.ringMem, err = syscall.Mmap(r.fd, ioringOffSQRing, sqSize, prot, flags)
+	if err != nil {
+		return err
+	}
+
+	r.cqMem = r.ringMem
+	if !single {
+		r.cqMem, err = syscall.Mmap(r.fd, ioringOffCQRing, cqSize, prot, flags)
+		if err != nil {
+			return err
+		}
+	}
+
+	sqeSize := int(p.sqEntries) * int(unsafe.Sizeof(uringSQE{}))
+	r.sqeMem, err = syscall.Mmap(r.fd, ioringOffSQEs, sqeSize, prot, flags)
+	if err != nil {
+		return err
+	}
+
+	u32 := func(mem []byte, off uint32) *uint32 {
+		return (*uint32)(unsafe.Pointer(&mem[off]))
+	}
+
+	r.sqHead = u32(r.ringMem, p.sqOff.head)
+	r.sqTail = u32(r.ringMem, p.sqOff.tail)
+	r.sqMask = *u32(r.ringMem, p.sqOff.ringMask)
+	r.sqEntries = *u32(r.ringMem, p.sqOff.ringEntries)
+	r.sqArray = unsafe.Slice(u32(r.ringMem, p.sqOff.array), p.sqEntries)
+	r.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&r.sqeMem[0])), p.sqEntries)
+
+	r.cqHead = u32(r.cqMem, p.cqOff.head)
+	r.cqTail = u32(r.cqMem, p.cqOff.tail)
+	r.cqMask = *u32(r.cqMem, p.cqOff.ringMask)
+	r.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&r.cqMem[p.cqOff.cqes])), p.cqEntries)
+
+	return nil
+}
+
+func (r *URing) unmap() {
+	if r.sqeMem != nil {
+		syscall.Munmap(r.sqeMem)
+	}
+
+	if r.cqMem != nil && &r.cqMem[0] != &r.ringMem[0] {
+		syscall.Munmap(r.cqMem)
+	}
+
+	if r.ringMem != nil {
+		syscall.Munmap(r.ringMem)
+	}
+}
+
+func (r *URing) enter(toSubmit, minComplete, flags uint32) (int, error) {
+	for {
+		n, _, errno := syscall.Syscall6(sysIOUringEnter, uintptr(r.fd),
+			uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+
+		switch errno {
+		case 0:
+			return int(n), nil
+		case syscall.EINTR, syscall.EAGAIN, syscall.EBUSY:
+			if minComplete == 0 && toSubmit == 0 {
+				return 0, nil
+			}
+			continue
+		default:
+			return 0, errno
+		}
+	}
+}
+
+// Submit the queued entries. Must hold r.mu.
+func (r *URing) submitLocked() error {
+	for r.pending > 0 {
+		n, err := r.enter(r.pending, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		r.pending -= uint32(n)
+	}
+
+	return nil
+}
+
+// Queue an entry, submitting right away if the queue is full. Must hold r.mu.
+func (r *URing) pushLocked(sqe uringSQE) error {
+	tail := *r.sqTail
+
+	if tail-atomic.LoadUint32(r.sqHead) == r.sqEntries {
+		if err := r.submitLocked(); err != nil {
+			return err
+		}
+	}
+
+	idx := tail & r.sqMask
+	r.sqes[idx] = sqe
+	r.sqArray[idx] = idx
+
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.pending++
+
+	select {
+	case r.kick <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (r *URing) submitLoop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-r.kick:
+		case <-r.done:
+			return
+		}
+
+		r.mu.Lock()
+		err := r.submitLocked()
+		if err != nil {
+			r.failAllLocked(err)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Fail every outstanding read, used when the ring itself breaks.
+func (r *URing) failAllLocked(err error) {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		errno = syscall.EIO
+	}
+
+	for id, op := range r.ops {
+		op.res <- -int32(errno)
+		delete(r.ops, id)
+	}
+}
+
+func (r *URing) reapLoop() {
+	defer r.wg.Done()
+
+	for {
+		if _, err := r.enter(0, 1, ioringEnterGetEvents); err != nil {
+			r.mu.Lock()
+			r.failAllLocked(err)
+			r.mu.Unlock()
+			return
+		}
+
+		head := atomic.LoadUint32(r.cqHead)
+		tail := atomic.LoadUint32(r.cqTail)
+
+		r.mu.Lock()
+
+		for ; head != tail; head++ {
+			cqe := r.cqes[head&r.cqMask]
+
+			if op, ok := r.ops[cqe.userData]; ok {
+				delete(r.ops, cqe.userData)
+				op.res <- cqe.res
+			}
+		}
+
+		atomic.StoreUint32(r.cqHead, head)
+
+		exit := r.closed && len(r.ops) == 0
+		r.mu.Unlock()
+
+		if exit {
+			return
+		}
+	}
+}
+
+// Start a recv of fd into buf, returning the id of the operation and the
+// channel its result is delivered on.
+func (r *URing) recv(fd int, buf []byte) (uint64, chan int32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, nil, ErrRingClosed
+	}
+
+	r.nextID++
+	id := r.nextID
+
+	op := &uringOp{buf: buf, res: make(chan int32, 1)}
+
+	sqe := uringSQE{
+		opcode:   ioringOpRecv,
+		fd:       int32(fd),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: id,
+	}
+
+	if err := r.pushLocked(sqe); err != nil {
+		return 0, nil, err
+	}
+
+	r.ops[id] = op
+
+	return id, op.res, nil
+}
+
+// Ask the kernel to cancel operation id. Its result still arrives on the
+// operation's channel, usually as -ECANCELED.
+func (r *URing) cancel(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ops[id]; !ok {
+		return
+	}
+
+	r.pushLocked(uringSQE{
+		opcode:   ioringOpAsyncCancel,
+		fd:       -1,
+		addr:     id,
+		userData: uringCancelID,
+	})
+}
+
+// Close cancels outstanding reads, waits for them to finish and releases
+// the ring.
+func (r *URing) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRingClosed
+	}
+
+	r.closed = true
+
+	for id := range r.ops {
+		r.pushLocked(uringSQE{
+			opcode:   ioringOpAsyncCancel,
+			fd:       -1,
+			addr:     id,
+			userData: uringCancelID,
+		})
+	}
+
+	// Wakes the reaper even if there were no reads outstanding.
+	r.pushLocked(uringSQE{opcode: ioringOpNop, fd: -1, userData: uringWakeID})
+	err := r.submitLocked()
+	r.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	close(r.done)
+	r.wg.Wait()
+
+	r.unmap()
+	return syscall.Close(r.fd)
+}
+
+// URingConn is a net.Conn whose reads go through a URing. It satisfies
+// io.ReadCloser, so it can be passed to ReadRequest and BodyReader in place
+// of the plain connection for both header and body reads.
+//
+// Read deadlines set on the connection are not applied to ring reads. Close
+// the URingConn, not the underlying connection, so a pending read is
+// cancelled first.
+type URingConn struct {
+	net.Conn
+
+	ring *URing
+	raw  syscall.RawConn
+
+	mu      sync.Mutex
+	reading bool
+	closed  bool
+	id      uint64
+}
+
+// Wrap c, which must expose its file descriptor via syscall.Conn (as
+// *net.TCPConn and *net.UnixConn do), to read through the ring.
+func (r *URing) Conn(c net.Conn) (*URingConn, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	return &URingConn{Conn: c, ring: r, raw: raw}, nil
+}
+
+func (c *URingConn) Read(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	switch {
+	case c.closed:
+		c.mu.Unlock()
+		return 0, net.ErrClosed
+	case c.reading:
+		c.mu.Unlock()
+		return 0, ErrRingBusy
+	}
+	c.reading = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.reading = false
+		c.id = 0
+		c.mu.Unlock()
+	}()
+
+	var res int32
+	var rerr error
+
+	// The descriptor is only guaranteed to stay open inside the callback,
+	// so the whole operation happens in there.
+	err := c.raw.Read(func(fd uintptr) bool {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			rerr = net.ErrClosed
+			return true
+		}
+
+		id, ch, err := c.ring.recv(int(fd), buf)
+		c.id = id
+		c.mu.Unlock()
+
+		if err != nil {
+			rerr = err
+			return true
+		}
+
+		res = <-ch
+		return true
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if rerr != nil {
+		return 0, rerr
+	}
+
+	switch {
+	case res > 0:
+		return int(res), nil
+	case res == 0:
+		return 0, io.EOF
+	case syscall.Errno(-res) == syscall.ECANCELED:
+		return 0, net.ErrClosed
+	default:
+		return 0, syscall.Errno(-res)
+	}
+}
+
+// Cancel any pending read and close the connection.
+func (c *URingConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	if c.id != 0 {
+		c.ring.cancel(c.id)
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package wildcat
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestURing(t *testing.T) *URing {
+	r, err := NewURing(8)
+	if err != nil {
+		t.Skipf("io_uring unavailable: %s", err)
+	}
+
+	return r
+}
+
+func TestURingReadRequest(t *testing.T) {
+	r := newTestURing(t)
+	defer r.Close()
+
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	c, err := r.Conn(server)
+	require.NoError(t, err)
+	defer c.Close()
+
+	go func() {
+		client.Write([]byte("POST / HTTP/1.1\r\nHost: x\r\nContent-"))
+		time.Sleep(10 * time.Millisecond)
+		client.Write([]byte("Length: 11\r\n\r\nhello"))
+		time.Sleep(10 * time.Millisecond)
+		client.Write([]byte(" world"))
+	}()
+
+	hp := NewHTTPParser()
+	buf := make([]byte, OptimalBufferSize)
+
+	headerLen, n, err := ReadRequest(hp, c, buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("x"), hp.Host())
+
+	body, err := io.ReadAll(hp.BodyReader(buf[headerLen:n], c))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+}
+
+func TestURingManyConns(t *testing.T) {
+	r := newTestURing(t)
+	defer r.Close()
+
+	const conns = 32
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < conns; i++ {
+		client, server := tcpPair(t)
+		defer client.Close()
+
+		c, err := r.Conn(server)
+		require.NoError(t, err)
+		defer c.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			hp := NewHTTPParser()
+			buf := make([]byte, OptimalBufferSize)
+
+			_, _, err := ReadRequest(hp, c, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("/many"), hp.Path)
+		}()
+
+		go client.Write([]byte("GET /many HTTP/1.1\r\n\r\n"))
+	}
+
+	wg.Wait()
+}
+
+func TestURingCloseCancelsRead(t *testing.T) {
+	r := newTestURing(t)
+	defer r.Close()
+
+	client, server := tcpPair(t)
+	defer client.Close()
+
+	c, err := r.Conn(server)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Read(make([]byte, 10))
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, c.Close())
+
+	select {
+	case err := <-done:
+		assert.Equal(t, net.ErrClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("read was not cancelled")
+	}
+}
+
+func TestURingEOF(t *testing.T) {
+	r := newTestURing(t)
+	defer r.Close()
+
+	client, server := tcpPair(t)
+
+	c, err := r.Conn(server)
+	require.NoError(t, err)
+	defer c.Close()
+
+	client.Close()
+
+	_, err = c.Read(make([]byte, 10))
+	assert.Equal(t, io.EOF, err)
+}
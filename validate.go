@@ -0,0 +1,62 @@
+package wildcat
+
+import "github.com/vektra/errors"
+
+// Returned by Parse in strict mode for a header name or value containing a
+// byte its table doesn't allow.
+var ErrBadHeaderByte = errors.New("invalid byte in header")
+
+// ByteTable says which bytes are allowed: b is allowed if the entry for b
+// is true.
+type ByteTable [256]bool
+
+// Allow each byte of chars.
+func (t *ByteTable) Allow(chars string) {
+	for i := 0; i < len(chars); i++ {
+		t[chars[i]] = true
+	}
+}
+
+// Disallow each byte of chars.
+func (t *ByteTable) Disallow(chars string) {
+	for i := 0; i < len(chars); i++ {
+		t[chars[i]] = false
+	}
+}
+
+// Allow the bytes from lo to hi inclusive, for example 0x80 to 0xFF to let
+// UTF-8 through.
+func (t *ByteTable) AllowRange(lo, hi byte) {
+	for c := int(lo); c <= int(hi); c++ {
+		t[c] = true
+	}
+}
+
+// The tables strict mode checks header names and values against.
+type ValidationTables struct {
+	Name, Value ByteTable
+}
+
+// The default tables: names must be tokens and values visible ASCII,
+// space and tab (RFC 9110, section 5). Copy this to adjust it for a
+// deployment, such as to let UTF-8 through in values or to reject
+// underscores in names.
+var DefaultValidationTables = func() ValidationTables {
+	var vt ValidationTables
+
+	for c := 0; c < 256; c++ {
+		vt.Name[c] = isTokenChar(byte(c))
+	}
+
+	vt.Value.AllowRange(0x21, 0x7E)
+	vt.Value.Allow(" \t")
+
+	return vt
+}()
+
+// Enable strict mode: Parse fails with ErrBadHeaderByte if a header name
+// or value has a byte that t doesn't allow, or a name is empty. nil turns
+// it off, which is the default. t is used as is, not copied.
+func (hp *HTTPParser) SetStrict(t *ValidationTables) {
+	hp.strict = t
+}
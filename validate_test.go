@@ -0,0 +1,78 @@
+package wildcat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserStrict(t *testing.T) {
+	hp := NewHTTPParser()
+	hp.SetStrict(&DefaultValidationTables)
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nX_Under: a b\t c\r\n\r\n"))
+	require.NoError(t, err)
+
+	bad := []string{
+		"GET / HTTP/1.1\r\nBad Name: x\r\n\r\n",
+		"GET / HTTP/1.1\r\nX-A: caf\xc3\xa9\r\n\r\n",
+		"GET / HTTP/1.1\r\nX-A: \x01\r\n\r\n",
+		"GET / HTTP/1.1\r\nX-A: ok\x7f\r\n\r\n",
+		"GET / HTTP/1.1\r\nX-A: a\r\n b\x00\r\n\r\n",
+	}
+
+	for _, req := range bad {
+		_, err := hp.Parse([]byte(req))
+		assert.Equal(t, ErrBadHeaderByte, err, "%q", req)
+	}
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.1\r\n: empty\r\n\r\n"))
+	assert.Equal(t, ErrBadHeaderByte, err)
+
+	hp.SetStrict(nil)
+
+	for _, req := range bad {
+		_, err := hp.Parse([]byte(req))
+		assert.NoError(t, err, "%q", req)
+	}
+}
+
+func TestParserStrictCustomTables(t *testing.T) {
+	tables := DefaultValidationTables
+	tables.Value.AllowRange(0x80, 0xFF)
+	tables.Name.Disallow("_")
+
+	hp := NewHTTPParser()
+	hp.SetStrict(&tables)
+
+	_, err := hp.Parse([]byte("GET / HTTP/1.1\r\nX-A: caf\xc3\xa9\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "caf\xc3\xa9", string(hp.FindHeader([]byte("X-A"))))
+
+	_, err = hp.Parse([]byte("GET / HTTP/1.1\r\nX_A: b\r\n\r\n"))
+	assert.Equal(t, ErrBadHeaderByte, err)
+
+	// The defaults are untouched.
+	assert.True(t, DefaultValidationTables.Name['_'])
+	assert.False(t, DefaultValidationTables.Value[0x80])
+}
+
+func TestParserStrictEmptyValue(t *testing.T) {
+	for _, strict := range []*ValidationTables{&DefaultValidationTables, nil} {
+		hp := NewHTTPParser()
+		hp.SetStrict(strict)
+
+		for _, req := range []string{
+			"GET / HTTP/1.1\r\nX-A:\r\nHost: h\r\n\r\n",
+			"GET / HTTP/1.1\r\nX-A:  \r\nHost: h\r\n\r\n",
+			"GET / HTTP/1.1\nX-A:\nHost: h\n\n",
+		} {
+			_, err := hp.Parse([]byte(req))
+			require.NoError(t, err, "%q", req)
+
+			assert.Equal(t, "", string(hp.FindHeader([]byte("X-A"))), "%q", req)
+			assert.Equal(t, "h", string(hp.Host()), "%q", req)
+		}
+	}
+}
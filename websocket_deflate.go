@@ -0,0 +1,295 @@
+package wildcat
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/vektra/errors"
+)
+
+// Parameters of the permessage-deflate WebSocket extension (RFC 7692).
+type PermessageDeflate struct {
+	// The server resets its compressor after every message.
+	ServerNoContextTakeover bool
+
+	// The client resets its compressor after every message, so the server
+	// can do the same with its decompressor.
+	ClientNoContextTakeover bool
+}
+
+var (
+	cSecWebSocketExtensions = []byte("Sec-WebSocket-Extensions")
+	cPermessageDeflate      = []byte("permessage-deflate")
+
+	cServerNoContextTakeover = []byte("server_no_context_takeover")
+	cClientNoContextTakeover = []byte("client_no_context_takeover")
+	cServerMaxWindowBits     = []byte("server_max_window_bits")
+	cClientMaxWindowBits     = []byte("client_max_window_bits")
+)
+
+// Pick the first permessage-deflate offer in the request's
+// Sec-WebSocket-Extensions that can be accepted. The context takeover
+// settings in prefer are added to what the client asked for. Offers that
+// limit the server's window are declined, as compress/flate always uses the
+// full 32KB window.
+func (hp *HTTPParser) NegotiatePermessageDeflate(prefer PermessageDeflate) (PermessageDeflate, bool) {
+	for _, value := range hp.FindAllHeaders(cSecWebSocketExtensions) {
+		for list := value; len(list) > 0; {
+			var item []byte
+			item, list = nextListItem(list)
+
+			if p, ok := parseDeflateOffer(item); ok {
+				p.ServerNoContextTakeover = p.ServerNoContextTakeover || prefer.ServerNoContextTakeover
+				p.ClientNoContextTakeover = p.ClientNoContextTakeover || prefer.ClientNoContextTakeover
+				return p, true
+			}
+		}
+	}
+
+	return PermessageDeflate{}, false
+}
+
+func parseDeflateOffer(item []byte) (PermessageDeflate, bool) {
+	var (
+		p    PermessageDeflate
+		seen [4]bool
+		ok   = true
+	)
+
+	ext := ParseParameterizedValue(item, func(name, value []byte) bool {
+		var idx int
+
+		switch {
+		case equalFoldASCII(name, cServerNoContextTakeover) && value == nil:
+			idx = 0
+			p.ServerNoContextTakeover = true
+		case equalFoldASCII(name, cClientNoContextTakeover) && value == nil:
+			idx = 1
+			p.ClientNoContextTakeover = true
+		case equalFoldASCII(name, cServerMaxWindowBits):
+			idx = 2
+			ok = string(value) == "15"
+		case equalFoldASCII(name, cClientMaxWindowBits):
+			// Any window the client compresses with can be inflated.
+			idx = 3
+			if value != nil {
+				bits, err := strconv.Atoi(string(value))
+				ok = err == nil && bits >= 8 && bits <= 15
+			}
+		default:
+			ok = false
+		}
+
+		if seen[idx] {
+			ok = false
+		}
+
+		seen[idx] = true
+		return ok
+	})
+
+	if !ok || !equalFoldASCII(ext, cPermessageDeflate) {
+		return PermessageDeflate{}, false
+	}
+
+	return p, true
+}
+
+// Append the Sec-WebSocket-Extensions value that accepts p.
+func (p PermessageDeflate) AppendExtension(dst []byte) []byte {
+	dst = append(dst, cPermessageDeflate...)
+
+	if p.ServerNoContextTakeover {
+		dst = append(dst, "; "...)
+		dst = append(dst, cServerNoContextTakeover...)
+	}
+
+	if p.ClientNoContextTakeover {
+		dst = append(dst, "; "...)
+		dst = append(dst, cClientNoContextTakeover...)
+	}
+
+	return dst
+}
+
+// The last 4 bytes of a sync flush, which RFC 7692 strips from messages.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// Appended when inflating: the sync tail plus an empty final block, so the
+// reader sees a clean end of stream.
+var inflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// How much output is kept as the dictionary when taking over context.
+const maxDeflateWindow = 32 << 10
+
+var (
+	flateWriterPools [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+	flateReaderPool  sync.Pool
+)
+
+func getFlateWriter(w io.Writer, level int) *flate.Writer {
+	if fw, ok := flateWriterPools[level-flate.HuffmanOnly].Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return fw
+	}
+
+	fw, _ := flate.NewWriter(w, level)
+	return fw
+}
+
+func putFlateWriter(fw *flate.Writer, level int) {
+	flateWriterPools[level-flate.HuffmanOnly].Put(fw)
+}
+
+func getFlateReader(r io.Reader, dict []byte) io.ReadCloser {
+	if fr, ok := flateReaderPool.Get().(io.ReadCloser); ok {
+		fr.(flate.Resetter).Reset(r, dict)
+		return fr
+	}
+
+	return flate.NewReaderDict(r, dict)
+}
+
+// MessageDeflater compresses WebSocket messages for permessage-deflate.
+// Without context takeover, compressors are borrowed from a pool for each
+// message so idle connections don't hold on to flate state.
+type MessageDeflater struct {
+	takeover bool
+	level    int
+
+	buf bytes.Buffer
+	fw  *flate.Writer
+}
+
+// Create a MessageDeflater. takeover is !ServerNoContextTakeover for a
+// server. level is a compress/flate level; out of range levels use
+// flate.DefaultCompression.
+func NewMessageDeflater(takeover bool, level int) *MessageDeflater {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+
+	return &MessageDeflater{takeover: takeover, level: level}
+}
+
+// Append the compressed form of msg to dst.
+func (md *MessageDeflater) Compress(dst, msg []byte) ([]byte, error) {
+	md.buf.Reset()
+
+	fw := md.fw
+	if fw == nil {
+		fw = getFlateWriter(&md.buf, md.level)
+	}
+
+	if _, err := fw.Write(msg); err != nil {
+		return dst, err
+	}
+
+	if err := fw.Flush(); err != nil {
+		return dst, err
+	}
+
+	if md.takeover {
+		md.fw = fw
+	} else {
+		putFlateWriter(fw, md.level)
+	}
+
+	out := md.buf.Bytes()
+	if bytes.HasSuffix(out, deflateTail) {
+		out = out[:len(out)-len(deflateTail)]
+	}
+
+	return append(dst, out...), nil
+}
+
+// Return the compressor to the pool. The MessageDeflater can't be used
+// afterwards.
+func (md *MessageDeflater) Close() {
+	if md.fw != nil {
+		putFlateWriter(md.fw, md.level)
+		md.fw = nil
+	}
+}
+
+// Returned by MessageInflater.Decompress for a message that inflates to
+// more than MaxMessageSize. The connection should be failed with close
+// code 1009, as the decompressor's context is lost.
+var ErrMessageTooLarge = errors.New("websocket message too large")
+
+// The default MaxMessageSize of a MessageInflater.
+const DefaultMaxMessageSize = 16 << 20
+
+// MessageInflater decompresses WebSocket messages for permessage-deflate.
+type MessageInflater struct {
+	// The most bytes a message may decompress to, so that a small message
+	// can't inflate without bound. Defaults to DefaultMaxMessageSize; 0
+	// means no limit.
+	MaxMessageSize int
+
+	takeover bool
+
+	src  bytes.Reader
+	tail bytes.Reader
+	dict []byte
+}
+
+// Create a MessageInflater. takeover is !ClientNoContextTakeover for a
+// server.
+func NewMessageInflater(takeover bool) *MessageInflater {
+	return &MessageInflater{MaxMessageSize: DefaultMaxMessageSize, takeover: takeover}
+}
+
+// Append the decompressed form of msg to dst. Fails with
+// ErrMessageTooLarge if it's more than MaxMessageSize bytes.
+func (mi *MessageInflater) Decompress(dst, msg []byte) ([]byte, error) {
+	mi.src.Reset(msg)
+	mi.tail.Reset(inflateTail)
+
+	fr := getFlateReader(io.MultiReader(&mi.src, &mi.tail), mi.dict)
+	defer flateReaderPool.Put(fr)
+
+	start := len(dst)
+
+	for {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+
+		buf := dst[len(dst):cap(dst)]
+
+		// Read at most one byte past the limit, enough to tell it was passed.
+		if max := mi.MaxMessageSize; max > 0 && len(buf) > max-(len(dst)-start)+1 {
+			buf = buf[:max-(len(dst)-start)+1]
+		}
+
+		n, err := fr.Read(buf)
+		dst = dst[:len(dst)+n]
+
+		if mi.MaxMessageSize > 0 && len(dst)-start > mi.MaxMessageSize {
+			return dst[:start], ErrMessageTooLarge
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return dst[:start], err
+		}
+	}
+
+	if mi.takeover {
+		out := dst[start:]
+
+		mi.dict = append(mi.dict, out...)
+		if len(mi.dict) > maxDeflateWindow {
+			mi.dict = append(mi.dict[:0], mi.dict[len(mi.dict)-maxDeflateWindow:]...)
+		}
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,94 @@
+package wildcat
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiatePermessageDeflate(t *testing.T) {
+	hp := NewHTTPParser()
+
+	_, err := hp.Parse([]byte("GET /ws HTTP/1.1\r\nSec-WebSocket-Extensions: permessage-deflate; server_max_window_bits=10, permessage-deflate; client_max_window_bits\r\n\r\n"))
+	require.NoError(t, err)
+
+	p, ok := hp.NegotiatePermessageDeflate(PermessageDeflate{ServerNoContextTakeover: true})
+	require.True(t, ok)
+
+	assert.Equal(t, PermessageDeflate{ServerNoContextTakeover: true}, p)
+	assert.Equal(t, "permessage-deflate; server_no_context_takeover", string(p.AppendExtension(nil)))
+}
+
+func TestNegotiatePermessageDeflateDeclines(t *testing.T) {
+	for _, value := range []string{
+		"x-webkit-deflate-frame",
+		"permessage-deflate; unknown",
+		"permessage-deflate; client_no_context_takeover; client_no_context_takeover",
+		"permessage-deflate; client_max_window_bits=20",
+	} {
+		hp := NewHTTPParser()
+
+		_, err := hp.Parse([]byte("GET /ws HTTP/1.1\r\nSec-WebSocket-Extensions: " + value + "\r\n\r\n"))
+		require.NoError(t, err)
+
+		_, ok := hp.NegotiatePermessageDeflate(PermessageDeflate{})
+		assert.False(t, ok, value)
+	}
+}
+
+func TestMessageDeflateRoundTrip(t *testing.T) {
+	for _, takeover := range []bool{false, true} {
+		md := NewMessageDeflater(takeover, flate.BestSpeed)
+		mi := NewMessageInflater(takeover)
+
+		msg := bytes.Repeat([]byte("hello websocket "), 50)
+
+		var sizes []int
+
+		for i := 0; i < 3; i++ {
+			compressed, err := md.Compress(nil, msg)
+			require.NoError(t, err)
+
+			assert.False(t, bytes.HasSuffix(compressed, deflateTail))
+			sizes = append(sizes, len(compressed))
+
+			out, err := mi.Decompress(nil, compressed)
+			require.NoError(t, err)
+			assert.Equal(t, msg, out)
+		}
+
+		// With context takeover, repeats compress against the previous
+		// message.
+		if takeover {
+			assert.True(t, sizes[1] < sizes[0])
+		}
+
+		md.Close()
+	}
+}
+
+func TestMessageInflaterMaxMessageSize(t *testing.T) {
+	md := NewMessageDeflater(false, flate.BestCompression)
+	defer md.Close()
+
+	msg := make([]byte, 1<<20)
+
+	compressed, err := md.Compress(nil, msg)
+	require.NoError(t, err)
+
+	mi := NewMessageInflater(false)
+	mi.MaxMessageSize = len(msg) - 1
+
+	out, err := mi.Decompress([]byte("x"), compressed)
+	assert.Equal(t, ErrMessageTooLarge, err)
+	assert.Equal(t, "x", string(out))
+
+	mi.MaxMessageSize = len(msg)
+
+	out, err = mi.Decompress(nil, compressed)
+	require.NoError(t, err)
+	assert.Equal(t, msg, out)
+}